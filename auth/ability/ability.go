@@ -0,0 +1,150 @@
+package ability
+
+// Ability contains the rules of the roles of one caller (use New)
+type Ability struct {
+	rules []Rule
+}
+
+// New creates the ability of user with roleNames. Templates in conditions are resolved with user
+func New(roles Roles, roleNames []string, user map[string]interface{}) (*Ability, error) {
+	ability := &Ability{}
+	for _, roleName := range roleNames {
+		for _, rule := range roles[roleName] {
+			resolved, ok, err := rule.resolve(user)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				ability.rules = append(ability.rules, resolved)
+			}
+		}
+	}
+	return ability, nil
+}
+
+// rulesFor returns the rules for action on subject ordered by precedence (the last defined rule first)
+func (a *Ability) rulesFor(action string, subject string) []Rule {
+	rules := []Rule{}
+	for i := len(a.rules) - 1; i >= 0; i-- {
+		rule := a.rules[i]
+		if rule.matchesAction(action) && rule.matchesSubject(subject) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Can returns if action on subject is allowed for at least some records
+func (a *Ability) Can(action string, subject string) bool {
+	for _, rule := range a.rulesFor(action, subject) {
+		if rule.Inverted && (len(rule.Conditions) > 0 || len(rule.Fields) > 0) {
+			// only forbids some records or fields
+			continue
+		}
+		return !rule.Inverted
+	}
+	return false
+}
+
+// CanRecord returns if action on record of subject is allowed. The rule with the highest precedence which matches record decides
+func (a *Ability) CanRecord(action string, subject string, record map[string]interface{}) (bool, *Rule, error) {
+	for _, rule := range a.rulesFor(action, subject) {
+		if rule.Inverted && len(rule.Fields) > 0 {
+			continue
+		}
+		ok, err := matches(record, rule.Conditions)
+		if err != nil {
+			return false, nil, err
+		}
+		if ok {
+			return !rule.Inverted, &rule, nil
+		}
+	}
+	return false, nil, nil
+}
+
+// Query returns the conditions all records of action on subject have to match (like CASL rulesToQuery).
+/*
+The query is empty if all records are allowed. ok is false if no record is allowed
+*/
+func (a *Ability) Query(action string, subject string) (query map[string]interface{}, ok bool) {
+	or := []interface{}{}
+	and := []interface{}{}
+	for _, rule := range a.rulesFor(action, subject) {
+		if rule.Inverted && len(rule.Fields) > 0 {
+			continue
+		}
+		if len(rule.Conditions) == 0 {
+			if rule.Inverted {
+				break
+			}
+			// all remaining records are allowed
+			or = nil
+			break
+		}
+		if rule.Inverted {
+			and = append(and, map[string]interface{}{"$nor": []interface{}{rule.Conditions}})
+		} else {
+			or = append(or, rule.Conditions)
+		}
+	}
+	if or != nil {
+		if len(or) == 0 {
+			return nil, false
+		}
+		and = append(and, map[string]interface{}{"$or": or})
+	}
+	query = map[string]interface{}{}
+	if len(and) > 0 {
+		query["$and"] = and
+	}
+	return query, true
+}
+
+// FieldSet are the fields of a record a caller may access
+type FieldSet struct {
+	all    bool
+	fields map[string]bool
+}
+
+// Has returns if field may be accessed
+func (f FieldSet) Has(field string) bool {
+	if permitted, ok := f.fields[field]; ok {
+		return permitted
+	}
+	return f.all
+}
+
+// PermittedFields returns the fields of record which action on subject may access.
+/*
+Rules without fields permit all fields. If record is nil conditions are ignored (e.g. for patch data which does not contain
+the fields of the conditions, the records are scoped by Query), only forbidding rules without conditions and fields forbid all fields
+*/
+func (a *Ability) PermittedFields(action string, subject string, record map[string]interface{}) (FieldSet, error) {
+	set := FieldSet{fields: map[string]bool{}}
+	// apply from lowest to highest precedence
+	rules := a.rulesFor(action, subject)
+	for i := len(rules) - 1; i >= 0; i-- {
+		rule := rules[i]
+		if record != nil {
+			ok, err := matches(record, rule.Conditions)
+			if err != nil {
+				return set, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		switch {
+		case len(rule.Fields) > 0:
+			for _, field := range rule.Fields {
+				set.fields[field] = !rule.Inverted
+			}
+		case !rule.Inverted:
+			set = FieldSet{all: true, fields: map[string]bool{}}
+		case record != nil || len(rule.Conditions) == 0:
+			set = FieldSet{fields: map[string]bool{}}
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,279 @@
+package ability_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/auth/ability"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/memory"
+)
+
+var testRoles = ability.Roles{
+	"admin": {
+		ability.Can(ability.ActionManage, ability.SubjectAll, nil),
+	},
+	"user": {
+		ability.Can(ability.ActionRead, "messages", map[string]interface{}{"public": true}, "text"),
+		ability.Can(ability.ActionManage, "messages", map[string]interface{}{"userId": "${user._id}"}),
+		ability.Cannot("remove", "messages", map[string]interface{}{"locked": true}),
+		ability.Cannot("patch", "messages", nil, "userId"),
+	},
+}
+
+var testUser = map[string]interface{}{"_id": "1", "roles": []interface{}{"user"}}
+
+func TestAbilityQuery(t *testing.T) {
+	userAbility, err := ability.New(testRoles, []string{"user"}, testUser)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for key, test := range []struct {
+		ability  *ability.Ability
+		action   string
+		expected map[string]interface{}
+		ok       bool
+	}{
+		/* #1 */ {userAbility, "find", map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"userId": "1"},
+				map[string]interface{}{"public": true},
+			}},
+		}}, true},
+		/* #2 */ {userAbility, "remove", map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"$nor": []interface{}{map[string]interface{}{"locked": true}}},
+			map[string]interface{}{"$or": []interface{}{map[string]interface{}{"userId": "1"}}},
+		}}, true},
+		/* #3 */ {userAbility, "create", map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"$or": []interface{}{map[string]interface{}{"userId": "1"}}},
+		}}, true},
+	} {
+		query, ok := test.ability.Query(test.action, "messages")
+		if ok != test.ok || !reflect.DeepEqual(query, test.expected) {
+			t.Errorf("Failed #%d: expected %#v (%t), got %#v (%t)", key+1, test.expected, test.ok, query, ok)
+		}
+	}
+
+	admin, _ := ability.New(testRoles, []string{"admin"}, nil)
+	if query, ok := admin.Query("remove", "messages"); !ok || len(query) != 0 {
+		t.Errorf("Admin should not be scoped, got %#v", query)
+	}
+	guest, _ := ability.New(testRoles, []string{"guest"}, nil)
+	if _, ok := guest.Query("find", "messages"); ok || guest.Can("find", "messages") {
+		t.Errorf("Guest should not be allowed to find messages")
+	}
+	anonymous, _ := ability.New(testRoles, []string{"user"}, nil)
+	if query, _ := anonymous.Query("find", "messages"); !reflect.DeepEqual(query["$and"], []interface{}{
+		map[string]interface{}{"$or": []interface{}{map[string]interface{}{"public": true}}},
+	}) {
+		t.Errorf("Rules with unresolved templates should be ignored, got %#v", query)
+	}
+}
+
+func TestAbilityRecords(t *testing.T) {
+	userAbility, _ := ability.New(testRoles, []string{"user"}, testUser)
+	for key, test := range []struct {
+		action   string
+		record   map[string]interface{}
+		expected bool
+	}{
+		/* #1 */ {"get", map[string]interface{}{"userId": "1"}, true},
+		/* #2 */ {"get", map[string]interface{}{"userId": "2"}, false},
+		/* #3 */ {"get", map[string]interface{}{"userId": "2", "public": true}, true},
+		/* #4 */ {"remove", map[string]interface{}{"userId": "1", "locked": true}, false},
+		/* #5 */ {"remove", map[string]interface{}{"userId": "1", "locked": false}, true},
+		/* #6 */ {"create", map[string]interface{}{"userId": "2"}, false},
+	} {
+		allowed, _, err := userAbility.CanRecord(test.action, "messages", test.record)
+		if err != nil || allowed != test.expected {
+			t.Errorf("Failed #%d: expected %t, got %t (%v)", key+1, test.expected, allowed, err)
+		}
+	}
+
+	fields, _ := userAbility.PermittedFields("get", "messages", map[string]interface{}{"userId": "2", "public": true})
+	if !fields.Has("text") || fields.Has("userId") {
+		t.Errorf("Public messages of other users should only expose text")
+	}
+	fields, _ = userAbility.PermittedFields("get", "messages", map[string]interface{}{"userId": "1", "public": true})
+	if !fields.Has("text") || !fields.Has("userId") {
+		t.Errorf("Own messages should expose all fields")
+	}
+	fields, _ = userAbility.PermittedFields("patch", "messages", nil)
+	if !fields.Has("text") || fields.Has("userId") {
+		t.Errorf("userId should not be writable on patch")
+	}
+}
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ability.ParseRoles(map[string]interface{}{
+		"user": []interface{}{
+			map[string]interface{}{"action": "read", "subject": []interface{}{"messages", "users"}, "fields": "text"},
+			map[string]interface{}{"action": "remove", "subject": "messages", "inverted": true, "reason": "No"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []ability.Rule{
+		{Action: []string{"read"}, Subject: []string{"messages", "users"}, Fields: []string{"text"}},
+		{Action: []string{"remove"}, Subject: []string{"messages"}, Inverted: true, Reason: "No"},
+	}
+	if !reflect.DeepEqual(roles["user"], expected) {
+		t.Errorf("Expected %#v, got %#v", expected, roles["user"])
+	}
+	if _, err := ability.ParseRoles(map[string]interface{}{"user": []interface{}{map[string]interface{}{"action": "read"}}}); err == nil {
+		t.Errorf("Rules without subject should be rejected")
+	}
+}
+
+type messageService struct {
+	*feathers.BaseService
+	lastQuery map[string]interface{}
+}
+
+func (s *messageService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	// the query is returned so the scoping can be checked
+	s.lastQuery = params.Query
+	return []map[string]interface{}{
+		{"_id": "a", "userId": "1", "text": "own", "secret": "x"},
+		{"_id": "b", "userId": "2", "public": true, "text": "public", "secret": "y"},
+		{"_id": "query", "query": params.Query},
+	}, nil
+}
+
+func (s *messageService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return map[string]interface{}{"_id": id, "userId": "2", "text": "private"}, nil
+}
+
+func (s *messageService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return data, nil
+}
+
+func (s *messageService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return data, nil
+}
+
+func (s *messageService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return data, nil
+}
+
+func (s *messageService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, nil
+}
+
+func TestAuthorizeHook(t *testing.T) {
+	app := feathers.NewApp()
+	app.SetConfig("authorization", map[string]interface{}{"roleField": "roles"})
+	ability.Configure(app, testRoles)
+	service := &messageService{BaseService: &feathers.BaseService{}}
+	service.Hooks.Before.All = []feathers.Hook{ability.Authorize()}
+	service.Hooks.After.All = []feathers.Hook{ability.Authorize()}
+	app.AddService("messages", service)
+
+	params := func(user map[string]interface{}) feathers.Params {
+		params := feathers.NewParams()
+		params.Provider = "http"
+		params.User = user
+		return *params
+	}
+
+	result, err := feathers.ToMapSlice(app.Service("messages").Find(context.Background(), params(testUser)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result[0]["secret"] != "x" || result[1]["secret"] != nil || result[1]["text"] != "public" {
+		t.Errorf("Unreadable fields were not removed: %#v", result)
+	}
+	if _, ok := result[2]["query"]; ok {
+		t.Errorf("Record without read permission should only contain its id: %#v", result[2])
+	}
+	if _, ok := service.lastQuery["$and"]; !ok {
+		t.Errorf("Find query was not scoped: %#v", service.lastQuery)
+	}
+
+	_, err = app.Service("messages").Get(context.Background(), "c", params(testUser))
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 403 {
+		t.Errorf("Expected Forbidden for get of other users message, got: %v", err)
+	}
+	_, err = app.Service("messages").Find(context.Background(), params(nil))
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for guest, got: %v", err)
+	}
+	_, err = app.Service("messages").Create(context.Background(), map[string]interface{}{"userId": "2"}, params(testUser))
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 403 {
+		t.Errorf("Expected Forbidden for create for other user, got: %v", err)
+	}
+	_, err = app.Service("messages").Patch(context.Background(), "a", map[string]interface{}{"userId": "2"}, params(testUser))
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 403 {
+		t.Errorf("Expected Forbidden for patch of userId, got: %v", err)
+	}
+
+	admin := map[string]interface{}{"_id": "9", "roles": "admin"}
+	result, err = feathers.ToMapSlice(app.Service("messages").Find(context.Background(), params(admin)))
+	if err != nil || result[1]["secret"] != "y" {
+		t.Errorf("Admin should read all fields: %#v %v", result, err)
+	}
+	if query, _ := result[2]["query"].(map[string]interface{}); len(query) != 0 {
+		t.Errorf("Admin query should not be scoped: %#v", query)
+	}
+
+	if _, err := app.Service("messages").Get(context.Background(), "c", *feathers.NewParams()); err != nil {
+		t.Errorf("Internal calls should not be authorized: %s", err)
+	}
+}
+
+func TestAuthorizePatch(t *testing.T) {
+	app := feathers.NewApp()
+	ability.Configure(app, ability.Roles{
+		"user": {
+			ability.Can(ability.ActionManage, "posts", map[string]interface{}{"authorId": "${user._id}"}),
+		},
+	})
+	posts := memory.NewService(nil, nil)
+	posts.Multi = []feathers.RestMethod{feathers.Patch}
+	posts.Hooks.Before.All = []feathers.Hook{ability.Authorize()}
+	app.AddService("posts", posts)
+	for _, post := range []map[string]interface{}{
+		{"_id": "a", "authorId": "1", "text": "own"},
+		{"_id": "b", "authorId": "1", "text": "own"},
+		{"_id": "c", "authorId": "2", "text": "other"},
+	} {
+		if _, err := posts.Create(context.Background(), post, *feathers.NewParams()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	params := feathers.Params{Provider: "rest", User: testUser}
+
+	for key, test := range []struct {
+		id       string
+		data     map[string]interface{}
+		expected int
+	}{
+		/* #1 */ {"a", map[string]interface{}{"text": "changed"}, 0},
+		/* #2 */ {"a", map[string]interface{}{"authorId": "1"}, 0},
+		/* #3 */ {"a", map[string]interface{}{"authorId": "2"}, 403},
+		/* #4 */ {"", map[string]interface{}{"authorId": "2"}, 403},
+		/* #5 */ {"", map[string]interface{}{"text": "all"}, 0},
+		/* #6 */ {"c", map[string]interface{}{"text": "changed"}, 404},
+	} {
+		_, err := app.Service("posts").Patch(context.Background(), test.id, test.data, params)
+		code := 0
+		if featherErr, ok := err.(httperrors.FeathersError); ok {
+			code = featherErr.Code
+		} else if err != nil {
+			code = -1
+		}
+		if code != test.expected {
+			t.Errorf("Failed #%d: expected %d, got: %v", key+1, test.expected, err)
+		}
+	}
+
+	for _, id := range []string{"a", "b"} {
+		post, _ := posts.Get(context.Background(), id, *feathers.NewParams())
+		if post.(map[string]interface{})["authorId"] != "1" {
+			t.Errorf("Post %s was handed to another user: %#v", id, post)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package ability
+
+import (
+	"context"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/hooks"
+)
+
+// GuestRole is the role of external calls without authenticated user
+const GuestRole = "guest"
+
+type options struct {
+	// RoleField is the field of the user containing its roles (a string or a list of strings)
+	RoleField string
+	Roles     Roles
+}
+
+// Configure sets the roles used by Authorize (they can also be set with the `authorization.roles` config)
+func Configure(app *feathers.App, roles Roles) {
+	config := map[string]interface{}{}
+	if current, ok := app.Config("authorization"); ok {
+		if currentMap, ok := current.(map[string]interface{}); ok {
+			for key, value := range currentMap {
+				config[key] = value
+			}
+		}
+	}
+	config["roles"] = roles
+	app.SetConfig("authorization", config)
+}
+
+func loadOptions(app *feathers.App) (*options, error) {
+	opts := &options{RoleField: "roles"}
+	config, ok := app.Config("authorization")
+	if !ok {
+		return nil, httperrors.NewGeneralError("No authorization roles are configured", nil)
+	}
+	configMap, _ := config.(map[string]interface{})
+	if roleField, ok := configMap["roleField"].(string); ok {
+		opts.RoleField = roleField
+	}
+	roles, err := ParseRoles(configMap["roles"])
+	if err != nil {
+		return nil, httperrors.NewGeneralError("Invalid authorization roles: "+err.Error(), nil)
+	}
+	opts.Roles = roles
+	return opts, nil
+}
+
+// roleNames returns the roles of the caller: the role field of the user and the `permissions` param field (e.g. of api keys)
+func roleNames(ctx *feathers.Context, roleField string) []string {
+	names := []string{}
+	if ctx.Params.User != nil {
+		names = append(names, stringList(ctx.Params.User[roleField])...)
+	}
+	names = append(names, stringList(ctx.Params.Get("permissions"))...)
+	if len(names) == 0 && ctx.Params.User == nil {
+		names = append(names, GuestRole)
+	}
+	return names
+}
+
+func stringList(value interface{}) []string {
+	if str, ok := value.(string); ok {
+		return []string{str}
+	}
+	result := []string{}
+	for _, item := range toSlice(value) {
+		if str, ok := item.(string); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
+
+// FromContext returns the ability of the caller of a service call (it is stored in the `ability` param field)
+func FromContext(ctx *feathers.Context) (*Ability, error) {
+	if ability, ok := ctx.Params.Get("ability").(*Ability); ok {
+		return ability, nil
+	}
+	opts, err := loadOptions(&ctx.App)
+	if err != nil {
+		return nil, err
+	}
+	ability, err := New(opts.Roles, roleNames(ctx, opts.RoleField), ctx.Params.User)
+	if err != nil {
+		return nil, httperrors.NewGeneralError("Invalid authorization rules: "+err.Error(), nil)
+	}
+	ctx.Params.Set("ability", ability)
+	return ability, nil
+}
+
+// Authorize checks external calls against the rules of the roles of the caller (use as before and after hook, after AuthenticationHook).
+/*
+Before: calls which no rule allows fail with Forbidden (NotAuthenticated without user). Create and update data has to match
+the conditions, find, get, update, patch and remove are scoped to the permitted records by adding the conditions to the query.
+The patched records (the existing records overlaid with the data) have to match the conditions as well, so patch data can not
+move records out of the permitted records (e.g. change their owner). Data of create, update and patch must only contain writable fields.
+After: fields the caller may not read (find for find results, get otherwise) are removed from the result (`_id` is kept).
+Internal calls are not checked
+*/
+func Authorize() feathers.Hook {
+	return func(ctx *feathers.Context) error {
+		if ctx.Params.Provider == "" {
+			return nil
+		}
+		ability, err := FromContext(ctx)
+		if err != nil {
+			return err
+		}
+		if ctx.Type == feathers.Before {
+			return authorizeBefore(ctx, ability)
+		}
+		if ctx.Type == feathers.After {
+			return authorizeAfter(ctx, ability)
+		}
+		return nil
+	}
+}
+
+func forbidden(ctx *feathers.Context, rule *Rule) error {
+	if ctx.Params.User == nil {
+		return httperrors.NewNotAuthenticated("Not authenticated", nil)
+	}
+	if rule != nil && rule.Reason != "" {
+		return httperrors.NewForbidden(rule.Reason, nil)
+	}
+	return httperrors.NewForbidden("You are not allowed to "+ctx.Method.String()+" "+ctx.Path, nil)
+}
+
+func authorizeBefore(ctx *feathers.Context, ability *Ability) error {
+	action := ctx.Method.String()
+	if !ability.Can(action, ctx.Path) {
+		return forbidden(ctx, nil)
+	}
+
+	switch ctx.Method {
+	case feathers.Create, feathers.Update, feathers.Patch:
+		items, _ := hooks.GetItemsNormalized(ctx)
+		for _, item := range items {
+			if ctx.Method != feathers.Patch {
+				allowed, rule, err := ability.CanRecord(action, ctx.Path, item)
+				if err != nil {
+					return httperrors.NewGeneralError(err.Error(), nil)
+				}
+				if !allowed {
+					return forbidden(ctx, rule)
+				}
+			}
+			var record map[string]interface{}
+			if ctx.Method != feathers.Patch {
+				record = item
+			}
+			fields, err := ability.PermittedFields(action, ctx.Path, record)
+			if err != nil {
+				return httperrors.NewGeneralError(err.Error(), nil)
+			}
+			for field := range item {
+				if field != "_id" && !fields.Has(field) {
+					return httperrors.NewForbidden("You are not allowed to "+action+" the field "+field, nil)
+				}
+			}
+		}
+	}
+
+	if ctx.Method == feathers.Create || !isServiceMethod(ctx.Method) {
+		return nil
+	}
+	query, ok := ability.Query(action, ctx.Path)
+	if !ok {
+		return forbidden(ctx, nil)
+	}
+	scopeQuery(ctx, query)
+	if ctx.Method == feathers.Patch {
+		return authorizePatch(ctx, ability)
+	}
+	return nil
+}
+
+// authorizePatch checks the records resulting from the patch against the conditions. The records matching the scoped query
+// are loaded from the service class (without hooks) and overlaid with the patch data
+func authorizePatch(ctx *feathers.Context, ability *Ability) error {
+	service, ok := ctx.ServiceClass.(feathers.Service)
+	if !ok {
+		return httperrors.NewGeneralError("Service "+ctx.Path+" can not be authorized", nil)
+	}
+	query := make(map[string]interface{}, len(ctx.Params.Query))
+	for key, value := range ctx.Params.Query {
+		// the fields of the conditions are required
+		if key != "$select" && key != "$populate" {
+			query[key] = value
+		}
+	}
+	params := feathers.NewParamsFrom(&ctx.Params, feathers.WithQuery(query))
+	params.Set("paginate", false)
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	var records []map[string]interface{}
+	if ctx.ID != "" {
+		record, err := feathers.ToMap(service.Get(parent, ctx.ID, *params))
+		if err != nil {
+			return err
+		}
+		records = []map[string]interface{}{record}
+	} else {
+		var err error
+		records, err = feathers.ToMapSlice(service.Find(parent, *params))
+		if err != nil {
+			return httperrors.Convert(err)
+		}
+	}
+
+	for _, record := range records {
+		patched := make(map[string]interface{}, len(record)+len(ctx.Data))
+		for field, value := range record {
+			patched[field] = value
+		}
+		for field, value := range ctx.Data {
+			patched[field] = value
+		}
+		allowed, rule, err := ability.CanRecord(ctx.Method.String(), ctx.Path, patched)
+		if err != nil {
+			return httperrors.NewGeneralError(err.Error(), nil)
+		}
+		if !allowed {
+			return forbidden(ctx, rule)
+		}
+	}
+	return nil
+}
+
+// scopeQuery adds the conditions of query to the query of the call
+func scopeQuery(ctx *feathers.Context, query map[string]interface{}) {
+	conditions, ok := query["$and"].([]interface{})
+	if !ok || len(conditions) == 0 {
+		return
+	}
+	scoped := make(map[string]interface{}, len(ctx.Params.Query)+1)
+	for key, value := range ctx.Params.Query {
+		scoped[key] = value
+	}
+	if existing := toSlice(scoped["$and"]); existing != nil {
+		conditions = append(append([]interface{}{}, existing...), conditions...)
+	}
+	scoped["$and"] = conditions
+	ctx.Params.Query = scoped
+}
+
+func isServiceMethod(method feathers.RestMethod) bool {
+	switch method {
+	case feathers.Find, feathers.Get, feathers.Create, feathers.Update, feathers.Patch, feathers.Remove:
+		return true
+	}
+	return false
+}
+
+func authorizeAfter(ctx *feathers.Context, ability *Ability) error {
+	if !isServiceMethod(ctx.Method) {
+		return nil
+	}
+	action := "get"
+	if ctx.Method == feathers.Find {
+		action = "find"
+	}
+	items, normalized := hooks.GetItemsNormalized(ctx)
+	if len(items) == 0 {
+		return nil
+	}
+	result := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if ctx.Method == feathers.Get {
+			allowed, rule, err := ability.CanRecord(action, ctx.Path, item)
+			if err != nil {
+				return httperrors.NewGeneralError(err.Error(), nil)
+			}
+			if !allowed {
+				return forbidden(ctx, rule)
+			}
+		}
+		fields, err := ability.PermittedFields(action, ctx.Path, item)
+		if err != nil {
+			return httperrors.NewGeneralError(err.Error(), nil)
+		}
+		readable := make(map[string]interface{}, len(item))
+		for field, value := range item {
+			if field == "_id" || fields.Has(field) {
+				readable[field] = value
+			}
+		}
+		result = append(result, readable)
+	}
+	hooks.ReplaceItemsNormalized(ctx, result, normalized)
+	return nil
+}
@@ -0,0 +1,195 @@
+package ability
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type hexable interface {
+	Hex() string
+}
+
+// normalize converts values so values of different go types can be compared (numbers to float64, ids to strings)
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case hexable:
+		return v.Hex()
+	case time.Time:
+		return float64(v.UnixNano())
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+func equal(a interface{}, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// compare compares two numbers or two strings. ok is false if they cannot be compared
+func compare(a interface{}, b interface{}) (int, bool) {
+	switch x := normalize(a).(type) {
+	case float64:
+		y, ok := normalize(b).(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := normalize(b).(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+// toSlice returns the elements of a slice value (nil if value is not a slice)
+func toSlice(value interface{}) []interface{} {
+	if values, ok := value.([]interface{}); ok {
+		return values
+	}
+	reflected := reflect.ValueOf(value)
+	if !reflected.IsValid() || reflected.Kind() != reflect.Slice {
+		return nil
+	}
+	result := make([]interface{}, reflected.Len())
+	for i := range result {
+		result[i] = reflected.Index(i).Interface()
+	}
+	return result
+}
+
+// contains returns if value (or one element of value if it is a slice like mongo) equals one of values
+func contains(values []interface{}, value interface{}) bool {
+	candidates := toSlice(value)
+	if candidates == nil {
+		candidates = []interface{}{value}
+	}
+	for _, candidate := range candidates {
+		for _, item := range values {
+			if equal(candidate, item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matches returns if record matches conditions
+func matches(record map[string]interface{}, conditions map[string]interface{}) (bool, error) {
+	for key, condition := range conditions {
+		var ok bool
+		var err error
+		switch key {
+		case "$or", "$and", "$nor":
+			ok, err = matchesLogical(record, key, condition)
+		default:
+			value, exists := fieldValue(record, key)
+			ok, err = matchesField(value, exists, condition)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchesLogical(record map[string]interface{}, operator string, condition interface{}) (bool, error) {
+	list := toSlice(condition)
+	if list == nil {
+		return false, fmt.Errorf("%s has to be a list", operator)
+	}
+	for _, item := range list {
+		itemConditions, ok := item.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s has to be a list of conditions", operator)
+		}
+		ok, err := matches(record, itemConditions)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case operator == "$or" && ok:
+			return true, nil
+		case operator == "$and" && !ok:
+			return false, nil
+		case operator == "$nor" && ok:
+			return false, nil
+		}
+	}
+	return operator != "$or", nil
+}
+
+func matchesField(value interface{}, exists bool, condition interface{}) (bool, error) {
+	operators, ok := condition.(map[string]interface{})
+	if !ok || !isOperatorMap(operators) {
+		return exists && (equal(value, condition) || contains([]interface{}{condition}, value)), nil
+	}
+	for operator, operand := range operators {
+		var ok bool
+		switch operator {
+		case "$eq":
+			ok = exists && contains([]interface{}{operand}, value)
+		case "$ne":
+			ok = !exists || !contains([]interface{}{operand}, value)
+		case "$in":
+			ok = exists && contains(toSlice(operand), value)
+		case "$nin":
+			ok = !exists || !contains(toSlice(operand), value)
+		case "$lt", "$lte", "$gt", "$gte":
+			result, comparable := compare(value, operand)
+			ok = exists && comparable && ((operator == "$lt" && result < 0) ||
+				(operator == "$lte" && result <= 0) ||
+				(operator == "$gt" && result > 0) ||
+				(operator == "$gte" && result >= 0))
+		case "$exists":
+			expected, _ := operand.(bool)
+			ok = exists == expected
+		default:
+			return false, fmt.Errorf("Unsupported condition operator %s", operator)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func isOperatorMap(value map[string]interface{}) bool {
+	for key := range value {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return len(value) > 0
+}
@@ -0,0 +1,195 @@
+package ability
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// ActionManage matches all actions
+	ActionManage = "manage"
+	// ActionRead matches find and get
+	ActionRead = "read"
+	// SubjectAll matches all services
+	SubjectAll = "all"
+)
+
+// Rule allows (or if Inverted forbids) actions on subjects.
+/*
+Actions are service methods (find, get, create, update, patch, remove or custom methods), `read` (find and get) or `manage` (all).
+Subjects are service paths or `all`. Conditions are a query (equality, $in, $nin, $ne, $lt, $lte, $gt, $gte, $exists, $or, $and, $nor)
+the records have to match. String values like `${user._id}` are replaced by the field of the authenticated user.
+Fields restricts the rule to these record fields (readable fields for find and get, writable fields for create, update and patch)
+*/
+type Rule struct {
+	Action     []string               `mapstructure:"action"`
+	Subject    []string               `mapstructure:"subject"`
+	Conditions map[string]interface{} `mapstructure:"conditions"`
+	Fields     []string               `mapstructure:"fields"`
+	Inverted   bool                   `mapstructure:"inverted"`
+	// Reason is the message of the Forbidden error if the rule denies a call
+	Reason string `mapstructure:"reason"`
+}
+
+// Can creates a rule which allows action on subject for records matching conditions (nil matches all)
+func Can(action string, subject string, conditions map[string]interface{}, fields ...string) Rule {
+	return Rule{
+		Action:     []string{action},
+		Subject:    []string{subject},
+		Conditions: conditions,
+		Fields:     fields,
+	}
+}
+
+// Cannot creates a rule which forbids action on subject for records matching conditions (nil matches all)
+func Cannot(action string, subject string, conditions map[string]interface{}, fields ...string) Rule {
+	rule := Can(action, subject, conditions, fields...)
+	rule.Inverted = true
+	return rule
+}
+
+// Roles are the rules of each role. Later rules take precedence over earlier rules
+type Roles map[string][]Rule
+
+func (r Rule) matchesAction(action string) bool {
+	for _, ruleAction := range r.Action {
+		if ruleAction == action || ruleAction == ActionManage {
+			return true
+		}
+		if ruleAction == ActionRead && (action == "find" || action == "get" || action == ActionRead) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesSubject(subject string) bool {
+	for _, ruleSubject := range r.Subject {
+		if ruleSubject == subject || ruleSubject == SubjectAll {
+			return true
+		}
+	}
+	return false
+}
+
+// errUnresolved is returned for templates whose user field does not exist
+var errUnresolved = errors.New("template cannot be resolved")
+
+// resolve replaces the `${user.<field>}` templates of the conditions with the fields of user.
+/*
+It returns false if a template cannot be resolved (e.g. without user). The rule cannot apply in that case
+*/
+func (r Rule) resolve(user map[string]interface{}) (Rule, bool, error) {
+	if len(r.Conditions) == 0 {
+		return r, true, nil
+	}
+	conditions, err := resolveTemplates(r.Conditions, user)
+	if err == errUnresolved {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, err
+	}
+	r.Conditions = conditions.(map[string]interface{})
+	return r, true, nil
+}
+
+func resolveTemplates(value interface{}, user map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := resolveTemplates(item, user)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = resolved
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := resolveTemplates(item, user)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = resolved
+		}
+		return result, nil
+	case string:
+		if !strings.HasPrefix(v, "${") || !strings.HasSuffix(v, "}") {
+			return v, nil
+		}
+		path := strings.TrimSuffix(strings.TrimPrefix(v, "${"), "}")
+		if !strings.HasPrefix(path, "user.") {
+			return nil, fmt.Errorf("Unknown template %s", v)
+		}
+		field, ok := fieldValue(user, strings.TrimPrefix(path, "user."))
+		if !ok {
+			return nil, errUnresolved
+		}
+		return field, nil
+	}
+	return value, nil
+}
+
+// fieldValue returns the value of a dot separated path in record
+func fieldValue(record map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = record
+	for _, segment := range strings.Split(path, ".") {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = currentMap[segment]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// ParseRoles decodes roles from configuration (`{"<role>": [{"action": "read", "subject": "messages", ...}]}`).
+/*
+action, subject and fields can be a string or a list of strings
+*/
+func ParseRoles(config interface{}) (Roles, error) {
+	if roles, ok := config.(Roles); ok {
+		return roles, nil
+	}
+	configMap, ok := config.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("roles have to be a map of rule lists")
+	}
+	roles := Roles{}
+	for role, rawRules := range configMap {
+		if rules, ok := rawRules.([]Rule); ok {
+			roles[role] = rules
+			continue
+		}
+		list, ok := rawRules.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("rules of role %s have to be a list", role)
+		}
+		for key, rawRule := range list {
+			rule := Rule{}
+			decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+				WeaklyTypedInput: true,
+				Result:           &rule,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := decoder.Decode(rawRule); err != nil {
+				return nil, fmt.Errorf("rule %d of role %s: %s", key+1, role, err)
+			}
+			if len(rule.Action) == 0 || len(rule.Subject) == 0 {
+				return nil, fmt.Errorf("rule %d of role %s needs action and subject", key+1, role)
+			}
+			roles[role] = append(roles[role], rule)
+		}
+	}
+	return roles, nil
+}
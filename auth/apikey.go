@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// ApiKey is a static api key of the apiKey strategy configuration
+type ApiKey struct {
+	Key string `mapstructure:"key"`
+	// Name identifies the caller (it is the `_id` and `name` of the entity, required)
+	Name        string   `mapstructure:"name"`
+	Permissions []string `mapstructure:"permissions"`
+}
+
+type apiKeyStrategyConfig struct {
+	// Header is the header which contains the key
+	Header string `mapstructure:"header" default:"x-api-key"`
+	// Query is the query parameter which contains the key (disabled if empty)
+	Query string `mapstructure:"query"`
+	// Keys are the allowed static keys
+	Keys []ApiKey `mapstructure:"keys"`
+	// Service is the entity service which is searched for keys not found in Keys (disabled if empty)
+	Service string `mapstructure:"service"`
+	// KeyField is the field of the entity containing the hash of the key (see HashApiKey)
+	KeyField string `mapstructure:"keyField" default:"key"`
+	// PermissionsField is the field of the entity containing the permissions
+	PermissionsField string `mapstructure:"permissionsField" default:"permissions"`
+}
+
+// ApiKeyStrategy authenticates service to service calls with api keys (`X-Api-Key: <key>` or `{"strategy": "apiKey", "apiKey": "<key>"}`).
+/*
+Keys are checked against the static `keys` of the strategy configuration and the entity service `service`.
+The entity service stores the keys hashed (see HashApiKey), it is searched for the hash of the sent key.
+The entity of the key becomes `Params.User` and its permissions are stored in the `permissions` param field.
+No access token is created, the key has to be sent with every request (sockets authenticate once)
+*/
+type ApiKeyStrategy struct {
+	*BaseAuthStrategy
+}
+
+// Tokenless marks that no access token is created for api keys
+func (s *ApiKeyStrategy) Tokenless() {}
+
+// Parse reads the key from the configured header or query parameter. The query parameter is removed from the query
+func (s *ApiKeyStrategy) Parse(params feathers.Params) (*Model, error) {
+	config := apiKeyStrategyConfig{}
+	s.StrategyConfig(&config)
+	key := strings.TrimSpace(params.Headers[strings.ToLower(config.Header)])
+	if key == "" && config.Query != "" {
+		if queryKey, ok := params.Query[config.Query].(string); ok {
+			key = queryKey
+			delete(params.Query, config.Query)
+		}
+	}
+	if key == "" {
+		return nil, nil
+	}
+	return &Model{
+		Strategy: s.name,
+		Params: map[string]interface{}{
+			"apiKey": key,
+		},
+	}, nil
+}
+
+// HashApiKey returns the hash of key which is stored in the `keyField` of the entities of the apiKey strategy service
+func HashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// staticEntity returns the entity of a configured key. Keys without name are rejected, their entities would have no id
+func (s *ApiKeyStrategy) staticEntity(config apiKeyStrategyConfig, key string) (map[string]interface{}, []string, bool, error) {
+	for _, apiKey := range config.Keys {
+		if apiKey.Name == "" {
+			return nil, nil, false, httperrors.NewGeneralError("Api keys of strategy "+s.name+" require a name", nil)
+		}
+	}
+	for _, apiKey := range config.Keys {
+		if apiKey.Key != "" && subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) == 1 {
+			entity := map[string]interface{}{
+				"_id":         apiKey.Name,
+				"name":        apiKey.Name,
+				"permissions": apiKey.Permissions,
+			}
+			return entity, apiKey.Permissions, true, nil
+		}
+	}
+	return nil, nil, false, nil
+}
+
+// serviceEntity looks up the entity of key in the configured service by the hash of the key
+func (s *ApiKeyStrategy) serviceEntity(ctx context.Context, config apiKeyStrategyConfig, key string) (map[string]interface{}, []string, error) {
+	service := s.app.Service(config.Service)
+	if service == nil {
+		return nil, nil, errors.New("Api key service " + config.Service + " is not registered")
+	}
+	findParams := feathers.NewParamsQuery(map[string]interface{}{config.KeyField: HashApiKey(key)})
+	findParams.Set("paginate", false)
+	entities, err := feathers.ToMapSlice(service.Find(ctx, *findParams))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entities) == 0 {
+		return nil, nil, nil
+	}
+	entity := entities[0]
+	permissions := []string{}
+	switch list := entity[config.PermissionsField].(type) {
+	case []string:
+		permissions = list
+	case []interface{}:
+		for _, permission := range list {
+			if permissionString, ok := permission.(string); ok {
+				permissions = append(permissions, permissionString)
+			}
+		}
+	}
+	return entity, permissions, nil
+}
+
+func (s *ApiKeyStrategy) Authenticate(ctx context.Context, data Model, params feathers.Params) (map[string]interface{}, error) {
+	config := apiKeyStrategyConfig{}
+	s.StrategyConfig(&config)
+	defaultConfig := s.DefaultConfig()
+	key, ok := data.Params["apiKey"].(string)
+	if !ok || key == "" {
+		return nil, httperrors.NewNotAuthenticated("No apiKey sent", nil)
+	}
+
+	entity, permissions, found, err := s.staticEntity(config, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found && config.Service != "" {
+		entity, permissions, err = s.serviceEntity(ctx, config, key)
+		if err != nil {
+			return nil, err
+		}
+		found = entity != nil
+	}
+	if !found {
+		return nil, httperrors.NewNotAuthenticated("Invalid api key", nil)
+	}
+
+	result := map[string]interface{}{
+		"authentication": map[string]interface{}{
+			"strategy": s.name,
+		},
+		"permissions": permissions,
+	}
+	result[defaultConfig.Entity] = entity
+	return result, nil
+}
+
+func NewApiKeyStrategy() *ApiKeyStrategy {
+	return &ApiKeyStrategy{
+		BaseAuthStrategy: &BaseAuthStrategy{},
+	}
+}
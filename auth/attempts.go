@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempts are the consecutive attempts of a key (e.g. the logins of a username) without success. Attempts are recorded
+// before they are checked, so attempts which are still running are counted as well
+type Attempts struct {
+	Count       int64
+	LastAttempt time.Time
+}
+
+// AttemptStore tracks attempts of authentication strategies (e.g. logins of the local strategy or two-factor codes)
+type AttemptStore interface {
+	// Get returns the attempts of key
+	Get(ctx context.Context, key string) (Attempts, error)
+	// Attempt atomically records an attempt of key if allow returns true for its current attempts. It returns the
+	// attempts (including the new one if it was recorded) and if the attempt was recorded.
+	// Attempts are forgotten window after the last attempt
+	Attempt(ctx context.Context, key string, window time.Duration, allow func(Attempts) bool) (Attempts, bool, error)
+	// Reset clears the attempts of key
+	Reset(ctx context.Context, key string) error
+}
+
+type memoryAttempts struct {
+	Attempts
+	expiresAt time.Time
+}
+
+// MemoryAttemptStore is an AttemptStore which keeps attempts in memory (use NewMemoryAttemptStore).
+/*
+Attempts are not shared between multiple server instances (use redis.AttemptStore for that)
+*/
+type MemoryAttemptStore struct {
+	attempts map[string]memoryAttempts
+	lock     sync.Mutex
+}
+
+// Get returns the attempts of key
+func (s *MemoryAttemptStore) Get(ctx context.Context, key string) (Attempts, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if attempts, ok := s.attempts[key]; ok && attempts.expiresAt.After(time.Now()) {
+		return attempts.Attempts, nil
+	}
+	return Attempts{}, nil
+}
+
+// Attempt records an attempt of key if allow returns true for its current attempts
+func (s *MemoryAttemptStore) Attempt(ctx context.Context, key string, window time.Duration, allow func(Attempts) bool) (Attempts, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for attemptKey, attempts := range s.attempts {
+		if !attempts.expiresAt.After(now) {
+			delete(s.attempts, attemptKey)
+		}
+	}
+	attempts := s.attempts[key]
+	if !allow(attempts.Attempts) {
+		return attempts.Attempts, false, nil
+	}
+	attempts.Count++
+	attempts.LastAttempt = now
+	attempts.expiresAt = now.Add(window)
+	s.attempts[key] = attempts
+	return attempts.Attempts, true, nil
+}
+
+// Reset clears the attempts of key
+func (s *MemoryAttemptStore) Reset(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// NewMemoryAttemptStore creates a new in memory attempt store
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{
+		attempts: map[string]memoryAttempts{},
+	}
+}
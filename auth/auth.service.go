@@ -3,7 +3,6 @@ package auth
 import (
 	"context"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/gbrlsnchs/jwt/v3"
@@ -14,27 +13,26 @@ import (
 	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
 )
 
-type hexable interface {
-	Hex() string
-}
-
 type jwtToken struct {
-	Issuer         string       `json:"iss,omitempty" mapstructure:"issuer,omitempty"`
-	Subject        string       `json:"sub,omitempty" mapstructure:"subject,omitempty"`
-	Audience       jwt.Audience `json:"aud,omitempty" mapstructure:"audience,omitempty"`
-	ExpirationTime *jwt.Time    `json:"exp,omitempty"`
-	NotBefore      *jwt.Time    `json:"nbf,omitempty"`
-	IssuedAt       *jwt.Time    `json:"iat,omitempty"`
-	JWTID          string       `json:"jti,omitempty"`
+	jwt.Payload `mapstructure:",squash"`
+	// TokenType is empty for access tokens and `refresh` for refresh tokens
+	TokenType string `json:"tokenType,omitempty" mapstructure:"-"`
+	// Family identifies all refresh tokens which were rotated from the same login
+	Family string `json:"family,omitempty" mapstructure:"-"`
 }
 
+// DefaultExpiresIn is the lifetime of access tokens if `jwtOptions.expiresIn` is not configured
+const DefaultExpiresIn = "1d"
+
 type AuthService struct {
 	*feathers.BaseService
 	*feathers.ModelService
-	app            *feathers.App
-	encryption     *jwt.HMACSHA
-	config         map[string]interface{}
-	authStrategies map[string]AuthStrategy
+	// RevocationStore stores tokens revoked by logout (defaults to a MemoryRevocationStore, nil disables revocation)
+	RevocationStore RevocationStore
+	app             *feathers.App
+	keys            *keySet
+	config          map[string]interface{}
+	authStrategies  map[string]AuthStrategy
 }
 
 func tokenType(tpe string) jwt.SignOption {
@@ -54,35 +52,211 @@ func (as *AuthService) Create(ctx context.Context, data map[string]interface{},
 		if err != nil {
 			return nil, httperrors.Convert(err)
 		}
+		if _, partial := result["partialToken"]; partial {
+			// the second factor is missing (see SecondFactor)
+			return result, nil
+		}
+
+		_, tokenless := strategy.(TokenlessStrategy)
+		if _, ok := result["accessToken"]; !ok && !tokenless {
+			// access tokens carry the refresh token family of the login, so logout can revoke it
+			refresh, refreshable := as.refreshStrategy()
+			family := ""
+			if refreshable && refresh == strategy {
+				authentication, _ := result["authentication"].(map[string]interface{})
+				family, _ = authentication["family"].(string)
+			} else if refreshable {
+				family = Uuid4()
+			}
+			token, decoded, err := as.createAccessTokenOfFamily(result, family)
+			if err != nil {
+				return nil, httperrors.Convert(err)
+			}
+
+			result["accessToken"] = token
+			result["authentication"] = map[string]interface{}{
+				"accessToken": token,
+				"payload":     decoded,
+			}
+
+			if refreshable && refresh != strategy {
+				refreshToken, err := refresh.issue(ctx, as, result, family, "")
+				if err != nil {
+					return nil, httperrors.Convert(err)
+				}
+				result["refreshToken"] = refreshToken
+			}
+		}
 
 		if params.IsSocket && params.Connection != nil {
-			defaultConfig := as.DefaultConfig()
-			params.Connection.SetAuthEntity(result[defaultConfig.Entity])
-			as.app.Emit("login", params.Connection)
+			as.authenticateConnection(params.Connection, model.Strategy, result)
 		}
 
-		if _, ok := result["accessToken"]; ok {
-			return result, nil
+		return result, nil
+	}
+	return nil, httperrors.NewGeneralError("Strategy "+model.Strategy+" not registered", nil)
+}
+
+// authenticateConnection stores the authentication result on a socket connection.
+/*
+An existing authentication is replaced. The connection expires with the access token: depending on `socketExpiry`
+(`deauthenticate` (default) or `disconnect`) its entity is cleared or the socket is disconnected.
+Emits `login` (first authentication), `reauthenticate` (replaced authentication) and `expired` with the connection
+*/
+func (as *AuthService) authenticateConnection(connection feathers.Connection, strategy string, result map[string]interface{}) {
+	defaultConfig := as.DefaultConfig()
+	reauthenticate := connection.IsAuthenticated()
+	connection.SetAuthEntity(result[defaultConfig.Entity])
+	if authConnection, ok := connection.(feathers.AuthenticationConnection); ok {
+		authentication := map[string]interface{}{
+			"strategy":    strategy,
+			"accessToken": result["accessToken"],
 		}
-		token, decoded, err := as.createAccessToken(result)
-		if err != nil {
-			return nil, httperrors.Convert(err)
+		if permissions, ok := result["permissions"]; ok {
+			authentication["permissions"] = permissions
 		}
+		authConnection.SetAuthentication(authentication)
+	}
+	if expiring, ok := connection.(feathers.ExpiringConnection); ok {
+		expiring.SetAuthExpiry(resultExpiresAt(result), func() {
+			as.expireConnection(connection)
+		})
+	}
+	if reauthenticate {
+		as.app.Emit("reauthenticate", connection)
+	} else {
+		as.app.Emit("login", connection)
+	}
+}
 
-		result["accessToken"] = token
-		result["authentication"] = map[string]interface{}{
-			"accessToken": token,
-			"payload":     decoded,
+// expireConnection clears the authentication of connection when its access token expired
+func (as *AuthService) expireConnection(connection feathers.Connection) {
+	expiring, ok := connection.(feathers.ExpiringConnection)
+	if !ok || expiring.AuthExpiresAt().IsZero() || expiring.AuthExpiresAt().After(time.Now()) {
+		// re-authenticated or logged out in the meantime
+		return
+	}
+	clearConnection(connection)
+	as.app.Emit("expired", connection)
+	if socketExpiry, _ := as.config["socketExpiry"].(string); socketExpiry == "disconnect" {
+		if disconnectable, ok := connection.(feathers.DisconnectableConnection); ok {
+			disconnectable.Disconnect()
 		}
+	}
+}
 
-		return result, nil
+// clearConnection removes the authentication of connection
+func clearConnection(connection feathers.Connection) {
+	connection.SetAuthEntity(nil)
+	if authConnection, ok := connection.(feathers.AuthenticationConnection); ok {
+		authConnection.SetAuthentication(nil)
 	}
-	return nil, httperrors.NewGeneralError("Strategy "+model.Strategy+" not registered", nil)
+	if expiring, ok := connection.(feathers.ExpiringConnection); ok {
+		expiring.SetAuthExpiry(time.Time{}, nil)
+	}
+}
+
+// resultExpiresAt returns the expiry of the access token of an authentication result (zero if it has none)
+func resultExpiresAt(result map[string]interface{}) time.Time {
+	authentication, _ := result["authentication"].(map[string]interface{})
+	var payload *jwtToken
+	switch value := authentication["payload"].(type) {
+	case jwtToken:
+		payload = &value
+	case *jwtToken:
+		payload = value
+	}
+	if payload == nil || payload.ExpirationTime == nil {
+		return time.Time{}
+	}
+	return payload.ExpirationTime.Time
+}
+
+// refreshStrategy returns the registered refresh strategy
+func (as *AuthService) refreshStrategy() (*RefreshStrategy, bool) {
+	for _, strategy := range as.authStrategies {
+		if refresh, ok := strategy.(*RefreshStrategy); ok {
+			return refresh, true
+		}
+	}
+	return nil, false
 }
 
-// Remove TODO Add implementation
+// Remove logs out (similar to feathers `app.logout()`).
+/*
+The access token is taken from id, the `authentication` param field, the authorization header or the socket connection.
+It is validated and revoked until it expires, the refresh tokens of its login are revoked as well.
+The entity of socket connections is cleared and a `logout` event is emitted
+*/
 func (as *AuthService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
-	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+	token := as.logoutToken(id, params)
+	if token == "" {
+		return nil, httperrors.NewNotAuthenticated("No access token", nil)
+	}
+	payload, err := as.verifyAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if as.RevocationStore != nil && payload.JWTID != "" {
+		expiresAt := time.Now().Add(24 * time.Hour)
+		if payload.ExpirationTime != nil {
+			expiresAt = payload.ExpirationTime.Time
+		}
+		if err := as.RevocationStore.Revoke(ctx, payload.JWTID, expiresAt); err != nil {
+			return nil, httperrors.Convert(err)
+		}
+	}
+	if refresh, ok := as.refreshStrategy(); ok && payload.Family != "" {
+		if err := refresh.Store.RevokeFamily(ctx, payload.Family); err != nil {
+			return nil, httperrors.Convert(err)
+		}
+	}
+
+	if params.Connection != nil {
+		clearConnection(params.Connection)
+	}
+
+	result := map[string]interface{}{
+		"accessToken": token,
+		"authentication": map[string]interface{}{
+			"strategy":    "jwt",
+			"accessToken": token,
+			"payload":     *payload,
+		},
+	}
+	if params.Connection != nil {
+		as.app.Emit("logout", params.Connection)
+	} else {
+		as.app.Emit("logout", result)
+	}
+	return result, nil
+}
+
+// logoutToken returns the access token which should be logged out
+func (as *AuthService) logoutToken(id string, params feathers.Params) string {
+	if id != "" {
+		return id
+	}
+	if authentication, ok := params.Get("authentication").(map[string]interface{}); ok {
+		if token, ok := authentication["accessToken"].(string); ok && token != "" {
+			return token
+		}
+	}
+	for _, strategy := range as.authStrategies {
+		if parser, ok := strategy.(*JwtStrategy); ok {
+			if model, err := parser.Parse(params); err == nil && model != nil {
+				if token, ok := model.Params["accessToken"].(string); ok {
+					return token
+				}
+			}
+		}
+	}
+	if connection, ok := params.Connection.(feathers.AuthenticationConnection); ok && connection.Authentication() != nil {
+		if token, ok := connection.Authentication()["accessToken"].(string); ok {
+			return token
+		}
+	}
+	return ""
 }
 
 func (as *AuthService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
@@ -103,10 +277,11 @@ func (as *AuthService) Update(ctx context.Context, id string, data map[string]in
 
 func NewAuthService(app *feathers.App, strategies map[string]AuthStrategy) *AuthService {
 	service := &AuthService{
-		app:            app,
-		BaseService:    &feathers.BaseService{},
-		ModelService:   feathers.NewModelService(NewModel),
-		authStrategies: strategies,
+		app:             app,
+		BaseService:     &feathers.BaseService{},
+		ModelService:    feathers.NewModelService(NewModel),
+		RevocationStore: NewMemoryRevocationStore(),
+		authStrategies:  strategies,
 	}
 	if appConfig, ok := app.Config("authentication"); ok {
 		appMapConfig := appConfig.(map[string]interface{})
@@ -115,8 +290,15 @@ func NewAuthService(app *feathers.App, strategies map[string]AuthStrategy) *Auth
 			strategy.SetConfiguration(appMapConfig)
 			strategy.SetApp(app)
 			strategy.SetName(key)
+			if setupable, ok := strategy.(feathers.Setupable); ok {
+				setupable.Setup(app)
+			}
 		}
-		service.encryption = jwt.NewHS256([]byte(service.config["secret"].(string)))
+		keys, err := newKeySet(appMapConfig)
+		if err != nil {
+			panic("Invalid authentication keys: " + err.Error())
+		}
+		service.keys = keys
 	} else {
 		panic("No app configuration of auth is set")
 	}
@@ -140,39 +322,83 @@ func (as *AuthService) DefaultConfig() DefaultAuthConfig {
 	return config
 }
 
+// tokenClaims are the claims which differ between access and refresh tokens
+type tokenClaims struct {
+	expiresIn time.Duration
+	tokenType string
+	family    string
+}
+
+// expiresIn returns the lifetime of access tokens (`jwtOptions.expiresIn`)
+func (as *AuthService) expiresIn() (time.Duration, error) {
+	if jwtConfig, ok := as.config["jwtOptions"].(map[string]interface{}); ok {
+		if expiresIn, ok := jwtConfig["expiresIn"]; ok {
+			return ParseDuration(expiresIn)
+		}
+	}
+	return ParseDuration(DefaultExpiresIn)
+}
+
 func (as *AuthService) createAccessToken(payload interface{}) (string, *jwtToken, error) {
+	return as.createAccessTokenOfFamily(payload, "")
+}
+
+// createAccessTokenOfFamily creates an access token of a login with the refresh token family
+func (as *AuthService) createAccessTokenOfFamily(payload interface{}, family string) (string, *jwtToken, error) {
+	expiresIn, err := as.expiresIn()
+	if err != nil {
+		return "", nil, err
+	}
+	return as.createToken(payload, tokenClaims{expiresIn: expiresIn, family: family})
+}
+
+// familyRevocation is the key of a revoked token family in the RevocationStore
+func familyRevocation(family string) string {
+	return "family:" + family
+}
+
+// revokeFamily revokes all access tokens of the login family until the last of them expires
+func (as *AuthService) revokeFamily(ctx context.Context, family string) error {
+	if as.RevocationStore == nil || family == "" {
+		return nil
+	}
+	expiresIn, err := as.expiresIn()
+	if err != nil {
+		return err
+	}
+	return as.RevocationStore.Revoke(ctx, familyRevocation(family), time.Now().Add(expiresIn))
+}
+
+func (as *AuthService) createToken(payload interface{}, claims tokenClaims) (string, *jwtToken, error) {
 	now := time.Now()
 	defaultConfig := as.DefaultConfig()
 	if entityKey, err := lookup.LookupString(payload, defaultConfig.Entity+"._id"); err == nil {
 		if jwtConfig, ok := as.config["jwtOptions"]; ok {
 
-			var stringKey string
-			switch key := entityKey.Interface().(type) {
-			case string:
-				stringKey = key
-			case hexable:
-				stringKey = key.Hex()
-			case fmt.Stringer:
-				stringKey = key.String()
-			default:
-				return "", nil, errors.New("Cannot strinigy entity key")
+			stringKey, err := entityID(entityKey.Interface())
+			if err != nil {
+				return "", nil, err
 			}
 
 			payload := jwtToken{
-				ExpirationTime: jwt.NumericDate(now.Add(24 * time.Hour)),
-				NotBefore:      jwt.NumericDate(now),
-				IssuedAt:       jwt.NumericDate(now),
-				Subject:        stringKey,
-				JWTID:          Uuid4(),
+				Payload: jwt.Payload{
+					ExpirationTime: jwt.NumericDate(now.Add(claims.expiresIn)),
+					NotBefore:      jwt.NumericDate(now),
+					IssuedAt:       jwt.NumericDate(now),
+					Subject:        stringKey,
+					JWTID:          Uuid4(),
+				},
 			}
 			mapstructure.Decode(jwtConfig, &payload)
+			payload.TokenType = claims.tokenType
+			payload.Family = claims.family
 			var tkTypeS string
 			if tkType, err := lookup.Lookup(jwtConfig, "header.typ"); err != nil {
 				tkTypeS = "access"
 			} else {
 				tkTypeS = tkType.String()
 			}
-			token, err := jwt.Sign(payload, as.encryption, tokenType(tkTypeS))
+			token, err := jwt.Sign(payload, as.keys.signing, append(as.keys.signOptions(), tokenType(tkTypeS))...)
 			if err != nil {
 				return "", nil, err
 			}
@@ -184,6 +410,56 @@ func (as *AuthService) createAccessToken(payload interface{}) (string, *jwtToken
 	}
 }
 
+// verifyAccessToken validates the signature and claims (iat, exp, iss) of token and checks that it was not revoked
+func (as *AuthService) verifyAccessToken(ctx context.Context, token string) (*jwtToken, error) {
+	return as.verifyToken(ctx, token, "")
+}
+
+// verifyToken verifies a token of tokenType (see verifyAccessToken)
+func (as *AuthService) verifyToken(ctx context.Context, token string, tokenType string) (*jwtToken, error) {
+	jwtConfig, ok := as.config["jwtOptions"]
+	if !ok {
+		return nil, httperrors.NewGeneralError("no jwt configuration given", nil)
+	}
+	now := time.Now()
+	defaultPayload := jwtToken{}
+	mapstructure.Decode(jwtConfig, &defaultPayload)
+	defaults.SetDefaults(&defaultPayload)
+
+	var payload jwtToken
+	iatValidator := jwt.IssuedAtValidator(now)
+	expValidator := jwt.ExpirationTimeValidator(now)
+	issValidator := jwt.IssuerValidator(defaultPayload.Issuer)
+	validatePayload := jwt.ValidatePayload(&payload.Payload, iatValidator, expValidator, issValidator)
+	if _, err := jwt.Verify([]byte(token), as.keys.verifier(), &payload, validatePayload); err != nil {
+		return nil, httperrors.NewNotAuthenticated(err.Error(), nil)
+	}
+	if payload.TokenType != tokenType {
+		return nil, httperrors.NewNotAuthenticated("Invalid token type", nil)
+	}
+
+	if as.RevocationStore != nil {
+		// tokens are revoked by their id or with their family (see revokeFamily)
+		keys := []string{}
+		if payload.JWTID != "" {
+			keys = append(keys, payload.JWTID)
+		}
+		if payload.Family != "" {
+			keys = append(keys, familyRevocation(payload.Family))
+		}
+		for _, key := range keys {
+			revoked, err := as.RevocationStore.IsRevoked(ctx, key)
+			if err != nil {
+				return nil, httperrors.Convert(err)
+			}
+			if revoked {
+				return nil, httperrors.NewNotAuthenticated("Token has been revoked", nil)
+			}
+		}
+	}
+	return &payload, nil
+}
+
 // const authStrategies = params.authStrategies || this.configuration.authStrategies;
 
 // if (!authStrategies.length) {
@@ -21,6 +21,22 @@ type AuthStrategy interface {
 	SetConfiguration(config map[string]interface{})
 }
 
+// AuthParser is implemented by strategies which can read authentication information from request params (e.g. headers)
+/*
+Parse returns nil if the params do not contain authentication information for the strategy
+*/
+type AuthParser interface {
+	Parse(params feathers.Params) (*Model, error)
+}
+
+// TokenlessStrategy is implemented by strategies whose credentials are sent with every request (e.g. api keys).
+/*
+The authentication service does not create access tokens for them
+*/
+type TokenlessStrategy interface {
+	Tokenless()
+}
+
 type BaseAuthStrategy struct {
 	app    *feathers.App
 	config map[string]interface{}
@@ -35,6 +51,16 @@ func (bas *BaseAuthStrategy) SetName(name string) {
 	bas.name = name
 }
 
+// Name returns the name the strategy is registered with
+func (bas *BaseAuthStrategy) Name() string {
+	return bas.name
+}
+
+// App returns the app the strategy is registered on
+func (bas *BaseAuthStrategy) App() *feathers.App {
+	return bas.app
+}
+
 func (bas *BaseAuthStrategy) SetConfiguration(config map[string]interface{}) {
 	bas.config = config
 }
@@ -46,12 +72,17 @@ func (bas *BaseAuthStrategy) Config(key string) (interface{}, bool) {
 	return nil, false
 }
 
+// StrategyConfig decodes the configuration of the strategy (key is the strategy name) into config.
+/*
+Defaults are applied even if the strategy is not configured (an error is returned in that case)
+*/
 func (bas *BaseAuthStrategy) StrategyConfig(config interface{}) error {
 	if configMap, ok := bas.Config(bas.name); ok {
 		mapstructure.Decode(configMap, config)
 		defaults.SetDefaults(config)
 		return nil
 	}
+	defaults.SetDefaults(config)
 	return errors.New("Key " + bas.name + " is not set")
 }
 
@@ -66,6 +97,15 @@ func (bas *BaseAuthStrategy) EntityService() (feathers.Service, bool) {
 	return nil, false
 }
 
+// AuthService returns the authentication service of the app the strategy is registered on
+func (bas *BaseAuthStrategy) AuthService() (*AuthService, bool) {
+	if bas.app == nil {
+		return nil, false
+	}
+	service, ok := bas.app.ServiceClass("authentication").(*AuthService)
+	return service, ok
+}
+
 func (bas *BaseAuthStrategy) DefaultConfig() DefaultAuthConfig {
 	config := DefaultAuthConfig{}
 	mapstructure.Decode(bas.config, &config)
@@ -0,0 +1,541 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+type testUserService struct {
+	*feathers.BaseService
+	users map[string]map[string]interface{}
+}
+
+func (s *testUserService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	result := []map[string]interface{}{}
+	for _, user := range s.users {
+		matches := true
+		for key, value := range params.Query {
+			if user[key] != value {
+				matches = false
+			}
+		}
+		if matches {
+			result = append(result, user)
+		}
+	}
+	return result, nil
+}
+
+func (s *testUserService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	if user, ok := s.users[id]; ok {
+		return user, nil
+	}
+	return nil, httperrors.NewNotFound("User not found")
+}
+
+func (s *testUserService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	s.users[data["_id"].(string)] = data
+	return data, nil
+}
+
+func (s *testUserService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	s.users[id] = data
+	return data, nil
+}
+
+func (s *testUserService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, httperrors.NewNotFound("User not found")
+	}
+	for key, value := range data {
+		user[key] = value
+	}
+	return user, nil
+}
+
+func (s *testUserService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	user := s.users[id]
+	delete(s.users, id)
+	return user, nil
+}
+
+func newTestApp(t *testing.T) (*feathers.App, *AuthService) {
+	return newTestAppStrategies(t, map[string]AuthStrategy{
+		"jwt": NewJwtStrategy(),
+	})
+}
+
+func newTestAppStrategies(t *testing.T, strategies map[string]AuthStrategy) (*feathers.App, *AuthService) {
+	app := feathers.NewApp()
+	app.SetConfig("authentication", map[string]interface{}{
+		"secret":  "supersecret",
+		"entity":  "user",
+		"service": "users",
+		"jwtOptions": map[string]interface{}{
+			"issuer": "feathers-go",
+		},
+	})
+	app.AddService("users", &testUserService{
+		BaseService: &feathers.BaseService{},
+		users: map[string]map[string]interface{}{
+			"1": {"_id": "1", "email": "test@example.com"},
+		},
+	})
+	err := Configure(app, map[string]interface{}{
+		"strategies": strategies,
+	})
+	if err != nil {
+		t.Fatalf("Could not configure authentication: %s", err)
+	}
+	return app, app.ServiceClass("authentication").(*AuthService)
+}
+
+// userParamsService returns the authenticated user of a call
+type userParamsService struct {
+	*testUserService
+}
+
+func (s *userParamsService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	return map[string]interface{}{
+		"user":        params.User,
+		"permissions": params.Get("permissions"),
+		"query":       params.Query,
+	}, nil
+}
+
+// authenticatedFind calls a service which uses AuthenticationHook (default strategy jwt) like an external http request.
+/*
+It returns the authenticated user, the permissions and the query of the call
+*/
+func authenticatedFind(app *feathers.App, headers map[string]string, query map[string]interface{}, strategies ...string) (map[string]interface{}, error) {
+	if len(strategies) == 0 {
+		strategies = []string{"jwt"}
+	}
+	if app.Service("secrets") == nil {
+		service := &userParamsService{testUserService: &testUserService{BaseService: &feathers.BaseService{}}}
+		service.Hooks.Before.Find = []feathers.Hook{AuthenticationHook(strategies...)}
+		app.AddService("secrets", service)
+	}
+	params := feathers.NewParams()
+	params.Provider = "http"
+	params.Headers = headers
+	if query != nil {
+		params.Query = query
+	}
+	return feathers.ToMap(app.Service("secrets").Find(context.Background(), *params))
+}
+
+func TestAuthenticationHookHeader(t *testing.T) {
+	app, authService := newTestApp(t)
+	token, _, err := authService.createAccessToken(map[string]interface{}{
+		"user": map[string]interface{}{"_id": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Could not create access token: %s", err)
+	}
+
+	result, err := authenticatedFind(app, map[string]string{"authorization": "Bearer " + token}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if user := result["user"].(map[string]interface{}); user["email"] != "test@example.com" {
+		t.Errorf("User was not set on params: %#v", user)
+	}
+}
+
+func TestAuthenticationHookInvalidHeader(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	for key, headers := range []map[string]string{
+		{},
+		{"authorization": "Bearer invalid"},
+		{"authorization": "Basic dGVzdDp0ZXN0"},
+	} {
+		_, err := authenticatedFind(app, headers, nil)
+		if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+			t.Errorf("Failed #%d: expected NotAuthenticated, got: %v", key+1, err)
+		}
+	}
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	app, authService := newTestApp(t)
+	token, _, err := authService.createAccessToken(map[string]interface{}{
+		"user": map[string]interface{}{"_id": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Could not create access token: %s", err)
+	}
+
+	loggedOut := make(chan interface{}, 1)
+	logoutEvents := app.Once("logout")
+	go func() {
+		loggedOut <- <-logoutEvents
+	}()
+
+	params := feathers.NewParams()
+	params.Headers = map[string]string{"authorization": "Bearer " + token}
+	result, err := authService.Remove(context.Background(), "", *params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result.(map[string]interface{})["accessToken"] != token {
+		t.Errorf("Logout did not return the access token: %#v", result)
+	}
+	select {
+	case <-loggedOut:
+	case <-time.After(time.Second):
+		t.Errorf("logout event was not emitted")
+	}
+
+	_, err = authenticatedFind(app, params.Headers, nil)
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for revoked token, got: %v", err)
+	}
+
+	_, err = authService.Remove(context.Background(), "", *feathers.NewParams())
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated without token, got: %v", err)
+	}
+
+	// logout revokes the refresh tokens of the login
+	_, authService = newTestAppStrategies(t, map[string]AuthStrategy{
+		"jwt":     NewJwtStrategy(),
+		"refresh": NewRefreshStrategy(),
+	})
+	refreshStrategy := authService.authStrategies["refresh"].(*RefreshStrategy)
+	refreshToken, err := refreshStrategy.issue(context.Background(), authService, map[string]interface{}{
+		"user": map[string]interface{}{"_id": "1"},
+	}, "", "")
+	if err != nil {
+		t.Fatalf("Could not issue refresh token: %s", err)
+	}
+	login, err := authService.Create(context.Background(), map[string]interface{}{"strategy": "refresh", "refreshToken": refreshToken}, *feathers.NewParams())
+	if err != nil {
+		t.Fatalf("Unexpected error on refresh: %s", err)
+	}
+	loginResult := login.(map[string]interface{})
+	if _, err := authService.Remove(context.Background(), loginResult["accessToken"].(string), *feathers.NewParams()); err != nil {
+		t.Fatalf("Unexpected error on logout: %s", err)
+	}
+	_, err = authService.Create(context.Background(), map[string]interface{}{"strategy": "refresh", "refreshToken": loginResult["refreshToken"]}, *feathers.NewParams())
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for refresh after logout, got: %v", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for key, test := range []struct {
+		value    interface{}
+		expected time.Duration
+	}{
+		{"1d", 24 * time.Hour},
+		{"15m", 15 * time.Minute},
+		{"2 hours", 2 * time.Hour},
+		{"1.5h", 90 * time.Minute},
+		{"100", 100 * time.Millisecond},
+		{60, time.Minute},
+		{float64(30), 30 * time.Second},
+	} {
+		duration, err := ParseDuration(test.value)
+		if err != nil || duration != test.expected {
+			t.Errorf("Failed #%d: expected %s, got %s (%v)", key+1, test.expected, duration, err)
+		}
+	}
+	if _, err := ParseDuration("1 fortnight"); err == nil {
+		t.Errorf("Expected error for unknown unit")
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	_, authService := newTestAppStrategies(t, map[string]AuthStrategy{
+		"jwt":     NewJwtStrategy(),
+		"refresh": NewRefreshStrategy(),
+	})
+	accessToken, _, err := authService.createAccessToken(map[string]interface{}{
+		"user": map[string]interface{}{"_id": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Could not create access token: %s", err)
+	}
+	login, err := authService.Create(context.Background(), map[string]interface{}{"strategy": "jwt", "accessToken": accessToken}, *feathers.NewParams())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := login.(map[string]interface{})["refreshToken"]; ok {
+		t.Errorf("Authentication with existing access token should not issue a refresh token")
+	}
+
+	refreshStrategy := authService.authStrategies["refresh"].(*RefreshStrategy)
+	firstToken, err := refreshStrategy.issue(context.Background(), authService, map[string]interface{}{
+		"user": map[string]interface{}{"_id": "1"},
+	}, "", "")
+	if err != nil {
+		t.Fatalf("Could not issue refresh token: %s", err)
+	}
+
+	if _, err := authService.verifyAccessToken(context.Background(), firstToken); err == nil {
+		t.Errorf("Refresh token should not be accepted as access token")
+	}
+
+	refresh := func(token string) (map[string]interface{}, error) {
+		result, err := authService.Create(context.Background(), map[string]interface{}{"strategy": "refresh", "refreshToken": token}, *feathers.NewParams())
+		if err != nil {
+			return nil, err
+		}
+		return result.(map[string]interface{}), nil
+	}
+
+	result, err := refresh(firstToken)
+	if err != nil {
+		t.Fatalf("Unexpected error on refresh: %s", err)
+	}
+	secondToken, _ := result["refreshToken"].(string)
+	if secondToken == "" || secondToken == firstToken || result["accessToken"] == nil {
+		t.Fatalf("Refresh did not rotate tokens: %#v", result)
+	}
+	refreshedToken := result["accessToken"].(string)
+	if _, err := authService.verifyAccessToken(context.Background(), refreshedToken); err != nil {
+		t.Fatalf("Unexpected error for refreshed access token: %s", err)
+	}
+
+	_, err = refresh(firstToken)
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for reused refresh token, got: %v", err)
+	}
+	if _, err = refresh(secondToken); err == nil {
+		t.Errorf("Reuse should revoke all refresh tokens of the login")
+	}
+	if _, err := authService.verifyAccessToken(context.Background(), refreshedToken); err == nil {
+		t.Errorf("Reuse should revoke the access tokens of the login")
+	}
+}
+
+type testConnection struct {
+	entity         interface{}
+	authentication map[string]interface{}
+	expiresAt      time.Time
+	timer          *time.Timer
+	disconnected   bool
+	lock           sync.Mutex
+}
+
+func (c *testConnection) Join(room string) error  { return nil }
+func (c *testConnection) Leave(room string) error { return nil }
+func (c *testConnection) IsAuthenticated() bool   { return c.AuthEntity() != nil }
+func (c *testConnection) AuthEntity() interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.entity
+}
+func (c *testConnection) SetAuthEntity(entity interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entity = entity
+}
+func (c *testConnection) Authentication() map[string]interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.authentication
+}
+func (c *testConnection) SetAuthentication(authentication map[string]interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.authentication = authentication
+}
+func (c *testConnection) AuthExpiresAt() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.expiresAt
+}
+func (c *testConnection) SetAuthExpiry(expiresAt time.Time, onExpire func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.expiresAt = expiresAt
+	if !expiresAt.IsZero() && onExpire != nil {
+		c.timer = time.AfterFunc(time.Until(expiresAt), onExpire)
+	}
+}
+func (c *testConnection) Disconnect() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.disconnected = true
+}
+
+func newApiKeyTestApp(t *testing.T) (*feathers.App, *AuthService) {
+	app, authService := newTestAppStrategies(t, map[string]AuthStrategy{
+		"jwt":    NewJwtStrategy(),
+		"apiKey": NewApiKeyStrategy(),
+	})
+	authService.config["apiKey"] = map[string]interface{}{
+		"query":   "apiKey",
+		"service": "keys",
+		"keys": []interface{}{
+			map[string]interface{}{"key": "worker-key", "name": "worker", "permissions": []string{"messages:read"}},
+		},
+	}
+	app.AddService("keys", &testUserService{
+		BaseService: &feathers.BaseService{},
+		users: map[string]map[string]interface{}{
+			"k1": {"_id": "k1", "key": HashApiKey("stored-key"), "permissions": []interface{}{"messages:write"}},
+		},
+	})
+	return app, authService
+}
+
+func TestApiKeyStrategy(t *testing.T) {
+	app, _ := newApiKeyTestApp(t)
+
+	result, err := authenticatedFind(app, map[string]string{"x-api-key": "worker-key"}, nil, "jwt", "apiKey")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if user := result["user"].(map[string]interface{}); user["name"] != "worker" {
+		t.Errorf("User was not set for static key: %#v", user)
+	}
+	if permissions, ok := result["permissions"].([]string); !ok || len(permissions) != 1 || permissions[0] != "messages:read" {
+		t.Errorf("Permissions were not set: %#v", result["permissions"])
+	}
+
+	result, err = authenticatedFind(app, nil, map[string]interface{}{"apiKey": "stored-key", "read": false}, "jwt", "apiKey")
+	if err != nil {
+		t.Fatalf("Unexpected error for key of service: %s", err)
+	}
+	if user := result["user"].(map[string]interface{}); user["_id"] != "k1" {
+		t.Errorf("User was not set for key of service: %#v", user)
+	}
+	if _, ok := result["query"].(map[string]interface{})["apiKey"]; ok {
+		t.Errorf("apiKey was not removed from query")
+	}
+
+	_, err = authenticatedFind(app, map[string]string{"x-api-key": "invalid"}, nil, "jwt", "apiKey")
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for invalid key, got: %v", err)
+	}
+	// the stored hash is not a valid key
+	_, err = authenticatedFind(app, map[string]string{"x-api-key": HashApiKey("stored-key")}, nil, "jwt", "apiKey")
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for hash of key, got: %v", err)
+	}
+}
+
+func TestApiKeyWithoutName(t *testing.T) {
+	app, authService := newApiKeyTestApp(t)
+	authService.config["apiKey"] = map[string]interface{}{
+		"keys": []interface{}{
+			map[string]interface{}{"key": "worker-key", "name": "worker"},
+			map[string]interface{}{"key": "anonymous-key"},
+		},
+	}
+	for key, apiKey := range []string{"worker-key", "anonymous-key"} {
+		_, err := authenticatedFind(app, map[string]string{"x-api-key": apiKey}, nil, "jwt", "apiKey")
+		if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 500 {
+			t.Errorf("Failed #%d: expected GeneralError for key without name, got: %v", key+1, err)
+		}
+	}
+}
+
+func TestApiKeySocketAuthentication(t *testing.T) {
+	_, authService := newApiKeyTestApp(t)
+	connection := &testConnection{}
+	params := feathers.NewParams()
+	params.IsSocket = true
+	params.Connection = connection
+
+	loginEvents := authService.app.Once("login")
+	go func() { <-loginEvents }()
+	result, err := authService.Create(context.Background(), map[string]interface{}{"strategy": "apiKey", "apiKey": "worker-key"}, *params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := result.(map[string]interface{})["accessToken"]; ok {
+		t.Errorf("No access token should be created for api keys")
+	}
+	if !connection.IsAuthenticated() || connection.Authentication()["permissions"] == nil {
+		t.Errorf("Connection was not authenticated: %#v", connection)
+	}
+}
+
+func TestConnectionLifecycle(t *testing.T) {
+	app, authService := newTestApp(t)
+	authService.config["socketExpiry"] = "disconnect"
+	connection := &testConnection{}
+	authenticate := func(id string, expiresIn time.Duration) {
+		token, _, err := authService.createToken(map[string]interface{}{
+			"user": map[string]interface{}{"_id": id},
+		}, tokenClaims{expiresIn: expiresIn})
+		if err != nil {
+			t.Fatalf("Could not create access token: %s", err)
+		}
+		params := feathers.NewParams()
+		params.IsSocket = true
+		params.Connection = connection
+		if _, err := authService.Create(context.Background(), map[string]interface{}{"strategy": "jwt", "accessToken": token}, *params); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	app.AddService("users", &testUserService{
+		BaseService: &feathers.BaseService{},
+		users: map[string]map[string]interface{}{
+			"1": {"_id": "1", "email": "first@example.com"},
+			"2": {"_id": "2", "email": "second@example.com"},
+		},
+	})
+
+	events := make(chan string, 3)
+	for _, event := range []string{"login", "reauthenticate", "expired"} {
+		event := event
+		listener := app.Once(event)
+		go func() {
+			<-listener
+			events <- event
+		}()
+	}
+
+	authenticate("1", time.Hour)
+	if event := <-events; event != "login" {
+		t.Errorf("Expected login event, got %s", event)
+	}
+	authenticate("2", 2*time.Second)
+	if event := <-events; event != "reauthenticate" {
+		t.Errorf("Expected reauthenticate event, got %s", event)
+	}
+	if entity, _ := connection.AuthEntity().(map[string]interface{}); entity["_id"] != "2" {
+		t.Errorf("Re-authentication did not replace the entity: %#v", entity)
+	}
+	if expiresAt := connection.AuthExpiresAt(); time.Until(expiresAt) > 2*time.Second || expiresAt.IsZero() {
+		t.Errorf("Connection does not store the token expiry: %s", expiresAt)
+	}
+
+	select {
+	case event := <-events:
+		if event != "expired" {
+			t.Errorf("Expected expired event, got %s", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Connection did not expire")
+	}
+	if connection.AuthEntity() != nil || connection.Authentication() != nil {
+		t.Errorf("Expired connection was not cleared")
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		connection.lock.Lock()
+		disconnected := connection.disconnected
+		connection.lock.Unlock()
+		if disconnected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expired connection was not disconnected")
+		}
+	}
+}
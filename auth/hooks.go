@@ -3,9 +3,17 @@ package auth
 import (
 	"github.com/tobiasbeck/feathers-go/feathers"
 	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/hooks"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// AuthenticationHook authenticates external calls (similar to feathers `authenticate('jwt')`).
+/*
+Socket connections which are already authenticated pass. Otherwise the named strategies parse the request
+(e.g. the jwt strategy reads `Authorization: Bearer <token>` from the headers) and authenticate it.
+On success `Params.User` and `Params.Authenticated` are set and the authentication result is stored in the `authentication` param field
+(`permissions` of the result, e.g. of the apiKey strategy, are stored in the `permissions` param field)
+*/
 func AuthenticationHook(strategies ...string) feathers.Hook {
 
 	return func(ctx *feathers.Context) error {
@@ -17,39 +25,109 @@ func AuthenticationHook(strategies ...string) feathers.Hook {
 			return nil
 		}
 
-		// TODO: implement authentication which is not socket based
-		// service, err := ctx.App.ServiceClass("authentication")
-		// if err != nil {
-		// 	return nil, httperrors.Convert(err)
-		// }
-		// authService := service.(AuthService)
-		// authData := map[string]interface{}{
-		// 	strategy: "jwt",
+		if ctx.Params.Authenticated && ctx.Params.User != nil {
+			return nil
+		}
 
-		// }
+		authService, ok := ctx.App.ServiceClass("authentication").(*AuthService)
+		if !ok {
+			return httperrors.NewGeneralError("Authentication service is not registered", nil)
+		}
 
-		// authService.Create(map[string]intewr)
+		for _, name := range strategies {
+			strategy, ok := authService.authStrategies[name]
+			if !ok {
+				return httperrors.NewGeneralError("Strategy "+name+" not registered", nil)
+			}
+			parser, ok := strategy.(AuthParser)
+			if !ok {
+				continue
+			}
+			authentication, err := parser.Parse(ctx.Params)
+			if err != nil {
+				return httperrors.NewNotAuthenticated(err.Error(), nil)
+			}
+			if authentication == nil {
+				continue
+			}
+			result, err := strategy.Authenticate(ctx, *authentication, ctx.Params)
+			if err != nil {
+				if featherErr, ok := err.(httperrors.FeathersError); ok {
+					return featherErr
+				}
+				return httperrors.NewNotAuthenticated(err.Error(), nil)
+			}
+			entity, err := feathers.ToMap(result[authService.DefaultConfig().Entity])
+			if err != nil || entity == nil {
+				return httperrors.NewNotAuthenticated("Authenticated entity not found", nil)
+			}
+			ctx.Params.User = entity
+			ctx.Params.Authenticated = true
+			ctx.Params.Set("authentication", result["authentication"])
+			if permissions, ok := result["permissions"]; ok {
+				ctx.Params.Set("permissions", permissions)
+			}
+			return nil
+		}
 
 		return httperrors.NewNotAuthenticated("Not authenticated", nil)
 	}
 }
 
+// DefaultBcryptCost is the bcrypt cost used by HashPassword if neither a cost is passed nor `authentication.bcryptCost` is configured
+const DefaultBcryptCost = 15
+
 // HashPassword is a hool which hashes the password in the given field using bcrypt. If the field is not set or cannot be converted a error is retunred
-func HashPassword(field string) feathers.Hook {
+/*
+The cost can be passed (`HashPassword("password", 12)`) or configured with `authentication.bcryptCost` (default DefaultBcryptCost).
+For multi create calls the password of every entity is hashed
+*/
+func HashPassword(field string, cost ...int) feathers.Hook {
 	return func(ctx *feathers.Context) error {
-		if password, ok := ctx.Data[field]; ok {
+		items, normalized := hooks.GetItemsNormalized(ctx)
+		if len(items) == 0 {
+			return httperrors.NewBadRequest("password not found", nil)
+		}
+		for _, item := range items {
+			password, ok := item[field]
+			if !ok {
+				return httperrors.NewBadRequest("password not found", nil)
+			}
 			passwordString, ok := password.(string)
 			if ok == false {
 				return httperrors.NewGeneralError("password field incorrect", nil)
 			}
 
-			encrypted, err := bcrypt.GenerateFromPassword([]byte(passwordString), 15)
+			encrypted, err := GeneratePasswordHash(&ctx.App, passwordString, cost...)
 			if err != nil {
-				return httperrors.Convert(err)
+				return err
+			}
+			item[field] = encrypted
+		}
+		hooks.ReplaceItemsNormalized(ctx, items, normalized)
+		return nil
+	}
+}
+
+// GeneratePasswordHash hashes password using bcrypt with the cost used by HashPassword
+func GeneratePasswordHash(app *feathers.App, password string, cost ...int) (string, error) {
+	encrypted, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost(app, cost))
+	if err != nil {
+		return "", httperrors.Convert(err)
+	}
+	return string(encrypted), nil
+}
+
+func bcryptCost(app *feathers.App, cost []int) int {
+	if len(cost) > 0 {
+		return cost[0]
+	}
+	if config, ok := app.Config("authentication"); ok {
+		if configMap, ok := config.(map[string]interface{}); ok {
+			if configCost, ok := configMap["bcryptCost"].(int); ok {
+				return configCost
 			}
-			ctx.Data[field] = string(encrypted)
-			return nil
 		}
-		return httperrors.NewBadRequest("password not found", nil)
 	}
+	return DefaultBcryptCost
 }
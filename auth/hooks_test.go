@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPasswordMultiCreate(t *testing.T) {
+	app := feathers.NewApp()
+	users := memory.NewService(nil, nil)
+	users.Multi = []feathers.RestMethod{feathers.Create}
+	users.Hooks.Append(feathers.Before, feathers.Create, []feathers.Hook{HashPassword("password", bcrypt.MinCost)})
+	app.AddService("users", users)
+
+	result, err := app.Service("users").(feathers.MultiCreateService).CreateMany(context.Background(), []map[string]interface{}{
+		{"email": "a@example.com", "password": "secret-a"},
+		{"email": "b@example.com", "password": "secret-b"},
+	}, *feathers.NewParams())
+	if err != nil {
+		t.Fatalf("Could not create users: %s", err)
+	}
+	created := result.([]map[string]interface{})
+	for key, password := range []string{"secret-a", "secret-b"} {
+		hash, _ := created[key]["password"].(string)
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			t.Errorf("Failed #%d: password was not hashed: %#v", key+1, created[key])
+		}
+	}
+
+	_, err = app.Service("users").(feathers.MultiCreateService).CreateMany(context.Background(), []map[string]interface{}{
+		{"email": "c@example.com", "password": "secret-c"},
+		{"email": "d@example.com"},
+	}, *feathers.NewParams())
+	if err == nil {
+		t.Errorf("Expected error for missing password")
+	}
+}
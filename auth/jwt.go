@@ -3,11 +3,8 @@ package auth
 import (
 	"context"
 	"errors"
-	"time"
+	"strings"
 
-	"github.com/gbrlsnchs/jwt/v3"
-	"github.com/mcuadros/go-defaults"
-	"github.com/mitchellh/mapstructure"
 	"github.com/tobiasbeck/feathers-go/feathers"
 )
 
@@ -15,55 +12,76 @@ type JwtStrategy struct {
 	*BaseAuthStrategy
 }
 
-func (s *JwtStrategy) Authenticate(ctx context.Context, data Model, params feathers.Params) (map[string]interface{}, error) {
-	defaultConfig := s.DefaultConfig()
-	if jwtConfig, ok := s.config["jwtOptions"]; ok {
-		if token, ok := data.Params["accessToken"]; ok {
-			encryption := jwt.NewHS256([]byte(defaultConfig.Secret))
-			var payload jwtToken
-			now := time.Now()
-
-			defaultPayload := jwtToken{}
-
-			mapstructure.Decode(jwtConfig, &defaultPayload)
-			defaults.SetDefaults(&defaultPayload)
-
-			iatValidator := jwt.IssuedAtValidator(now)
-			expValidator := jwt.ExpirationTimeValidator(now)
-			// audValidator := jwt.AudienceValidator(defaultPayload.Audience)
-			issValidator := jwt.IssuerValidator(defaultPayload.Issuer)
-			// nbfValidator := jwt.NotBeforeValidator(now)
+type jwtStrategyConfig struct {
+	Header  string   `mapstructure:"header" default:"authorization"`
+	Schemes []string `mapstructure:"schemes"`
+}
 
-			validatePayload := jwt.ValidatePayload((*jwt.Payload)(&payload), iatValidator, expValidator, issValidator)
-			_, err := jwt.Verify([]byte(token.(string)), encryption, &payload, validatePayload)
-			if err != nil {
-				return nil, err
+// Parse reads the access token from the authorization header (`Authorization: Bearer <token>`)
+func (s *JwtStrategy) Parse(params feathers.Params) (*Model, error) {
+	config := jwtStrategyConfig{}
+	s.StrategyConfig(&config)
+	if len(config.Schemes) == 0 {
+		config.Schemes = []string{"Bearer", "JWT"}
+	}
+	headerValue, ok := params.Headers[strings.ToLower(config.Header)]
+	if !ok || headerValue == "" {
+		return nil, nil
+	}
+	token := strings.TrimSpace(headerValue)
+	if parts := strings.SplitN(token, " ", 2); len(parts) == 2 {
+		schemeAllowed := false
+		for _, scheme := range config.Schemes {
+			if strings.EqualFold(scheme, parts[0]) {
+				schemeAllowed = true
 			}
+		}
+		if !schemeAllowed {
+			return nil, nil
+		}
+		token = strings.TrimSpace(parts[1])
+	}
+	return &Model{
+		Strategy: s.name,
+		Params: map[string]interface{}{
+			"accessToken": token,
+		},
+	}, nil
+}
 
-			entityID := payload.Subject
-
-			if entityService, ok := s.EntityService(); ok {
-				entity, err := entityService.Get(ctx, entityID, *feathers.NewParams())
-				if err != nil {
-					return nil, err
-				}
-				result := map[string]interface{}{
-					"authentication": map[string]interface{}{
-						"strategy":    "local",
-						"accessToken": token,
-						"payload":     payload,
-					},
-				}
-				result[defaultConfig.Entity] = entity
-				result["accessToken"] = token
-				return result, nil
+func (s *JwtStrategy) Authenticate(ctx context.Context, data Model, params feathers.Params) (map[string]interface{}, error) {
+	defaultConfig := s.DefaultConfig()
+	authService, ok := s.AuthService()
+	if !ok {
+		return nil, errors.New("Authentication service is not registered")
+	}
+	token, ok := data.Params["accessToken"].(string)
+	if !ok {
+		return nil, errors.New("No accessToken sent")
+	}
+	payload, err := authService.verifyAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
 
-			}
-			return nil, errors.New("JWT Invalid")
+	if entityService, ok := s.EntityService(); ok {
+		entity, err := entityService.Get(ctx, payload.Subject, *feathers.NewParams())
+		if err != nil {
+			return nil, err
 		}
-		return nil, errors.New("No accessToken sent")
+		result := map[string]interface{}{
+			"authentication": map[string]interface{}{
+				"strategy":    s.name,
+				"accessToken": token,
+				"payload":     *payload,
+			},
+		}
+		result[defaultConfig.Entity] = entity
+		result["accessToken"] = token
+		return result, nil
+
 	}
-	return nil, errors.New("no jwt configuration given")
+	return nil, errors.New("JWT Invalid")
 }
 
 func NewJwtStrategy() *JwtStrategy {
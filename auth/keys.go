@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/mitchellh/mapstructure"
+)
+
+// KeyConfig configures a key used to sign or verify access tokens.
+/*
+PrivateKey and PublicKey are paths to PEM files (PEM content is accepted as well).
+Only the public key is needed for keys which are only used for verification
+*/
+type KeyConfig struct {
+	// KeyID is the `kid` header of tokens signed with this key
+	KeyID string `mapstructure:"kid"`
+	// Algorithm is one of HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512 (defaults to `jwtOptions.algorithm`)
+	Algorithm  string `mapstructure:"algorithm"`
+	Secret     string `mapstructure:"secret"`
+	PrivateKey string `mapstructure:"privateKey"`
+	PublicKey  string `mapstructure:"publicKey"`
+}
+
+type keyOptions struct {
+	Algorithm string `mapstructure:"algorithm"`
+	KeyID     string `mapstructure:"keyid"`
+}
+
+// keySet holds all keys of the authentication service by `kid`
+type keySet struct {
+	signing    jwt.Algorithm
+	signingKid string
+	keys       map[string]jwt.Algorithm
+}
+
+// newKeySet creates the keys from the authentication configuration.
+/*
+`secret`, `privateKey` and `publicKey` of the authentication config define the key without `kid`,
+`keys` is a list of KeyConfig with kid. Tokens are signed with the key named by `jwtOptions.keyid`
+*/
+func newKeySet(config map[string]interface{}) (*keySet, error) {
+	options := keyOptions{}
+	if jwtOptions, ok := config["jwtOptions"]; ok {
+		mapstructure.Decode(jwtOptions, &options)
+	}
+	if options.Algorithm == "" {
+		options.Algorithm = "HS256"
+	}
+
+	set := &keySet{
+		keys: map[string]jwt.Algorithm{},
+	}
+	defaultKey := KeyConfig{}
+	mapstructure.Decode(config, &defaultKey)
+	defaultKey.KeyID = ""
+	keyConfigs := []KeyConfig{}
+	if defaultKey.Secret != "" || defaultKey.PrivateKey != "" || defaultKey.PublicKey != "" {
+		keyConfigs = append(keyConfigs, defaultKey)
+	}
+	if keys, ok := config["keys"]; ok {
+		configuredKeys := []KeyConfig{}
+		if err := mapstructure.Decode(keys, &configuredKeys); err != nil {
+			return nil, fmt.Errorf("cannot decode keys: %w", err)
+		}
+		for _, key := range configuredKeys {
+			if key.KeyID == "" {
+				return nil, errors.New("keys need a kid")
+			}
+			keyConfigs = append(keyConfigs, key)
+		}
+	}
+
+	for _, keyConfig := range keyConfigs {
+		if keyConfig.Algorithm == "" {
+			keyConfig.Algorithm = options.Algorithm
+		}
+		if _, ok := set.keys[keyConfig.KeyID]; ok {
+			return nil, fmt.Errorf("key %q is defined twice", keyConfig.KeyID)
+		}
+		algorithm, canSign, err := newAlgorithm(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", keyConfig.KeyID, err)
+		}
+		set.keys[keyConfig.KeyID] = algorithm
+		if keyConfig.KeyID == options.KeyID {
+			if !canSign {
+				return nil, fmt.Errorf("key %q cannot be used for signing", keyConfig.KeyID)
+			}
+			set.signing = algorithm
+			set.signingKid = keyConfig.KeyID
+		}
+	}
+	if set.signing == nil {
+		return nil, fmt.Errorf("no signing key %q configured", options.KeyID)
+	}
+	return set, nil
+}
+
+// signOptions returns the options to sign a token with the signing key
+func (ks *keySet) signOptions() []jwt.SignOption {
+	if ks.signingKid == "" {
+		return []jwt.SignOption{}
+	}
+	return []jwt.SignOption{jwt.KeyID(ks.signingKid)}
+}
+
+// verifier returns an algorithm which selects the verification key by the `kid` of the token
+func (ks *keySet) verifier() *keyResolver {
+	return &keyResolver{keys: ks}
+}
+
+// keyResolver is a jwt.Algorithm which resolves the key by the token header (one per verification)
+type keyResolver struct {
+	keys      *keySet
+	algorithm jwt.Algorithm
+}
+
+func (kr *keyResolver) Resolve(header jwt.Header) error {
+	algorithm, ok := kr.keys.keys[header.KeyID]
+	if !ok {
+		return fmt.Errorf("unknown key %q", header.KeyID)
+	}
+	if algorithm.Name() != header.Algorithm {
+		return jwt.ErrAlgValidation
+	}
+	kr.algorithm = algorithm
+	return nil
+}
+
+func (kr *keyResolver) Name() string {
+	return kr.algorithm.Name()
+}
+
+func (kr *keyResolver) Sign(headerPayload []byte) ([]byte, error) {
+	return kr.algorithm.Sign(headerPayload)
+}
+
+func (kr *keyResolver) Size() int {
+	return kr.algorithm.Size()
+}
+
+func (kr *keyResolver) Verify(headerPayload, sig []byte) error {
+	return kr.algorithm.Verify(headerPayload, sig)
+}
+
+// newAlgorithm creates the jwt algorithm of a key. canSign is false if only a public key is set
+func newAlgorithm(config KeyConfig) (algorithm jwt.Algorithm, canSign bool, err error) {
+	switch config.Algorithm {
+	case "HS256", "HS384", "HS512":
+		if config.Secret == "" {
+			return nil, false, errors.New("secret is not set")
+		}
+		secret := []byte(config.Secret)
+		switch config.Algorithm {
+		case "HS384":
+			return jwt.NewHS384(secret), true, nil
+		case "HS512":
+			return jwt.NewHS512(secret), true, nil
+		}
+		return jwt.NewHS256(secret), true, nil
+	case "RS256", "RS384", "RS512":
+		privateKey, publicKey, err := loadKeyPair(config)
+		if err != nil {
+			return nil, false, err
+		}
+		options := []func(*jwt.RSASHA){}
+		if privateKey != nil {
+			rsaKey, ok := privateKey.(*rsa.PrivateKey)
+			if !ok {
+				return nil, false, errors.New("private key is not a RSA key")
+			}
+			options = append(options, jwt.RSAPrivateKey(rsaKey))
+		}
+		if publicKey != nil {
+			rsaKey, ok := publicKey.(*rsa.PublicKey)
+			if !ok {
+				return nil, false, errors.New("public key is not a RSA key")
+			}
+			options = append(options, jwt.RSAPublicKey(rsaKey))
+		}
+		switch config.Algorithm {
+		case "RS384":
+			return jwt.NewRS384(options...), privateKey != nil, nil
+		case "RS512":
+			return jwt.NewRS512(options...), privateKey != nil, nil
+		}
+		return jwt.NewRS256(options...), privateKey != nil, nil
+	case "ES256", "ES384", "ES512":
+		privateKey, publicKey, err := loadKeyPair(config)
+		if err != nil {
+			return nil, false, err
+		}
+		options := []func(*jwt.ECDSASHA){}
+		if privateKey != nil {
+			ecKey, ok := privateKey.(*ecdsa.PrivateKey)
+			if !ok {
+				return nil, false, errors.New("private key is not a ECDSA key")
+			}
+			options = append(options, jwt.ECDSAPrivateKey(ecKey))
+		}
+		if publicKey != nil {
+			ecKey, ok := publicKey.(*ecdsa.PublicKey)
+			if !ok {
+				return nil, false, errors.New("public key is not a ECDSA key")
+			}
+			options = append(options, jwt.ECDSAPublicKey(ecKey))
+		}
+		switch config.Algorithm {
+		case "ES384":
+			return jwt.NewES384(options...), privateKey != nil, nil
+		case "ES512":
+			return jwt.NewES512(options...), privateKey != nil, nil
+		}
+		return jwt.NewES256(options...), privateKey != nil, nil
+	}
+	return nil, false, fmt.Errorf("algorithm %q is not supported", config.Algorithm)
+}
+
+// loadKeyPair loads the private and public key of config. One of them has to be set
+func loadKeyPair(config KeyConfig) (privateKey interface{}, publicKey interface{}, err error) {
+	if config.PrivateKey == "" && config.PublicKey == "" {
+		return nil, nil, errors.New("privateKey or publicKey has to be set")
+	}
+	if config.PrivateKey != "" {
+		block, err := readPEM(config.PrivateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, err = parsePrivateKey(block)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if config.PublicKey != "" {
+		block, err := readPEM(config.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey, err = parsePublicKey(block)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return privateKey, publicKey, nil
+}
+
+// readPEM reads the first PEM block of a file (or of the value itself if it contains PEM data)
+func readPEM(value string) (*pem.Block, error) {
+	data := []byte(value)
+	if !strings.Contains(value, "-----BEGIN") {
+		fileData, err := ioutil.ReadFile(value)
+		if err != nil {
+			return nil, err
+		}
+		data = fileData
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func parsePublicKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return certificate.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
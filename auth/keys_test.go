@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writePEM(t *testing.T, name string, blockType string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+		t.Fatalf("Could not write key: %s", err)
+	}
+	return path
+}
+
+func newRSAKeyFiles(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Could not generate key: %s", err)
+	}
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	return writePEM(t, "private.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), writePEM(t, "public.pem", "PUBLIC KEY", public)
+}
+
+func signAndVerify(t *testing.T, signing *keySet, verifying *keySet) error {
+	service := &AuthService{
+		config: map[string]interface{}{
+			"entity":     "user",
+			"jwtOptions": map[string]interface{}{},
+		},
+		keys: signing,
+	}
+	token, _, err := service.createAccessToken(map[string]interface{}{
+		"user": map[string]interface{}{"_id": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Could not sign token: %s", err)
+	}
+	service.keys = verifying
+	_, err = service.verifyAccessToken(context.Background(), token)
+	return err
+}
+
+func TestKeySetAlgorithms(t *testing.T) {
+	privateRSA, publicRSA := newRSAKeyFiles(t)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecPrivate, _ := x509.MarshalECPrivateKey(ecKey)
+
+	for key, config := range []map[string]interface{}{
+		{"secret": "supersecret"},
+		{"secret": "supersecret", "jwtOptions": map[string]interface{}{"algorithm": "HS512"}},
+		{"privateKey": privateRSA, "publicKey": publicRSA, "jwtOptions": map[string]interface{}{"algorithm": "RS256"}},
+		{"privateKey": writePEM(t, "ec.pem", "EC PRIVATE KEY", ecPrivate), "jwtOptions": map[string]interface{}{"algorithm": "ES256"}},
+	} {
+		keys, err := newKeySet(config)
+		if err != nil {
+			t.Errorf("Failed #%d: could not create keys: %s", key+1, err)
+			continue
+		}
+		if err := signAndVerify(t, keys, keys); err != nil {
+			t.Errorf("Failed #%d: could not verify token: %s", key+1, err)
+		}
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldPrivate, oldPublic := newRSAKeyFiles(t)
+	newPrivate, newPublic := newRSAKeyFiles(t)
+
+	before, err := newKeySet(map[string]interface{}{
+		"jwtOptions": map[string]interface{}{"algorithm": "RS256", "keyid": "2020"},
+		"keys": []interface{}{
+			map[string]interface{}{"kid": "2020", "privateKey": oldPrivate},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not create keys: %s", err)
+	}
+	after, err := newKeySet(map[string]interface{}{
+		"jwtOptions": map[string]interface{}{"algorithm": "RS256", "keyid": "2021"},
+		"keys": []interface{}{
+			map[string]interface{}{"kid": "2020", "publicKey": oldPublic},
+			map[string]interface{}{"kid": "2021", "privateKey": newPrivate, "publicKey": newPublic},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not create rotated keys: %s", err)
+	}
+
+	if err := signAndVerify(t, before, after); err != nil {
+		t.Errorf("Token of old key is not valid after rotation: %s", err)
+	}
+	if err := signAndVerify(t, after, before); err == nil {
+		t.Errorf("Token with unknown kid should not be valid")
+	}
+
+	_, err = newKeySet(map[string]interface{}{
+		"jwtOptions": map[string]interface{}{"algorithm": "RS256", "keyid": "2020"},
+		"keys": []interface{}{
+			map[string]interface{}{"kid": "2020", "publicKey": oldPublic},
+		},
+	})
+	if err == nil {
+		t.Errorf("Public key should not be usable as signing key")
+	}
+}
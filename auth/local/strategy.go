@@ -12,10 +12,14 @@ import (
 type strategyConfig struct {
 	UsernameField string `mapstructure:"usernameField" default:"username"`
 	PasswordField string `mapstructure:"passswordField" default:"password"`
+	// Throttle configures the brute force protection (backoff and lockout per username and ip address)
+	Throttle throttleConfig `mapstructure:"throttle"`
 }
 
 type Strategy struct {
 	*auth.BaseAuthStrategy
+	// Attempts tracks login attempts (defaults to a MemoryAttemptStore)
+	Attempts AttemptStore
 }
 
 func (s *Strategy) findEntity(ctx context.Context, username string, params feathers.Params) (map[string]interface{}, error) {
@@ -25,6 +29,7 @@ func (s *Strategy) findEntity(ctx context.Context, username string, params feath
 		query := map[string]interface{}{}
 		query[config.UsernameField] = username
 		findParams := feathers.NewParamsQuery(query)
+		findParams.Set("paginate", false)
 		iResults, err := service.Find(ctx, *findParams)
 		if err != nil {
 			return nil, httperrors.NewNotAuthenticated(err.Error(), nil)
@@ -61,17 +66,46 @@ func (s *Strategy) comparePassword(entity map[string]interface{}, password strin
 	return false
 }
 
+// Authenticate checks username and password.
+/*
+Attempts are tracked per username until a login succeeds and per ip address (successful ones as well) until no attempt
+was made for `throttle.window` (default 1h). After `throttle.username.freeAttempts` (default 5) attempts every further attempt has to wait `throttle.baseDelay` (default 1s) doubled per attempt (max `throttle.maxDelay`, default 5m).
+After `throttle.username.lockoutAttempts` (default 20) attempts the username is locked for `throttle.lockoutDuration` (default 15m).
+Attempts are recorded atomically before the password is checked, so parallel requests cannot exceed the limits.
+Attempts which have to wait fail with TooManyRequests before the password is checked.
+If the entity has two-factor authentication enabled (see auth.TOTPStrategy) the `totp` field is checked as well.
+Without it only a partial token is returned
+*/
 func (s *Strategy) Authenticate(ctx context.Context, data auth.Model, params feathers.Params) (map[string]interface{}, error) {
 	config := strategyConfig{}
 	s.StrategyConfig(&config)
 	defaultConfig := s.DefaultConfig()
-	// fmt.Printf("data: %s, %#v\n", config.UsernameField, data)
-	entity, err := s.findEntity(ctx, data.Params[config.UsernameField].(string), params)
+	username, _ := data.Params[config.UsernameField].(string)
+	password, _ := data.Params[config.PasswordField].(string)
+	if username == "" || password == "" {
+		return nil, httperrors.NewNotAuthenticated("Username or Password is incorrect", nil)
+	}
+
+	var limiter *throttle
+	var keys map[string]throttleLimits
+	if !config.Throttle.Disabled && s.Attempts != nil {
+		var err error
+		limiter, err = newThrottle(config.Throttle)
+		if err != nil {
+			return nil, httperrors.NewGeneralError("Invalid throttle configuration: " + err.Error())
+		}
+		keys = limiter.keys(username, params)
+		if err := limiter.attempt(ctx, s.Attempts, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	entity, err := s.findEntity(ctx, username, params)
 	if err != nil {
 		return nil, err
 	}
 	// This takes around 250ms to complete (pretty slow)
-	passwordCorrect := s.comparePassword(entity, data.Params[config.PasswordField].(string))
+	passwordCorrect := s.comparePassword(entity, password)
 	if !passwordCorrect {
 		return nil, httperrors.NewNotAuthenticated("Username or Password is incorrect", nil)
 	}
@@ -79,11 +113,24 @@ func (s *Strategy) Authenticate(ctx context.Context, data auth.Model, params fea
 		"authentication": struct{ Strategy string }{Strategy: "local"},
 	}
 	result[defaultConfig.Entity] = entity
+	if authService, ok := s.AuthService(); ok {
+		code, _ := data.Params["totp"].(string)
+		result, err = authService.SecondFactor(ctx, result, code)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limiter != nil {
+		if err := limiter.succeed(ctx, s.Attempts, username); err != nil {
+			return nil, err
+		}
+	}
 	return result, nil
 }
 
 func New() *Strategy {
 	return &Strategy{
 		BaseAuthStrategy: &auth.BaseAuthStrategy{},
+		Attempts:         NewMemoryAttemptStore(),
 	}
 }
@@ -0,0 +1,117 @@
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/auth"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testUserService struct {
+	*feathers.BaseService
+	user map[string]interface{}
+}
+
+func (s *testUserService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	if params.Query["email"] == s.user["email"] {
+		return []map[string]interface{}{s.user}, nil
+	}
+	return []map[string]interface{}{}, nil
+}
+
+func (s *testUserService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	if id == s.user["_id"] {
+		return s.user, nil
+	}
+	return nil, httperrors.NewNotFound("User not found")
+}
+
+func (s *testUserService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *testUserService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *testUserService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	for key, value := range data {
+		s.user[key] = value
+	}
+	return s.user, nil
+}
+
+func (s *testUserService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	secret, _ := auth.GenerateTOTPSecret()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	app := feathers.NewApp()
+	app.SetConfig("authentication", map[string]interface{}{
+		"secret":  "supersecret",
+		"entity":  "user",
+		"service": "users",
+		"local": map[string]interface{}{
+			"usernameField": "email",
+		},
+		"jwtOptions": map[string]interface{}{},
+	})
+	app.AddService("users", &testUserService{
+		BaseService: &feathers.BaseService{},
+		user: map[string]interface{}{
+			"_id":         "1",
+			"email":       "admin@example.com",
+			"password":    string(hash),
+			"totpEnabled": true,
+			"totpSecret":  secret,
+		},
+	})
+	err := auth.Configure(app, map[string]interface{}{
+		"strategies": map[string]auth.AuthStrategy{
+			"jwt":   auth.NewJwtStrategy(),
+			"local": New(),
+			"totp":  auth.NewTOTPStrategy(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not configure authentication: %s", err)
+	}
+	login := func(data map[string]interface{}) (map[string]interface{}, error) {
+		data["strategy"] = "local"
+		data["email"] = "admin@example.com"
+		data["password"] = "secret"
+		result, err := app.Service("authentication").Create(context.Background(), data, *feathers.NewParams())
+		if err != nil {
+			return nil, err
+		}
+		return result.(map[string]interface{}), nil
+	}
+
+	result, err := login(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result["partialToken"] == nil || result["accessToken"] != nil || result["user"] != nil {
+		t.Errorf("Expected partial result without totp, got: %#v", result)
+	}
+
+	_, err = login(map[string]interface{}{"totp": "abcdef"})
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for invalid totp, got: %v", err)
+	}
+
+	code, _ := auth.TOTPCode(secret, time.Now())
+	result, err = login(map[string]interface{}{"totp": code})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result["accessToken"] == nil || result["user"] == nil {
+		t.Errorf("Expected access token with valid totp, got: %#v", result)
+	}
+}
@@ -0,0 +1,181 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/auth"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// Attempts are the login attempts of a username or ip address (see auth.Attempts)
+type Attempts = auth.Attempts
+
+// AttemptStore tracks login attempts (see auth.AttemptStore)
+type AttemptStore = auth.AttemptStore
+
+// MemoryAttemptStore is an AttemptStore which keeps attempts in memory (see auth.MemoryAttemptStore)
+type MemoryAttemptStore = auth.MemoryAttemptStore
+
+// NewMemoryAttemptStore creates a new in memory attempt store
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return auth.NewMemoryAttemptStore()
+}
+
+// throttleLimits configures backoff and lockout for one kind of key (username or ip address)
+type throttleLimits struct {
+	// FreeAttempts is the number of attempts which are allowed without delay
+	FreeAttempts int64 `mapstructure:"freeAttempts"`
+	// LockoutAttempts is the number of attempts after which the key is locked for LockoutDuration (0 disables the lockout)
+	LockoutAttempts int64 `mapstructure:"lockoutAttempts"`
+}
+
+type throttleConfig struct {
+	// Disabled disables the brute force protection
+	Disabled bool `mapstructure:"disabled"`
+	// BaseDelay is the delay after the first attempt which exceeds FreeAttempts. It doubles with every further attempt
+	BaseDelay interface{} `mapstructure:"baseDelay"`
+	// MaxDelay caps the delay
+	MaxDelay interface{} `mapstructure:"maxDelay"`
+	// LockoutDuration is the time a key is locked after LockoutAttempts attempts
+	LockoutDuration interface{} `mapstructure:"lockoutDuration"`
+	// Window is the time after the last attempt when attempts are forgotten
+	Window interface{} `mapstructure:"window"`
+	// TrustProxy uses the first address of the `X-Forwarded-For` header as ip address
+	TrustProxy bool           `mapstructure:"trustProxy"`
+	Username   throttleLimits `mapstructure:"username"`
+	IP         throttleLimits `mapstructure:"ip"`
+}
+
+// throttle is the parsed throttle configuration
+type throttle struct {
+	config          throttleConfig
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+	lockoutDuration time.Duration
+	window          time.Duration
+}
+
+func parseThrottleDuration(value interface{}, defaultValue string) (time.Duration, error) {
+	if value == nil {
+		value = defaultValue
+	}
+	return auth.ParseDuration(value)
+}
+
+// newThrottle applies the defaults to config (usernames: 5 free attempts and lockout after 20, ip addresses: 20 and 100)
+func newThrottle(config throttleConfig) (*throttle, error) {
+	if config.Username.FreeAttempts == 0 && config.Username.LockoutAttempts == 0 {
+		config.Username = throttleLimits{FreeAttempts: 5, LockoutAttempts: 20}
+	}
+	if config.IP.FreeAttempts == 0 && config.IP.LockoutAttempts == 0 {
+		config.IP = throttleLimits{FreeAttempts: 20, LockoutAttempts: 100}
+	}
+	t := &throttle{config: config}
+	var err error
+	if t.baseDelay, err = parseThrottleDuration(config.BaseDelay, "1s"); err != nil {
+		return nil, err
+	}
+	if t.maxDelay, err = parseThrottleDuration(config.MaxDelay, "5m"); err != nil {
+		return nil, err
+	}
+	if t.lockoutDuration, err = parseThrottleDuration(config.LockoutDuration, "15m"); err != nil {
+		return nil, err
+	}
+	if t.window, err = parseThrottleDuration(config.Window, "1h"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// retryAfter returns how long the key with attempts has to wait before the next attempt (0 if it can try now)
+func (t *throttle) retryAfter(attempts Attempts, limits throttleLimits) time.Duration {
+	var wait time.Duration
+	if limits.LockoutAttempts > 0 && attempts.Count >= limits.LockoutAttempts {
+		wait = t.lockoutDuration
+	} else if attempts.Count > limits.FreeAttempts {
+		exponent := float64(attempts.Count - limits.FreeAttempts - 1)
+		delay := time.Duration(float64(t.baseDelay) * math.Pow(2, exponent))
+		if delay > t.maxDelay || delay <= 0 {
+			delay = t.maxDelay
+		}
+		wait = delay
+	}
+	remaining := wait - time.Since(attempts.LastAttempt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// usernameKey returns the store key of a username
+func usernameKey(username string) string {
+	return "local:username:" + strings.ToLower(username)
+}
+
+// keys returns the store keys of a login attempt and their limits
+func (t *throttle) keys(username string, params feathers.Params) map[string]throttleLimits {
+	keys := map[string]throttleLimits{
+		usernameKey(username): t.config.Username,
+	}
+	if ip := t.ip(params); ip != "" {
+		keys["local:ip:"+ip] = t.config.IP
+	}
+	return keys
+}
+
+// ip returns the ip address of the caller
+func (t *throttle) ip(params feathers.Params) string {
+	if t.config.TrustProxy {
+		if forwarded := params.Headers["x-forwarded-for"]; forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	address, _ := params.Get("remoteAddress").(string)
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// attempt records an attempt for all keys before the password is checked. It returns TooManyRequests if one of the
+// keys has to wait (the attempt is not recorded for this key then)
+func (t *throttle) attempt(ctx context.Context, store AttemptStore, keys map[string]throttleLimits) error {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		var wait time.Duration
+		_, recorded, err := store.Attempt(ctx, key, t.window, func(attempts Attempts) bool {
+			wait = t.retryAfter(attempts, keys[key])
+			return wait <= 0
+		})
+		if err != nil {
+			return httperrors.Convert(err)
+		}
+		if !recorded {
+			seconds := int64(math.Ceil(wait.Seconds()))
+			return httperrors.NewTooManyRequests(fmt.Sprintf("Too many login attempts. Retry in %d seconds", seconds), map[string]interface{}{
+				"retryAfter": seconds,
+			})
+		}
+	}
+	return nil
+}
+
+// succeed clears the attempts of username after a successful login. The attempts of the ip address are kept, otherwise
+// logins to an own account between guesses would reset the ip limits
+func (t *throttle) succeed(ctx context.Context, store AttemptStore, username string) error {
+	if err := store.Reset(ctx, usernameKey(username)); err != nil {
+		return httperrors.Convert(err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package local
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+func TestThrottleBackoff(t *testing.T) {
+	limiter, err := newThrottle(throttleConfig{
+		BaseDelay:       "1s",
+		MaxDelay:        "4s",
+		LockoutDuration: "1h",
+		Username:        throttleLimits{FreeAttempts: 2, LockoutAttempts: 6},
+	})
+	if err != nil {
+		t.Fatalf("Could not create throttle: %s", err)
+	}
+	now := time.Now()
+	for key, test := range []struct {
+		count    int64
+		expected time.Duration
+	}{
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, time.Hour},
+	} {
+		wait := limiter.retryAfter(Attempts{Count: test.count, LastAttempt: now}, limiter.config.Username)
+		if wait > test.expected || wait < test.expected-time.Second {
+			t.Errorf("Failed #%d: expected wait of %s, got %s", key+1, test.expected, wait)
+		}
+	}
+}
+
+func TestThrottleCheck(t *testing.T) {
+	limiter, _ := newThrottle(throttleConfig{
+		Username: throttleLimits{FreeAttempts: 1, LockoutAttempts: 3},
+	})
+	store := NewMemoryAttemptStore()
+	params := feathers.NewParams()
+	params.Set("remoteAddress", "10.0.0.1:51234")
+	keys := limiter.keys("Test@example.com", *params)
+	if _, ok := keys["local:ip:10.0.0.1"]; !ok {
+		t.Errorf("Ip address key is missing: %#v", keys)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		// the attempt after the free attempt has no delay yet
+		if err := limiter.attempt(ctx, store, keys); err != nil {
+			t.Errorf("Attempt %d should not be throttled: %s", i+1, err)
+		}
+	}
+	err := limiter.attempt(ctx, store, limiter.keys("test@example.com", *feathers.NewParams()))
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 429 {
+		t.Errorf("Expected TooManyRequests, got: %v", err)
+	}
+	if attempts, _ := store.Get(ctx, usernameKey("test@example.com")); attempts.Count != 2 {
+		t.Errorf("Throttled attempt should not be recorded: %#v", attempts)
+	}
+
+	limiter.succeed(ctx, store, "test@example.com")
+	if err := limiter.attempt(ctx, store, limiter.keys("test@example.com", *feathers.NewParams())); err != nil {
+		t.Errorf("Reset attempts should not be throttled: %s", err)
+	}
+	// a login to another account must not reset the attempts of the ip address
+	if attempts, _ := store.Get(ctx, "local:ip:10.0.0.1"); attempts.Count != 2 {
+		t.Errorf("Attempts of the ip address were reset: %#v", attempts)
+	}
+}
+
+func TestThrottleConcurrentAttempts(t *testing.T) {
+	limiter, _ := newThrottle(throttleConfig{
+		Username: throttleLimits{FreeAttempts: 3, LockoutAttempts: 10},
+	})
+	store := NewMemoryAttemptStore()
+	keys := limiter.keys("test@example.com", *feathers.NewParams())
+
+	// attempts which are still running (e.g. comparing the password) count as well
+	var wait sync.WaitGroup
+	var lock sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if err := limiter.attempt(context.Background(), store, keys); err == nil {
+				lock.Lock()
+				allowed++
+				lock.Unlock()
+			}
+		}()
+	}
+	wait.Wait()
+	// the attempt after the free attempts is allowed without delay as well
+	if allowed != 4 {
+		t.Errorf("Expected 4 parallel attempts to be allowed, got %d", allowed)
+	}
+}
@@ -0,0 +1,80 @@
+package management
+
+import (
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/hooks"
+)
+
+const verifyTokenParam = "management.verifyToken"
+
+func managementService(ctx *feathers.Context, path string) (*Service, error) {
+	service, ok := ctx.App.ServiceClass(path).(*Service)
+	if !ok {
+		return nil, httperrors.NewGeneralError("Management service " + path + " is not registered")
+	}
+	return service, nil
+}
+
+// AddVerification is a before create hook for the entity service which adds an unverified state and a verification token to the entity.
+/*
+Each entity of a multi create gets its own token. Use SendVerification as after hook to notify the new entities
+*/
+func AddVerification(path string) feathers.Hook {
+	return func(ctx *feathers.Context) error {
+		if ctx.Type != feathers.Before || ctx.Method != feathers.Create {
+			return httperrors.NewGeneralError("AddVerification must be used as a before create hook")
+		}
+		service, err := managementService(ctx, path)
+		if err != nil {
+			return err
+		}
+		items, normalized := hooks.GetItemsNormalized(ctx)
+		tokens := make([]string, 0, len(items))
+		for _, item := range items {
+			token, fields := service.VerificationFields()
+			for key, value := range fields {
+				item[key] = value
+			}
+			tokens = append(tokens, token)
+		}
+		hooks.ReplaceItemsNormalized(ctx, items, normalized)
+		ctx.Params.Set(verifyTokenParam, tokens)
+		return nil
+	}
+}
+
+// SendVerification is an after create hook for the entity service which sends the tokens added by AddVerification to the created entities
+func SendVerification(path string) feathers.Hook {
+	return func(ctx *feathers.Context) error {
+		tokens, ok := ctx.Params.Get(verifyTokenParam).([]string)
+		if !ok {
+			return nil
+		}
+		service, err := managementService(ctx, path)
+		if err != nil {
+			return err
+		}
+		entities, _ := hooks.GetItemsNormalized(ctx)
+		if len(entities) != len(tokens) {
+			return httperrors.NewGeneralError("Created entities do not match the verification tokens")
+		}
+		// created entities are in the order of the data
+		for i, entity := range entities {
+			if err := service.notify(ctx, ActionResendVerifySignup, entity, tokens[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// RemoveVerification is an after hook which removes the token fields from results of external calls
+func RemoveVerification() feathers.Hook {
+	return func(ctx *feathers.Context) error {
+		if ctx.Params.Provider == "" {
+			return nil
+		}
+		return hooks.Discard(FieldVerifyToken, FieldVerifyExpires, FieldResetToken, FieldResetExpires)(ctx)
+	}
+}
@@ -0,0 +1,449 @@
+// Package management implements signup verification and password reset (similar to feathers-authentication-management)
+package management
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/tobiasbeck/feathers-go/auth"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// Notifier actions
+const (
+	// ActionResendVerifySignup sends the verification token to a new or unverified entity
+	ActionResendVerifySignup = "resendVerifySignup"
+	// ActionVerifySignup confirms a successful verification
+	ActionVerifySignup = "verifySignup"
+	// ActionSendResetPwd sends the password reset token
+	ActionSendResetPwd = "sendResetPwd"
+	// ActionResetPwd confirms a successful password reset
+	ActionResetPwd = "resetPwd"
+)
+
+// Notifier notifies entities (e.g. by email). token is the plain token for ActionResendVerifySignup and ActionSendResetPwd (empty otherwise)
+type Notifier interface {
+	Notify(ctx context.Context, action string, entity map[string]interface{}, token string) error
+}
+
+// NotifierFunc is a function which implements Notifier
+type NotifierFunc func(ctx context.Context, action string, entity map[string]interface{}, token string) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, action string, entity map[string]interface{}, token string) error {
+	return f(ctx, action, entity, token)
+}
+
+// Entity fields used by the service
+const (
+	FieldIsVerified    = "isVerified"
+	FieldVerifyToken   = "verifyToken"
+	FieldVerifyExpires = "verifyExpires"
+	FieldResetToken    = "resetToken"
+	FieldResetExpires  = "resetExpires"
+)
+
+// Model is the data of a management call (`{"action": "sendResetPwd", "value": {"email": "..."}}`)
+type Model struct {
+	Action string      `mapstructure:"action" validate:"required,oneof=resendVerifySignup verifySignupLong sendResetPwd resetPwdLong"`
+	Value  interface{} `mapstructure:"value" validate:"required"`
+}
+
+func NewModel() interface{} {
+	return &Model{}
+}
+
+type options struct {
+	// Service is the entity service (defaults to `authentication.service`)
+	Service string `mapstructure:"service"`
+	// IdentifyUserProps are the fields which identify an entity in resendVerifySignup and sendResetPwd
+	IdentifyUserProps []string `mapstructure:"identifyUserProps"`
+	// PasswordField is the field containing the password hash
+	PasswordField string `mapstructure:"passwordField"`
+	// VerifyDelay is the lifetime of verification tokens
+	VerifyDelay interface{} `mapstructure:"verifyDelay"`
+	// ResetDelay is the lifetime of password reset tokens
+	ResetDelay interface{} `mapstructure:"resetDelay"`
+}
+
+// Service handles the management actions through create.
+/*
+Actions (`value`):
+`resendVerifySignup` (`{"email": "..."}`) creates a new verification token and notifies the entity if it is not verified (returns `{}`),
+`verifySignupLong` (`"<token>"`) verifies the entity of the token,
+`sendResetPwd` (`{"email": "..."}`) creates a password reset token and notifies the entity (returns `{}`),
+`resetPwdLong` (`{"token": "<token>", "password": "..."}`) sets the password of the entity of the token.
+resendVerifySignup and sendResetPwd return `{}` also if no entity is found, so callers cannot find out which accounts exist.
+Tokens are only stored as sha256 hash and expire after `verifyDelay` (default 5d) or `resetDelay` (default 2h).
+Passwords are hashed with auth.GeneratePasswordHash, so the entity service should only hash passwords of external calls
+*/
+type Service struct {
+	*feathers.BaseService
+	*feathers.ModelService
+	// Notifier is notified about all actions
+	Notifier    Notifier
+	app         *feathers.App
+	options     options
+	verifyDelay time.Duration
+	resetDelay  time.Duration
+}
+
+func (s *Service) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	model := Model{}
+	if err := s.MapAndValidateStruct(data, &model); err != nil {
+		return nil, httperrors.NewBadRequest(err.Error(), nil)
+	}
+	var entity map[string]interface{}
+	var err error
+	switch model.Action {
+	case "resendVerifySignup":
+		entity, err = s.resendVerifySignup(ctx, model.Value)
+	case "verifySignupLong":
+		entity, err = s.verifySignup(ctx, model.Value)
+	case "sendResetPwd":
+		entity, err = s.sendResetPwd(ctx, model.Value)
+	case "resetPwdLong":
+		entity, err = s.resetPwd(ctx, model.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch model.Action {
+	case "resendVerifySignup", "sendResetPwd":
+		// the caller only identified the entity, so the entity is not returned
+		return map[string]interface{}{}, nil
+	}
+	return Sanitize(entity, s.options.PasswordField), nil
+}
+
+func (s *Service) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *Service) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *Service) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *Service) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *Service) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *Service) resendVerifySignup(ctx context.Context, value interface{}) (map[string]interface{}, error) {
+	entity, err := s.identify(ctx, value)
+	if err != nil || entity == nil {
+		return nil, err
+	}
+	if verified, _ := entity[FieldIsVerified].(bool); verified {
+		return nil, nil
+	}
+	token, fields := s.VerificationFields()
+	entity, err = s.patch(ctx, entity, fields)
+	if err != nil {
+		return nil, err
+	}
+	return entity, s.notify(ctx, ActionResendVerifySignup, entity, token)
+}
+
+func (s *Service) verifySignup(ctx context.Context, value interface{}) (map[string]interface{}, error) {
+	token, ok := value.(string)
+	if !ok || token == "" {
+		return nil, httperrors.NewBadRequest("Expected value to be a token", nil)
+	}
+	entity, err := s.findByToken(ctx, FieldVerifyToken, FieldVerifyExpires, token)
+	if err != nil {
+		return nil, err
+	}
+	entity, err = s.patch(ctx, entity, map[string]interface{}{
+		FieldIsVerified:    true,
+		FieldVerifyToken:   nil,
+		FieldVerifyExpires: nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entity, s.notify(ctx, ActionVerifySignup, entity, "")
+}
+
+func (s *Service) sendResetPwd(ctx context.Context, value interface{}) (map[string]interface{}, error) {
+	entity, err := s.identify(ctx, value)
+	if err != nil || entity == nil {
+		return nil, err
+	}
+	token := newToken()
+	entity, err = s.patch(ctx, entity, map[string]interface{}{
+		FieldResetToken:   HashToken(token),
+		FieldResetExpires: expires(s.resetDelay),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entity, s.notify(ctx, ActionSendResetPwd, entity, token)
+}
+
+func (s *Service) resetPwd(ctx context.Context, value interface{}) (map[string]interface{}, error) {
+	values := struct {
+		Token    string `mapstructure:"token"`
+		Password string `mapstructure:"password"`
+	}{}
+	if err := mapstructure.Decode(value, &values); err != nil || values.Token == "" || values.Password == "" {
+		return nil, httperrors.NewBadRequest("Expected value to contain token and password", nil)
+	}
+	entity, err := s.findByToken(ctx, FieldResetToken, FieldResetExpires, values.Token)
+	if err != nil {
+		return nil, err
+	}
+	password, err := auth.GeneratePasswordHash(s.app, values.Password)
+	if err != nil {
+		return nil, err
+	}
+	entity, err = s.patch(ctx, entity, map[string]interface{}{
+		s.options.PasswordField: password,
+		FieldResetToken:         nil,
+		FieldResetExpires:       nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entity, s.notify(ctx, ActionResetPwd, entity, "")
+}
+
+// VerificationFields creates a new verification token and returns it with the entity fields which store it
+func (s *Service) VerificationFields() (string, map[string]interface{}) {
+	token := newToken()
+	return token, map[string]interface{}{
+		FieldIsVerified:    false,
+		FieldVerifyToken:   HashToken(token),
+		FieldVerifyExpires: expires(s.verifyDelay),
+	}
+}
+
+func (s *Service) entityService() (feathers.Service, error) {
+	service := s.app.Service(s.options.Service)
+	if service == nil {
+		return nil, httperrors.NewGeneralError("Entity service " + s.options.Service + " is not registered")
+	}
+	return service, nil
+}
+
+// find returns the first entity matching query
+func (s *Service) find(ctx context.Context, query map[string]interface{}) (map[string]interface{}, error) {
+	service, err := s.entityService()
+	if err != nil {
+		return nil, err
+	}
+	params := feathers.NewParamsQuery(query)
+	params.Set("paginate", false)
+	entities, err := feathers.ToMapSlice(service.Find(ctx, *params))
+	if err != nil {
+		return nil, httperrors.Convert(err)
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	return entities[0], nil
+}
+
+// identify finds the entity by one of IdentifyUserProps of value (nil if there is none). Identifiers have to be strings,
+// so they cannot contain query operators
+func (s *Service) identify(ctx context.Context, value interface{}) (map[string]interface{}, error) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, httperrors.NewBadRequest("Expected value to identify a user", nil)
+	}
+	for _, prop := range s.options.IdentifyUserProps {
+		if rawIdentifier, ok := values[prop]; ok {
+			identifier, ok := rawIdentifier.(string)
+			if !ok || identifier == "" {
+				return nil, httperrors.NewBadRequest(fmt.Sprintf("Expected %s to be a non-empty string", prop), nil)
+			}
+			return s.find(ctx, map[string]interface{}{prop: identifier})
+		}
+	}
+	return nil, httperrors.NewBadRequest(fmt.Sprintf("Expected value to contain one of %v", s.options.IdentifyUserProps), nil)
+}
+
+// findByToken finds the entity of a token which is not expired
+func (s *Service) findByToken(ctx context.Context, tokenField string, expiresField string, token string) (map[string]interface{}, error) {
+	entity, err := s.find(ctx, map[string]interface{}{tokenField: HashToken(token)})
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return nil, httperrors.NewBadRequest("Token was not found", nil)
+	}
+	if expiresAt, ok := toInt64(entity[expiresField]); !ok || expiresAt < time.Now().UnixNano()/int64(time.Millisecond) {
+		return nil, httperrors.NewBadRequest("Token has expired", nil)
+	}
+	return entity, nil
+}
+
+func (s *Service) patch(ctx context.Context, entity map[string]interface{}, data map[string]interface{}) (map[string]interface{}, error) {
+	service, err := s.entityService()
+	if err != nil {
+		return nil, err
+	}
+	id, err := entityID(entity)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err.Error())
+	}
+	result, err := feathers.ToMap(service.Patch(ctx, id, data, *feathers.NewParams()))
+	if err != nil {
+		return nil, httperrors.Convert(err)
+	}
+	return result, nil
+}
+
+func (s *Service) notify(ctx context.Context, action string, entity map[string]interface{}, token string) error {
+	if s.Notifier == nil {
+		return nil
+	}
+	if err := s.Notifier.Notify(ctx, action, Sanitize(entity, s.options.PasswordField), token); err != nil {
+		return httperrors.Convert(err)
+	}
+	return nil
+}
+
+// Sanitize returns a copy of entity without password and token fields
+func Sanitize(entity map[string]interface{}, passwordField string) map[string]interface{} {
+	if entity == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(entity))
+	for key, value := range entity {
+		switch key {
+		case passwordField, FieldVerifyToken, FieldVerifyExpires, FieldResetToken, FieldResetExpires:
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// HashToken returns the sha256 hash of a token (tokens are stored hashed)
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// expires returns the expiry (unix milliseconds like feathers-authentication-management) of a token with lifetime delay
+func expires(delay time.Duration) int64 {
+	return time.Now().Add(delay).UnixNano() / int64(time.Millisecond)
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+type hexable interface {
+	Hex() string
+}
+
+func entityID(entity map[string]interface{}) (string, error) {
+	switch id := entity["_id"].(type) {
+	case string:
+		return id, nil
+	case hexable:
+		return id.Hex(), nil
+	case fmt.Stringer:
+		return id.String(), nil
+	}
+	return "", errors.New("Cannot stringify entity id")
+}
+
+// Configure registers the management service (default path `authManagement`).
+/*
+Supported config keys:
+`path` path of the service, `notifier` the Notifier.
+Options are read from `authentication.management` (service, identifyUserProps (default email), passwordField (default password),
+verifyDelay (default 5d), resetDelay (default 2h))
+*/
+func Configure(app *feathers.App, config map[string]interface{}) error {
+	service, err := New(app)
+	if err != nil {
+		return err
+	}
+	if notifier, ok := config["notifier"]; ok {
+		service.Notifier, ok = notifier.(Notifier)
+		if !ok {
+			return errors.New("notifier does not implement management.Notifier")
+		}
+	}
+	path, ok := config["path"].(string)
+	if !ok {
+		path = "authManagement"
+	}
+	app.AddService(path, service)
+	return nil
+}
+
+// New creates a management service with the options of `authentication.management`
+func New(app *feathers.App) (*Service, error) {
+	service := &Service{
+		BaseService:  &feathers.BaseService{},
+		ModelService: feathers.NewModelService(NewModel),
+		app:          app,
+	}
+	authConfig, ok := app.Config("authentication")
+	if !ok {
+		return nil, errors.New("No app configuration of auth is set")
+	}
+	authMap, _ := authConfig.(map[string]interface{})
+	mapstructure.Decode(authMap["management"], &service.options)
+	if service.options.Service == "" {
+		service.options.Service, _ = authMap["service"].(string)
+	}
+	if len(service.options.IdentifyUserProps) == 0 {
+		service.options.IdentifyUserProps = []string{"email"}
+	}
+	if service.options.PasswordField == "" {
+		service.options.PasswordField = "password"
+	}
+	var err error
+	if service.verifyDelay, err = parseDelay(service.options.VerifyDelay, "5d"); err != nil {
+		return nil, err
+	}
+	if service.resetDelay, err = parseDelay(service.options.ResetDelay, "2h"); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func parseDelay(value interface{}, defaultValue string) (time.Duration, error) {
+	if value == nil {
+		value = defaultValue
+	}
+	return auth.ParseDuration(value)
+}
@@ -0,0 +1,257 @@
+package management
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testUserService struct {
+	*feathers.BaseService
+	users map[string]map[string]interface{}
+}
+
+func (s *testUserService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	result := []map[string]interface{}{}
+	for _, user := range s.users {
+		matches := true
+		for key, value := range params.Query {
+			if user[key] != value {
+				matches = false
+			}
+		}
+		if matches {
+			result = append(result, user)
+		}
+	}
+	return result, nil
+}
+
+func (s *testUserService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	if user, ok := s.users[id]; ok {
+		return user, nil
+	}
+	return nil, httperrors.NewNotFound("User not found")
+}
+
+func (s *testUserService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	data["_id"] = fmt.Sprint(len(s.users) + 1)
+	s.users[data["_id"].(string)] = data
+	return data, nil
+}
+
+func (s *testUserService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *testUserService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, httperrors.NewNotFound("User not found")
+	}
+	for key, value := range data {
+		user[key] = value
+	}
+	return user, nil
+}
+
+func (s *testUserService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+type notification struct {
+	action string
+	entity map[string]interface{}
+	token  string
+}
+
+func newTestApp(t *testing.T) (*feathers.App, *testUserService, *[]notification) {
+	app := feathers.NewApp()
+	app.SetConfig("authentication", map[string]interface{}{
+		"secret":     "supersecret",
+		"service":    "users",
+		"bcryptCost": bcrypt.MinCost,
+	})
+	users := &testUserService{
+		BaseService: &feathers.BaseService{},
+		users:       map[string]map[string]interface{}{},
+	}
+	users.Hooks.Before.Create = []feathers.Hook{AddVerification("authManagement")}
+	users.Hooks.After.Create = []feathers.Hook{SendVerification("authManagement")}
+	app.AddService("users", users)
+
+	notifications := &[]notification{}
+	err := Configure(app, map[string]interface{}{
+		"notifier": NotifierFunc(func(ctx context.Context, action string, entity map[string]interface{}, token string) error {
+			*notifications = append(*notifications, notification{action, entity, token})
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Could not configure management: %s", err)
+	}
+	return app, users, notifications
+}
+
+func call(app *feathers.App, action string, value interface{}) (interface{}, error) {
+	return app.Service("authManagement").Create(context.Background(), map[string]interface{}{
+		"action": action,
+		"value":  value,
+	}, *feathers.NewParams())
+}
+
+func TestVerifySignup(t *testing.T) {
+	app, users, notifications := newTestApp(t)
+	_, err := app.Service("users").Create(context.Background(), map[string]interface{}{"email": "test@example.com"}, *feathers.NewParams())
+	if err != nil {
+		t.Fatalf("Could not create user: %s", err)
+	}
+	if len(*notifications) != 1 || (*notifications)[0].action != ActionResendVerifySignup {
+		t.Fatalf("Verification was not sent: %#v", *notifications)
+	}
+	token := (*notifications)[0].token
+	if users.users["1"][FieldVerifyToken] == token {
+		t.Errorf("Token is not stored hashed")
+	}
+	if _, ok := (*notifications)[0].entity[FieldVerifyToken]; ok {
+		t.Errorf("Notified entity is not sanitized")
+	}
+
+	result, err := call(app, "verifySignupLong", token)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if result.(map[string]interface{})[FieldIsVerified] != true || users.users["1"][FieldVerifyToken] != nil {
+		t.Errorf("User was not verified: %#v", users.users["1"])
+	}
+
+	_, err = call(app, "verifySignupLong", token)
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 400 {
+		t.Errorf("Expected BadRequest for used token, got: %v", err)
+	}
+	sent := len(*notifications)
+	result, err = call(app, "resendVerifySignup", map[string]interface{}{"email": "test@example.com"})
+	if err != nil || len(result.(map[string]interface{})) != 0 || len(*notifications) != sent {
+		t.Errorf("Verified user should not be notified: %#v %v", result, err)
+	}
+}
+
+func TestUnknownUser(t *testing.T) {
+	app, users, notifications := newTestApp(t)
+	users.users["1"] = map[string]interface{}{"_id": "1", "email": "test@example.com"}
+	for _, action := range []string{"sendResetPwd", "resendVerifySignup"} {
+		known, knownErr := call(app, action, map[string]interface{}{"email": "test@example.com"})
+		unknown, unknownErr := call(app, action, map[string]interface{}{"email": "unknown@example.com"})
+		if knownErr != nil || unknownErr != nil || !reflect.DeepEqual(known, unknown) {
+			t.Errorf("%s reveals if the user exists: %#v (%v) and %#v (%v)", action, known, knownErr, unknown, unknownErr)
+		}
+	}
+	for _, notification := range *notifications {
+		if notification.entity["_id"] != "1" {
+			t.Errorf("Unknown user was notified: %#v", notification)
+		}
+	}
+	if len(*notifications) != 2 {
+		t.Errorf("Expected notifications of the known user, got: %#v", *notifications)
+	}
+}
+
+func TestVerifySignupMultiCreate(t *testing.T) {
+	app, users, notifications := newTestApp(t)
+	users.Multi = []feathers.RestMethod{feathers.Create}
+	_, err := app.Service("users").(feathers.MultiCreateService).CreateMany(context.Background(), []map[string]interface{}{
+		{"email": "a@example.com"},
+		{"email": "b@example.com"},
+	}, *feathers.NewParams())
+	if err != nil {
+		t.Fatalf("Could not create users: %s", err)
+	}
+	if len(*notifications) != 2 {
+		t.Fatalf("Verification was not sent to all users: %#v", *notifications)
+	}
+	for i, notification := range *notifications {
+		user := users.users[notification.entity["_id"].(string)]
+		if user[FieldIsVerified] != false || user[FieldVerifyToken] != HashToken(notification.token) {
+			t.Errorf("Failed #%d: verification was not added: %#v", i+1, user)
+		}
+	}
+	if (*notifications)[0].token == (*notifications)[1].token {
+		t.Errorf("Users share the verification token")
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	app, users, notifications := newTestApp(t)
+	users.users["1"] = map[string]interface{}{"_id": "1", "email": "test@example.com", "password": "old"}
+
+	result, err := call(app, "sendResetPwd", map[string]interface{}{"email": "test@example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(result.(map[string]interface{})) != 0 {
+		t.Errorf("Result contains the user: %#v", result)
+	}
+	if len(*notifications) != 1 || (*notifications)[0].action != ActionSendResetPwd {
+		t.Fatalf("Reset token was not sent: %#v", *notifications)
+	}
+	token := (*notifications)[0].token
+
+	_, err = call(app, "resetPwdLong", map[string]interface{}{"token": "invalid", "password": "new"})
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 400 {
+		t.Errorf("Expected BadRequest for invalid token, got: %v", err)
+	}
+
+	result, err = call(app, "resetPwdLong", map[string]interface{}{"token": token, "password": "new"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := result.(map[string]interface{})["password"]; ok {
+		t.Errorf("Result contains the password")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(users.users["1"]["password"].(string)), []byte("new")); err != nil {
+		t.Errorf("Password was not reset: %s", err)
+	}
+	if (*notifications)[1].action != ActionResetPwd {
+		t.Errorf("Reset was not notified")
+	}
+
+	users.users["1"][FieldResetToken] = HashToken("expired")
+	users.users["1"][FieldResetExpires] = int64(1)
+	_, err = call(app, "resetPwdLong", map[string]interface{}{"token": "expired", "password": "new"})
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 400 {
+		t.Errorf("Expected BadRequest for expired token, got: %v", err)
+	}
+}
+
+func TestIdentifyRejectsOperators(t *testing.T) {
+	app, _, notifications := newTestApp(t)
+	// the memory service evaluates query operators like a database
+	users := memory.NewService(nil, nil)
+	app.AddService("users", users)
+	if _, err := users.Create(context.Background(), map[string]interface{}{"_id": "1", "email": "test@example.com"}, *feathers.NewParams()); err != nil {
+		t.Fatalf("Could not create user: %s", err)
+	}
+
+	for key, value := range []interface{}{
+		/* #1 */ map[string]interface{}{"email": map[string]interface{}{"$ne": ""}},
+		/* #2 */ map[string]interface{}{"email": map[string]interface{}{"$nin": []interface{}{"a@example.com"}}},
+		/* #3 */ map[string]interface{}{"email": []interface{}{"test@example.com"}},
+		/* #4 */ map[string]interface{}{"email": ""},
+	} {
+		for _, action := range []string{"sendResetPwd", "resendVerifySignup"} {
+			_, err := call(app, action, value)
+			if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 400 {
+				t.Errorf("Failed #%d: expected BadRequest for %s, got: %v", key+1, action, err)
+			}
+		}
+	}
+	if len(*notifications) != 0 {
+		t.Errorf("User was identified by an operator: %#v", *notifications)
+	}
+}
@@ -0,0 +1,263 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+// discovery is the part of the OpenID provider metadata (`/.well-known/openid-configuration`) used by the strategy
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// keyCache caches the verification keys of a JWKS by kid. Keys are reloaded if a token uses an unknown kid
+type keyCache struct {
+	client   *http.Client
+	uri      string
+	keys     map[string]jwt.Algorithm
+	loadedAt time.Time
+	lock     sync.Mutex
+}
+
+// minReload is the minimum time between two loads of the JWKS (protects the provider against unknown kids)
+const minReload = 10 * time.Second
+
+func (kc *keyCache) key(ctx context.Context, header jwt.Header) (jwt.Algorithm, error) {
+	kc.lock.Lock()
+	defer kc.lock.Unlock()
+	if key, ok := kc.find(header); ok {
+		return key, nil
+	}
+	if time.Since(kc.loadedAt) < minReload {
+		return nil, fmt.Errorf("unknown key %q", header.KeyID)
+	}
+	if err := kc.load(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := kc.find(header); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", header.KeyID)
+}
+
+// find returns the key for header. Tokens without kid can be used if the set contains a single key
+func (kc *keyCache) find(header jwt.Header) (jwt.Algorithm, bool) {
+	if key, ok := kc.keys[header.KeyID]; ok {
+		return key, true
+	}
+	if header.KeyID == "" && len(kc.keys) == 1 {
+		for _, key := range kc.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (kc *keyCache) load(ctx context.Context) error {
+	set := jsonWebKeySet{}
+	if err := getJSON(ctx, kc.client, kc.uri, &set); err != nil {
+		return fmt.Errorf("cannot load jwks: %w", err)
+	}
+	keys := map[string]jwt.Algorithm{}
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		algorithm, err := key.algorithm()
+		if err != nil {
+			continue
+		}
+		keys[key.KeyID] = algorithm
+	}
+	kc.keys = keys
+	kc.loadedAt = time.Now()
+	return nil
+}
+
+// algorithm creates the jwt algorithm of a RSA or EC key
+func (k jsonWebKey) algorithm() (jwt.Algorithm, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		key := jwt.RSAPublicKey(&rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		})
+		switch k.Algorithm {
+		case "RS384":
+			return jwt.NewRS384(key), nil
+		case "RS512":
+			return jwt.NewRS512(key), nil
+		case "", "RS256":
+			return jwt.NewRS256(key), nil
+		}
+	case "EC":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		switch k.Curve {
+		case "P-256":
+			return jwt.NewES256(jwt.ECDSAPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)})), nil
+		case "P-384":
+			return jwt.NewES384(jwt.ECDSAPublicKey(&ecdsa.PublicKey{Curve: elliptic.P384(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)})), nil
+		case "P-521":
+			return jwt.NewES512(jwt.ECDSAPublicKey(&ecdsa.PublicKey{Curve: elliptic.P521(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)})), nil
+		}
+	}
+	return nil, fmt.Errorf("key type %q (%s) is not supported", k.KeyType, k.Algorithm)
+}
+
+// keyResolver selects the verification key of a token by its header (one per verification)
+type keyResolver struct {
+	ctx       context.Context
+	cache     *keyCache
+	algorithm jwt.Algorithm
+}
+
+func (kr *keyResolver) Resolve(header jwt.Header) error {
+	algorithm, err := kr.cache.key(kr.ctx, header)
+	if err != nil {
+		return err
+	}
+	if algorithm.Name() != header.Algorithm {
+		return jwt.ErrAlgValidation
+	}
+	kr.algorithm = algorithm
+	return nil
+}
+
+func (kr *keyResolver) Name() string {
+	return kr.algorithm.Name()
+}
+
+func (kr *keyResolver) Sign(headerPayload []byte) ([]byte, error) {
+	return nil, errors.New("id token keys cannot sign")
+}
+
+func (kr *keyResolver) Size() int {
+	return kr.algorithm.Size()
+}
+
+func (kr *keyResolver) Verify(headerPayload, sig []byte) error {
+	return kr.algorithm.Verify(headerPayload, sig)
+}
+
+type idTokenClaims struct {
+	jwt.Payload
+	Nonce string `json:"nonce,omitempty"`
+	// AuthorizedParty is the client the token was issued to (set if there are multiple audiences)
+	AuthorizedParty string `json:"azp,omitempty"`
+}
+
+// verifyIDToken verifies the signature and the claims (iss, aud, azp, exp, iat, nonce) of an id token and returns all its claims
+func (s *Strategy) verifyIDToken(ctx context.Context, config *providerConfig, token string, nonce string) (map[string]interface{}, error) {
+	cache, err := s.keyCache(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	claims := idTokenClaims{}
+	now := time.Now()
+	validators := []jwt.Validator{
+		jwt.ExpirationTimeValidator(now),
+		jwt.IssuedAtValidator(now),
+		jwt.AudienceValidator(jwt.Audience{config.Key}),
+	}
+	if config.Issuer != "" {
+		validators = append(validators, jwt.IssuerValidator(config.Issuer))
+	}
+	resolver := &keyResolver{ctx: ctx, cache: cache}
+	if _, err := jwt.Verify([]byte(token), resolver, &claims, jwt.ValidatePayload(&claims.Payload, validators...)); err != nil {
+		return nil, err
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, errors.New("id token nonce is invalid")
+	}
+	if claims.AuthorizedParty != "" && claims.AuthorizedParty != config.Key {
+		return nil, errors.New("id token was issued to another client")
+	}
+
+	profile := map[string]interface{}{}
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, &profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, uri string, target interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	return doJSON(client, request, target)
+}
+
+func doJSON(client *http.Client, request *http.Request, target interface{}) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		errorResponse := struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}{}
+		json.NewDecoder(response.Body).Decode(&errorResponse)
+		if errorResponse.Error != "" {
+			return fmt.Errorf("%s: %s", errorResponse.Error, errorResponse.ErrorDescription)
+		}
+		return fmt.Errorf("%s responded with status %d", request.URL.Host, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
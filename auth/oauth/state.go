@@ -0,0 +1,90 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pendingAuthorization is stored in a signed cookie until the provider redirects to the callback
+type pendingAuthorization struct {
+	State     string `json:"state"`
+	Verifier  string `json:"verifier"`
+	Nonce     string `json:"nonce"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func (s *Strategy) stateCookieName() string {
+	return "oauth_" + s.Name()
+}
+
+// stateKey returns the key the cookie is signed with. It is derived from the authentication secret so all instances of
+// an app accept the cookie, without a secret a random key of this instance is used
+func (s *Strategy) stateKey() []byte {
+	if secret := s.DefaultConfig().Secret; secret != "" {
+		key := sha256.Sum256([]byte("oauth-state:" + secret))
+		return key[:]
+	}
+	return s.randomKey
+}
+
+// setPending stores the pending authorization in a short-lived cookie, the server keeps no state for anonymous requests
+func (s *Strategy) setPending(response http.ResponseWriter, request *http.Request, config *providerConfig, pending pendingAuthorization) error {
+	payload, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(response, &http.Cookie{
+		Name:     s.stateCookieName(),
+		Value:    value + "." + s.sign(value),
+		Path:     "/oauth/" + s.Name(),
+		MaxAge:   int(PendingTimeout / time.Second),
+		HttpOnly: true,
+		Secure:   request.TLS != nil || strings.HasPrefix(config.RedirectURI, "https://"),
+		// the provider redirects to the callback with a top-level GET
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// pending returns the pending authorization of the state and removes the cookie
+func (s *Strategy) pending(response http.ResponseWriter, request *http.Request, state string) (*pendingAuthorization, error) {
+	cookie, err := request.Cookie(s.stateCookieName())
+	if err != nil {
+		return nil, errors.New("Invalid or expired oauth state")
+	}
+	http.SetCookie(response, &http.Cookie{
+		Name:     s.stateCookieName(),
+		Path:     "/oauth/" + s.Name(),
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.sign(parts[0]))) {
+		return nil, errors.New("Invalid or expired oauth state")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("Invalid or expired oauth state")
+	}
+	pending := &pendingAuthorization{}
+	if err := json.Unmarshal(payload, pending); err != nil {
+		return nil, errors.New("Invalid or expired oauth state")
+	}
+	if state == "" || !hmac.Equal([]byte(pending.State), []byte(state)) || pending.ExpiresAt < time.Now().Unix() {
+		return nil, errors.New("Invalid or expired oauth state")
+	}
+	return pending, nil
+}
+
+func (s *Strategy) sign(value string) string {
+	mac := hmac.New(sha256.New, s.stateKey())
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,405 @@
+// Package oauth implements an OAuth2 / OpenID Connect authentication strategy (authorization code flow with PKCE)
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/tobiasbeck/feathers-go/auth"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// providerConfig is the configuration of a provider (`authentication.oauth.<name>`)
+type providerConfig struct {
+	// Key is the client id
+	Key string `mapstructure:"key"`
+	// Secret is the client secret (can be empty for public clients)
+	Secret string `mapstructure:"secret"`
+	// Issuer of the provider. Endpoints which are not configured are discovered from `<issuer>/.well-known/openid-configuration`
+	Issuer                string   `mapstructure:"issuer"`
+	AuthorizationEndpoint string   `mapstructure:"authorizationEndpoint"`
+	TokenEndpoint         string   `mapstructure:"tokenEndpoint"`
+	UserinfoEndpoint      string   `mapstructure:"userinfoEndpoint"`
+	JwksURI               string   `mapstructure:"jwksUri"`
+	Scope                 []string `mapstructure:"scope"`
+	// RedirectURI is the callback url registered at the provider (`<origin>/oauth/<name>/callback`)
+	RedirectURI string `mapstructure:"redirectUri"`
+	// Redirect is the frontend url which receives `#access_token=<token>` or `#error=<message>` (overwrites `oauth.redirect`)
+	Redirect string `mapstructure:"redirect"`
+}
+
+// profileParam is the params field containing the userinfo fetched by the callback (providers without id tokens)
+const profileParam = "oauth.profile"
+
+// PendingTimeout is the time a user has to complete the login at the provider
+const PendingTimeout = 10 * time.Minute
+
+// Strategy authenticates users with an OAuth2 / OpenID Connect provider. The name of the strategy is the name of the provider.
+/*
+The provider is configured in `authentication.oauth.<name>` (key, secret, issuer, scope, redirectUri, redirect).
+The strategy mounts `/oauth/<name>` (redirects to the provider) and `/oauth/<name>/callback` on the http server.
+State, nonce and PKCE verifier are kept in a signed cookie until the callback.
+Clients can also authenticate with `{"strategy": "<name>", "idToken": "..."}` (the audience has to be the client).
+Access tokens are not accepted from clients because they are not bound to the client, they are only used by the callback
+to load the userinfo of providers without id tokens.
+The entity is looked up by `<name>Id` (the `sub` claim) and created if it does not exist
+*/
+type Strategy struct {
+	*auth.BaseAuthStrategy
+	// Client is used for all requests to the provider
+	Client    *http.Client
+	discovery *discovery
+	keys      *keyCache
+	randomKey []byte
+	lock      sync.Mutex
+}
+
+func (s *Strategy) providerConfig(ctx context.Context) (*providerConfig, error) {
+	config := &providerConfig{}
+	oauthConfig, _ := s.Config("oauth")
+	oauthMap, _ := oauthConfig.(map[string]interface{})
+	providerMap, ok := oauthMap[s.Name()]
+	if !ok {
+		return nil, errors.New("oauth provider " + s.Name() + " is not configured")
+	}
+	if err := mapstructure.Decode(providerMap, config); err != nil {
+		return nil, err
+	}
+	if config.Redirect == "" {
+		config.Redirect, _ = oauthMap["redirect"].(string)
+	}
+	if len(config.Scope) == 0 {
+		config.Scope = []string{"openid", "email", "profile"}
+	}
+	if config.Issuer != "" && (config.AuthorizationEndpoint == "" || config.TokenEndpoint == "" || config.JwksURI == "") {
+		metadata, err := s.discover(ctx, config.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		if config.AuthorizationEndpoint == "" {
+			config.AuthorizationEndpoint = metadata.AuthorizationEndpoint
+		}
+		if config.TokenEndpoint == "" {
+			config.TokenEndpoint = metadata.TokenEndpoint
+		}
+		if config.UserinfoEndpoint == "" {
+			config.UserinfoEndpoint = metadata.UserinfoEndpoint
+		}
+		if config.JwksURI == "" {
+			config.JwksURI = metadata.JwksURI
+		}
+	}
+	return config, nil
+}
+
+// discover loads the provider metadata once
+func (s *Strategy) discover(ctx context.Context, issuer string) (*discovery, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.discovery != nil {
+		return s.discovery, nil
+	}
+	metadata := &discovery{}
+	if err := getJSON(ctx, s.Client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", metadata); err != nil {
+		return nil, fmt.Errorf("cannot discover oauth provider: %w", err)
+	}
+	s.discovery = metadata
+	return metadata, nil
+}
+
+func (s *Strategy) keyCache(ctx context.Context, config *providerConfig) (*keyCache, error) {
+	if config.JwksURI == "" {
+		return nil, errors.New("jwksUri of oauth provider " + s.Name() + " is not configured")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.keys == nil || s.keys.uri != config.JwksURI {
+		s.keys = &keyCache{client: s.Client, uri: config.JwksURI}
+	}
+	return s.keys, nil
+}
+
+// Setup registers the routes of the strategy (called by the authentication service)
+func (s *Strategy) Setup(app *feathers.App) {
+	app.AddProvider("oauth/"+s.Name(), s)
+}
+
+// Listen mounts the redirect and callback routes on the http server
+func (s *Strategy) Listen(port int, mux *http.ServeMux) {
+	mux.HandleFunc("/oauth/"+s.Name(), s.handleRedirect)
+	mux.HandleFunc("/oauth/"+s.Name()+"/callback", s.handleCallback)
+}
+
+// Publish is required by the provider interface. The strategy does not publish events
+func (s *Strategy) Publish(room string, event string, data interface{}, path string, provider string) {
+}
+
+// handleRedirect redirects to the authorization endpoint of the provider
+func (s *Strategy) handleRedirect(response http.ResponseWriter, request *http.Request) {
+	config, err := s.providerConfig(request.Context())
+	if err != nil {
+		s.respondError(response, request, nil, httperrors.NewGeneralError(err.Error()))
+		return
+	}
+	state, verifier, nonce := randomString(), randomString(), randomString()
+	err = s.setPending(response, request, config, pendingAuthorization{
+		State:     state,
+		Verifier:  verifier,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(PendingTimeout).Unix(),
+	})
+	if err != nil {
+		s.respondError(response, request, nil, httperrors.NewGeneralError(err.Error()))
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {config.Key},
+		"redirect_uri":          {config.RedirectURI},
+		"scope":                 {strings.Join(config.Scope, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(config.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	http.Redirect(response, request, config.AuthorizationEndpoint+separator+query.Encode(), http.StatusFound)
+}
+
+// handleCallback exchanges the authorization code and authenticates through the authentication service
+func (s *Strategy) handleCallback(response http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+	config, err := s.providerConfig(ctx)
+	if err != nil {
+		s.respondError(response, request, nil, httperrors.NewGeneralError(err.Error()))
+		return
+	}
+	query := request.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		s.respondError(response, request, config, httperrors.NewNotAuthenticated(providerError+": "+query.Get("error_description"), nil))
+		return
+	}
+
+	pending, err := s.pending(response, request, query.Get("state"))
+	if err != nil {
+		s.respondError(response, request, config, httperrors.NewNotAuthenticated(err.Error(), nil))
+		return
+	}
+
+	tokens, err := s.exchangeCode(ctx, config, query.Get("code"), pending.Verifier)
+	if err != nil {
+		s.respondError(response, request, config, httperrors.NewNotAuthenticated(err.Error(), nil))
+		return
+	}
+	data := map[string]interface{}{
+		"strategy": s.Name(),
+	}
+	params := feathers.NewParams()
+	params.Provider = "http"
+	if tokens.IDToken != "" {
+		data["idToken"] = tokens.IDToken
+		data["nonce"] = pending.Nonce
+	} else {
+		// the access token was issued to this client by the code exchange, so its userinfo can be trusted
+		profile, err := s.userinfo(ctx, config, tokens.AccessToken)
+		if err != nil {
+			s.respondError(response, request, config, httperrors.NewNotAuthenticated(err.Error(), nil))
+			return
+		}
+		params.Set(profileParam, profile)
+	}
+	result, err := s.App().Service("authentication").Create(ctx, data, *params)
+	if err != nil {
+		s.respondError(response, request, config, httperrors.Convert(err))
+		return
+	}
+	resultMap, _ := result.(map[string]interface{})
+	accessToken, _ := resultMap["accessToken"].(string)
+	if config.Redirect != "" {
+		http.Redirect(response, request, config.Redirect+"#access_token="+url.QueryEscape(accessToken), http.StatusFound)
+		return
+	}
+	respondJSON(response, http.StatusCreated, result)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// exchangeCode exchanges an authorization code at the token endpoint (client_secret_post)
+func (s *Strategy) exchangeCode(ctx context.Context, config *providerConfig, code string, verifier string) (*tokenResponse, error) {
+	if code == "" {
+		return nil, errors.New("No authorization code")
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {config.RedirectURI},
+		"client_id":     {config.Key},
+		"code_verifier": {verifier},
+	}
+	if config.Secret != "" {
+		form.Set("client_secret", config.Secret)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	tokens := &tokenResponse{}
+	if err := doJSON(s.Client, request, tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" && tokens.AccessToken == "" {
+		return nil, errors.New("Token response does not contain a token")
+	}
+	return tokens, nil
+}
+
+// profile returns the verified claims of an id token
+func (s *Strategy) profile(ctx context.Context, config *providerConfig, data auth.Model) (map[string]interface{}, error) {
+	if idToken, ok := data.Params["idToken"].(string); ok && idToken != "" {
+		nonce, _ := data.Params["nonce"].(string)
+		return s.verifyIDToken(ctx, config, idToken, nonce)
+	}
+	return nil, errors.New("No idToken sent")
+}
+
+// userinfo returns the userinfo of an access token which was issued to this client
+func (s *Strategy) userinfo(ctx context.Context, config *providerConfig, accessToken string) (map[string]interface{}, error) {
+	if config.UserinfoEndpoint == "" {
+		return nil, errors.New("userinfoEndpoint of oauth provider " + s.Name() + " is not configured")
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, config.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Accept", "application/json")
+	profile := map[string]interface{}{}
+	if err := doJSON(s.Client, request, &profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// findOrCreateEntity looks up the entity by `<name>Id` and creates it if it does not exist
+func (s *Strategy) findOrCreateEntity(ctx context.Context, profile map[string]interface{}) (interface{}, error) {
+	subject, ok := profile["sub"].(string)
+	if !ok || subject == "" {
+		return nil, errors.New("Profile does not contain a subject")
+	}
+	entityService, ok := s.EntityService()
+	if !ok {
+		return nil, errors.New("Entity service is not registered")
+	}
+	idField := s.Name() + "Id"
+	findParams := feathers.NewParamsQuery(map[string]interface{}{idField: subject})
+	findParams.Set("paginate", false)
+	entities, err := feathers.ToMapSlice(entityService.Find(ctx, *findParams))
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) > 0 {
+		return entities[0], nil
+	}
+
+	data := map[string]interface{}{idField: subject}
+	// unverified emails could claim the address of another user
+	if email, ok := profile["email"].(string); ok && email != "" && emailVerified(profile) {
+		data["email"] = email
+	}
+	return entityService.Create(ctx, data, *feathers.NewParams())
+}
+
+// emailVerified returns if the `email_verified` claim is true (some providers send it as string)
+func emailVerified(profile map[string]interface{}) bool {
+	switch verified := profile["email_verified"].(type) {
+	case bool:
+		return verified
+	case string:
+		return verified == "true"
+	}
+	return false
+}
+
+func (s *Strategy) Authenticate(ctx context.Context, data auth.Model, params feathers.Params) (map[string]interface{}, error) {
+	defaultConfig := s.DefaultConfig()
+	config, err := s.providerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the userinfo of the callback can only be set by the server
+	profile, ok := params.Get(profileParam).(map[string]interface{})
+	if !ok {
+		profile, err = s.profile(ctx, config, data)
+		if err != nil {
+			return nil, httperrors.NewNotAuthenticated(err.Error(), nil)
+		}
+	}
+	entity, err := s.findOrCreateEntity(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{
+		"authentication": map[string]interface{}{
+			"strategy": s.Name(),
+			"profile":  profile,
+		},
+	}
+	result[defaultConfig.Entity] = entity
+	return result, nil
+}
+
+// respondError redirects to the frontend with `#error=<message>` or responds with the error if no redirect is configured
+func (s *Strategy) respondError(response http.ResponseWriter, request *http.Request, config *providerConfig, err httperrors.FeathersError) {
+	if config != nil && config.Redirect != "" {
+		http.Redirect(response, request, config.Redirect+"#error="+url.QueryEscape(err.Message), http.StatusFound)
+		return
+	}
+	respondJSON(response, err.Code, err)
+}
+
+func respondJSON(response http.ResponseWriter, code int, data interface{}) {
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(code)
+	json.NewEncoder(response).Encode(data)
+}
+
+// randomString returns 32 random bytes (base64url encoded). Used for state, nonce and the PKCE code verifier
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// New creates a new oauth strategy. The name it is registered with selects the provider configuration
+func New() *Strategy {
+	return &Strategy{
+		BaseAuthStrategy: &auth.BaseAuthStrategy{},
+		Client:           &http.Client{Timeout: 10 * time.Second},
+		randomKey:        []byte(randomString()),
+	}
+}
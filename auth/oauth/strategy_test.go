@@ -0,0 +1,330 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/tobiasbeck/feathers-go/auth"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+type testUserService struct {
+	*feathers.BaseService
+	users []map[string]interface{}
+}
+
+func (s *testUserService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	result := []map[string]interface{}{}
+	for _, user := range s.users {
+		if user["mockId"] == params.Query["mockId"] {
+			result = append(result, user)
+		}
+	}
+	return result, nil
+}
+
+func (s *testUserService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	for _, user := range s.users {
+		if user["_id"] == id {
+			return user, nil
+		}
+	}
+	return nil, httperrors.NewNotFound("User not found")
+}
+
+func (s *testUserService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	data["_id"] = data["mockId"]
+	s.users = append(s.users, data)
+	return data, nil
+}
+
+func (s *testUserService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *testUserService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *testUserService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+// mockProvider is a minimal OpenID provider (discovery, jwks, authorize, token, userinfo)
+type mockProvider struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	// withoutIDToken only returns an access token like plain OAuth2 providers
+	withoutIDToken bool
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Could not generate key: %s", err)
+	}
+	provider := &mockProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(response http.ResponseWriter, request *http.Request) {
+		respondJSON(response, 200, map[string]interface{}{
+			"issuer":                 provider.URL,
+			"authorization_endpoint": provider.URL + "/authorize",
+			"token_endpoint":         provider.URL + "/token",
+			"jwks_uri":               provider.URL + "/jwks",
+			"userinfo_endpoint":      provider.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/jwks", func(response http.ResponseWriter, request *http.Request) {
+		respondJSON(response, 200, map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "RSA",
+				"kid": "mock",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/authorize", func(response http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "client" {
+			http.Error(response, "invalid request", 400)
+			return
+		}
+		provider.challenge = query.Get("code_challenge")
+		provider.nonce = query.Get("nonce")
+		http.Redirect(response, request, query.Get("redirect_uri")+"?code=secretcode&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(response http.ResponseWriter, request *http.Request) {
+		request.ParseForm()
+		verifier := sha256.Sum256([]byte(request.Form.Get("code_verifier")))
+		if request.Form.Get("code") != "secretcode" || base64.RawURLEncoding.EncodeToString(verifier[:]) != provider.challenge {
+			respondJSON(response, 400, map[string]interface{}{"error": "invalid_grant"})
+			return
+		}
+		tokens := map[string]interface{}{"access_token": "provider-token"}
+		if !provider.withoutIDToken {
+			tokens["id_token"] = provider.idToken(t, provider.nonce)
+		}
+		respondJSON(response, 200, tokens)
+	})
+	mux.HandleFunc("/userinfo", func(response http.ResponseWriter, request *http.Request) {
+		// any token of the provider is accepted, like tokens issued to other clients
+		if !strings.HasPrefix(request.Header.Get("Authorization"), "Bearer ") {
+			respondJSON(response, 401, map[string]interface{}{"error": "invalid_token"})
+			return
+		}
+		respondJSON(response, 200, map[string]interface{}{"sub": "43", "email": "other@example.com", "email_verified": false})
+	})
+	provider.Server = httptest.NewServer(mux)
+	return provider
+}
+
+func (p *mockProvider) idToken(t *testing.T, nonce string) string {
+	now := time.Now()
+	token, err := jwt.Sign(idTokenClaims{
+		Payload: jwt.Payload{
+			Issuer:         p.URL,
+			Subject:        "42",
+			Audience:       jwt.Audience{"client"},
+			ExpirationTime: jwt.NumericDate(now.Add(time.Hour)),
+			IssuedAt:       jwt.NumericDate(now),
+		},
+		Nonce: nonce,
+	}, jwt.NewRS256(jwt.RSAPrivateKey(p.key)), jwt.KeyID("mock"))
+	if err != nil {
+		t.Fatalf("Could not sign id token: %s", err)
+	}
+	return string(token)
+}
+
+func newTestApp(t *testing.T, provider *mockProvider) (*httptest.Server, *Strategy, *testUserService, *http.Client) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	app := feathers.NewApp()
+	app.SetConfig("authentication", map[string]interface{}{
+		"secret":     "supersecret",
+		"entity":     "user",
+		"service":    "users",
+		"jwtOptions": map[string]interface{}{},
+		"oauth": map[string]interface{}{
+			"redirect": "http://frontend/",
+			"mock": map[string]interface{}{
+				"key":         "client",
+				"secret":      "clientsecret",
+				"issuer":      provider.URL,
+				"redirectUri": server.URL + "/oauth/mock/callback",
+			},
+		},
+	})
+	users := &testUserService{BaseService: &feathers.BaseService{}}
+	app.AddService("users", users)
+	strategy := New()
+	if err := auth.Configure(app, map[string]interface{}{
+		"strategies": map[string]auth.AuthStrategy{"mock": strategy},
+	}); err != nil {
+		t.Fatalf("Could not configure authentication: %s", err)
+	}
+	strategy.Listen(0, mux)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("Could not create cookie jar: %s", err)
+	}
+	client := &http.Client{Jar: jar, CheckRedirect: func(request *http.Request, via []*http.Request) error {
+		if request.URL.Host == "frontend" {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}}
+	return server, strategy, users, client
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	provider := newMockProvider(t)
+	defer provider.Close()
+	server, strategy, users, client := newTestApp(t, provider)
+	defer server.Close()
+
+	response, err := client.Get(server.URL + "/oauth/mock")
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	response.Body.Close()
+	location := response.Header.Get("Location")
+	if !strings.HasPrefix(location, "http://frontend/#access_token=") {
+		t.Fatalf("Expected redirect with access token, got %d %q", response.StatusCode, location)
+	}
+	if len(users.users) != 1 || users.users[0]["mockId"] != "42" {
+		t.Errorf("Entity was not created: %#v", users.users)
+	}
+
+	_, err = strategy.Authenticate(context.Background(), auth.Model{
+		Strategy: "mock",
+		Params:   map[string]interface{}{"idToken": provider.idToken(t, "")},
+	}, *feathers.NewParams())
+	if err != nil {
+		t.Errorf("Unexpected error for id token: %s", err)
+	}
+	if len(users.users) != 1 {
+		t.Errorf("Existing entity should be used")
+	}
+
+	_, err = strategy.Authenticate(context.Background(), auth.Model{
+		Strategy: "mock",
+		Params:   map[string]interface{}{"idToken": provider.idToken(t, "other"), "nonce": "expected"},
+	}, *feathers.NewParams())
+	if err == nil {
+		t.Errorf("Expected error for invalid nonce")
+	}
+
+	response, err = client.Get(server.URL + "/oauth/mock/callback?code=secretcode&state=unknown")
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	response.Body.Close()
+	if location := response.Header.Get("Location"); !strings.HasPrefix(location, "http://frontend/#error=") {
+		t.Errorf("Expected error redirect for unknown state, got %q", location)
+	}
+}
+
+func TestAccessTokenFlow(t *testing.T) {
+	provider := newMockProvider(t)
+	provider.withoutIDToken = true
+	defer provider.Close()
+	server, strategy, users, client := newTestApp(t, provider)
+	defer server.Close()
+
+	response, err := client.Get(server.URL + "/oauth/mock")
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	response.Body.Close()
+	if location := response.Header.Get("Location"); !strings.HasPrefix(location, "http://frontend/#access_token=") {
+		t.Fatalf("Expected redirect with access token, got %d %q", response.StatusCode, location)
+	}
+	if len(users.users) != 1 || users.users[0]["mockId"] != "43" {
+		t.Errorf("Entity was not created from the userinfo: %#v", users.users)
+	} else if _, ok := users.users[0]["email"]; ok {
+		t.Errorf("Unverified email was copied: %#v", users.users[0])
+	}
+
+	// access tokens of the provider may be issued to other clients
+	_, err = strategy.Authenticate(context.Background(), auth.Model{
+		Strategy: "mock",
+		Params:   map[string]interface{}{"access_token": "token-of-other-client"},
+	}, *feathers.NewParams())
+	if err == nil {
+		t.Errorf("Expected error for access token")
+	}
+}
+
+func TestStateCookie(t *testing.T) {
+	provider := newMockProvider(t)
+	defer provider.Close()
+	server, _, users, client := newTestApp(t, provider)
+	defer server.Close()
+
+	// the state of another browser is not accepted
+	other := &http.Client{CheckRedirect: func(request *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := other.Get(server.URL + "/oauth/mock")
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	response.Body.Close()
+	redirect, err := url.Parse(response.Header.Get("Location"))
+	if err != nil || redirect.Query().Get("state") == "" {
+		t.Fatalf("Invalid redirect: %s", err)
+	}
+	response, err = client.Get(server.URL + "/oauth/mock/callback?code=secretcode&state=" + url.QueryEscape(redirect.Query().Get("state")))
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	response.Body.Close()
+	if location := response.Header.Get("Location"); !strings.HasPrefix(location, "http://frontend/#error=") || len(users.users) != 0 {
+		t.Errorf("Expected error redirect for state of another browser, got %q", location)
+	}
+}
+
+func TestEmailVerified(t *testing.T) {
+	provider := newMockProvider(t)
+	defer provider.Close()
+	server, strategy, users, _ := newTestApp(t, provider)
+	defer server.Close()
+
+	for key, test := range []struct {
+		profile map[string]interface{}
+		email   interface{}
+	}{
+		/* #1 */ {map[string]interface{}{"sub": "1", "email": "a@example.com"}, nil},
+		/* #2 */ {map[string]interface{}{"sub": "2", "email": "a@example.com", "email_verified": false}, nil},
+		/* #3 */ {map[string]interface{}{"sub": "3", "email": "a@example.com", "email_verified": true}, "a@example.com"},
+		/* #4 */ {map[string]interface{}{"sub": "4", "email": "a@example.com", "email_verified": "true"}, "a@example.com"},
+	} {
+		if _, err := strategy.findOrCreateEntity(context.Background(), test.profile); err != nil {
+			t.Fatalf("Failed #%d: unexpected error: %s", key+1, err)
+		}
+		if email := users.users[len(users.users)-1]["email"]; email != test.email {
+			t.Errorf("Failed #%d: wanted email %v, got: %v", key+1, test.email, email)
+		}
+	}
+}
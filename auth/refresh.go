@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// RefreshTokenStore stores the current refresh token of each token family (all tokens rotated from one login)
+type RefreshTokenStore interface {
+	// Save stores jti as the current token of a new family
+	Save(ctx context.Context, family string, jti string, expiresAt time.Time) error
+	// Rotate replaces the current token of family with next. It returns false if current is not the current token of family (reuse)
+	Rotate(ctx context.Context, family string, current string, next string, expiresAt time.Time) (bool, error)
+	// RevokeFamily removes family so none of its tokens can be used anymore
+	RevokeFamily(ctx context.Context, family string) error
+}
+
+type refreshTokenEntry struct {
+	jti       string
+	expiresAt time.Time
+}
+
+// MemoryRefreshTokenStore is a RefreshTokenStore which keeps token families in memory (use NewMemoryRefreshTokenStore)
+type MemoryRefreshTokenStore struct {
+	families map[string]refreshTokenEntry
+	lock     sync.Mutex
+}
+
+// Save stores jti as the current token of a new family
+func (s *MemoryRefreshTokenStore) Save(ctx context.Context, family string, jti string, expiresAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for key, entry := range s.families {
+		if entry.expiresAt.Before(now) {
+			delete(s.families, key)
+		}
+	}
+	s.families[family] = refreshTokenEntry{jti: jti, expiresAt: expiresAt}
+	return nil
+}
+
+// Rotate replaces the current token of family with next if current is the current token
+func (s *MemoryRefreshTokenStore) Rotate(ctx context.Context, family string, current string, next string, expiresAt time.Time) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.families[family]
+	if !ok || entry.jti != current || entry.expiresAt.Before(time.Now()) {
+		return false, nil
+	}
+	s.families[family] = refreshTokenEntry{jti: next, expiresAt: expiresAt}
+	return true, nil
+}
+
+// RevokeFamily removes family so none of its tokens can be used anymore
+func (s *MemoryRefreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.families, family)
+	return nil
+}
+
+// NewMemoryRefreshTokenStore creates a new in memory refresh token store
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		families: map[string]refreshTokenEntry{},
+	}
+}
+
+// DefaultRefreshExpiresIn is the lifetime of refresh tokens if `expiresIn` of the refresh strategy is not configured
+const DefaultRefreshExpiresIn = "30d"
+
+type refreshStrategyConfig struct {
+	ExpiresIn interface{} `mapstructure:"expiresIn"`
+}
+
+// RefreshStrategy issues new access tokens for a refresh token (`{"strategy": "refresh", "refreshToken": "..."}`).
+/*
+If the strategy is registered every successful authentication returns a long lived `refreshToken` (lifetime `expiresIn`
+of the strategy configuration, default 30d). Each refresh token can only be used once and is replaced by a new one.
+If a used refresh token is sent again all tokens of its login (also the access tokens issued for it, if the authentication
+service has a RevocationStore) are revoked and a `refresh-reuse` event is emitted
+*/
+type RefreshStrategy struct {
+	*BaseAuthStrategy
+	// Store keeps track of the current refresh token of each login
+	Store RefreshTokenStore
+}
+
+func (s *RefreshStrategy) expiresIn() (time.Duration, error) {
+	config := refreshStrategyConfig{}
+	s.StrategyConfig(&config)
+	if config.ExpiresIn == nil {
+		return ParseDuration(DefaultRefreshExpiresIn)
+	}
+	return ParseDuration(config.ExpiresIn)
+}
+
+// issue creates a new refresh token for the authentication result. Without current token a new family is started
+// (family is generated if it is empty)
+func (s *RefreshStrategy) issue(ctx context.Context, authService *AuthService, result map[string]interface{}, family string, current string) (string, error) {
+	expiresIn, err := s.expiresIn()
+	if err != nil {
+		return "", err
+	}
+	newFamily := current == ""
+	if family == "" {
+		family = Uuid4()
+	}
+	token, payload, err := authService.createToken(result, tokenClaims{
+		expiresIn: expiresIn,
+		tokenType: "refresh",
+		family:    family,
+	})
+	if err != nil {
+		return "", err
+	}
+	if newFamily {
+		return token, s.Store.Save(ctx, family, payload.JWTID, payload.ExpirationTime.Time)
+	}
+	rotated, err := s.Store.Rotate(ctx, family, current, payload.JWTID, payload.ExpirationTime.Time)
+	if err != nil {
+		return "", err
+	}
+	if !rotated {
+		return "", s.reused(ctx, authService, family)
+	}
+	return token, nil
+}
+
+func (s *RefreshStrategy) reused(ctx context.Context, authService *AuthService, family string) error {
+	if err := s.Store.RevokeFamily(ctx, family); err != nil {
+		return httperrors.Convert(err)
+	}
+	if err := authService.revokeFamily(ctx, family); err != nil {
+		return httperrors.Convert(err)
+	}
+	s.app.Emit("refresh-reuse", family)
+	return httperrors.NewNotAuthenticated("Refresh token has already been used", nil)
+}
+
+func (s *RefreshStrategy) Authenticate(ctx context.Context, data Model, params feathers.Params) (map[string]interface{}, error) {
+	defaultConfig := s.DefaultConfig()
+	authService, ok := s.AuthService()
+	if !ok {
+		return nil, errors.New("Authentication service is not registered")
+	}
+	token, ok := data.Params["refreshToken"].(string)
+	if !ok || token == "" {
+		return nil, httperrors.NewNotAuthenticated("No refreshToken sent", nil)
+	}
+	payload, err := authService.verifyToken(ctx, token, "refresh")
+	if err != nil {
+		return nil, err
+	}
+
+	entityService, ok := s.EntityService()
+	if !ok {
+		return nil, errors.New("Entity service is not registered")
+	}
+	entity, err := entityService.Get(ctx, payload.Subject, *feathers.NewParams())
+	if err != nil {
+		return nil, httperrors.NewNotAuthenticated(err.Error(), nil)
+	}
+	result := map[string]interface{}{
+		"authentication": map[string]interface{}{
+			"strategy": s.name,
+			// the new access token belongs to the same login
+			"family": payload.Family,
+		},
+	}
+	result[defaultConfig.Entity] = entity
+	refreshToken, err := s.issue(ctx, authService, result, payload.Family, payload.JWTID)
+	if err != nil {
+		return nil, err
+	}
+	result["refreshToken"] = refreshToken
+	return result, nil
+}
+
+// NewRefreshStrategy creates a refresh strategy with an in memory store
+func NewRefreshStrategy() *RefreshStrategy {
+	return &RefreshStrategy{
+		BaseAuthStrategy: &BaseAuthStrategy{},
+		Store:            NewMemoryRefreshTokenStore(),
+	}
+}
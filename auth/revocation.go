@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore stores revoked tokens by their JWT ID (`jti`)
+type RevocationStore interface {
+	// Revoke marks the token with jti as revoked until it expires
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsRevoked returns true if the token with jti was revoked
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// MemoryRevocationStore is a RevocationStore which keeps revoked tokens in memory (use NewMemoryRevocationStore).
+/*
+Revocations are not shared between multiple server instances. Expired entries are removed automatically
+*/
+type MemoryRevocationStore struct {
+	revoked map[string]time.Time
+	lock    sync.RWMutex
+}
+
+// Revoke marks the token with jti as revoked until it expires
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for key, expiry := range s.revoked {
+		if expiry.Before(now) {
+			delete(s.revoked, key)
+		}
+	}
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked returns true if the token with jti was revoked and is not expired yet
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	expiresAt, ok := s.revoked[jti]
+	if !ok {
+		return false, nil
+	}
+	return expiresAt.After(time.Now()), nil
+}
+
+// NewMemoryRevocationStore creates a new in memory revocation store
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked: map[string]time.Time{},
+	}
+}
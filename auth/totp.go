@@ -0,0 +1,476 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+const (
+	totpDigits = 6
+	totpPeriod = 30
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret creates a random base32 encoded TOTP secret (160 bit like recommended by RFC 4226)
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI returns the otpauth URI of secret which can be shown as QR code to authenticator apps
+func TOTPURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPCode returns the code of secret at t (RFC 6238 with SHA1, 6 digits and 30 second steps)
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, uint64(t.Unix()/totpPeriod)), nil
+}
+
+func totpCode(key []byte, counter uint64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// ValidateTOTP checks code against secret at t. Codes of skew steps before and after t are accepted to allow clock drift
+func ValidateTOTP(secret string, code string, t time.Time, skew int) bool {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(code) != totpDigits {
+		return false
+	}
+	counter := t.Unix() / totpPeriod
+	for step := -skew; step <= skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, uint64(counter+int64(step)))), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// hashRecoveryCode returns the sha256 hash of a recovery code (recovery codes are stored hashed)
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(hash[:])
+}
+
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b)
+	return code[:5] + "-" + code[5:], nil
+}
+
+type totpStrategyConfig struct {
+	// Issuer is shown in authenticator apps
+	Issuer string `mapstructure:"issuer" default:"feathers"`
+	// AccountField is the entity field shown as account in authenticator apps
+	AccountField string `mapstructure:"accountField" default:"email"`
+	// EnabledField marks entities with activated two-factor authentication
+	EnabledField string `mapstructure:"enabledField" default:"totpEnabled"`
+	// SecretField stores the TOTP secret
+	SecretField string `mapstructure:"secretField" default:"totpSecret"`
+	// RecoveryCodesField stores the hashes of the unused recovery codes
+	RecoveryCodesField string `mapstructure:"recoveryCodesField" default:"totpRecoveryCodes"`
+	// RecoveryCodes is the number of recovery codes created on enrolment
+	RecoveryCodes int `mapstructure:"recoveryCodes" default:"10"`
+	// Skew is the number of 30 second steps codes are accepted before and after the current time
+	Skew int `mapstructure:"skew" default:"1"`
+	// ExpiresIn is the lifetime of partial tokens
+	ExpiresIn interface{} `mapstructure:"expiresIn"`
+	// MaxAttempts is the number of wrong codes after which the entity is locked for LockoutDuration
+	MaxAttempts int64 `mapstructure:"maxAttempts" default:"5"`
+	// LockoutDuration is the time an entity is locked after MaxAttempts wrong codes (default 15m)
+	LockoutDuration interface{} `mapstructure:"lockoutDuration"`
+}
+
+// DefaultTOTPLockoutDuration is the lockout of entities if `lockoutDuration` of the totp strategy is not configured
+const DefaultTOTPLockoutDuration = "15m"
+
+// DefaultPartialExpiresIn is the lifetime of partial tokens if `expiresIn` of the totp strategy is not configured
+const DefaultPartialExpiresIn = "5m"
+
+// TOTPEnrolment is the result of TOTPStrategy.Enroll
+type TOTPEnrolment struct {
+	Secret        string   `json:"secret" mapstructure:"secret"`
+	URI           string   `json:"uri" mapstructure:"uri"`
+	RecoveryCodes []string `json:"recoveryCodes" mapstructure:"recoveryCodes"`
+}
+
+// TOTPStrategy is the second factor of strategies which call AuthService.SecondFactor (e.g. local).
+/*
+If the entity has two-factor authentication enabled the first factor returns a short lived `partialToken`
+(lifetime `expiresIn` of the strategy configuration, default 5m) instead of an access token.
+`{"strategy": "totp", "partialToken": "...", "totp": "123456"}` upgrades it to an access token. Instead of the code
+an unused recovery code is accepted. Partial tokens can only be used once, also if the code is wrong.
+After `maxAttempts` (default 5) wrong codes the entity is locked for `lockoutDuration` (default 15m), also for
+new partial tokens. Entities are enrolled with Enroll and Activate (see TOTPService)
+*/
+type TOTPStrategy struct {
+	*BaseAuthStrategy
+	// Attempts tracks wrong codes and used partial tokens (defaults to a MemoryAttemptStore)
+	Attempts AttemptStore
+}
+
+func (s *TOTPStrategy) config() totpStrategyConfig {
+	config := totpStrategyConfig{}
+	s.StrategyConfig(&config)
+	return config
+}
+
+// Enabled returns if entity has activated two-factor authentication
+func (s *TOTPStrategy) Enabled(entity map[string]interface{}) bool {
+	enabled, _ := entity[s.config().EnabledField].(bool)
+	return enabled
+}
+
+func (s *TOTPStrategy) lockoutDuration() (time.Duration, error) {
+	config := s.config()
+	if config.LockoutDuration == nil {
+		return ParseDuration(DefaultTOTPLockoutDuration)
+	}
+	return ParseDuration(config.LockoutDuration)
+}
+
+// Verify checks code against the TOTP secret of entity. Recovery codes are accepted and removed from entity.
+/*
+Every code is counted as attempt of the entity before it is checked, after MaxAttempts wrong codes Verify fails with
+TooManyRequests until the lockout is over. A valid code resets the attempts
+*/
+func (s *TOTPStrategy) Verify(ctx context.Context, entity map[string]interface{}, code string) error {
+	config := s.config()
+	id, err := entityID(entity["_id"])
+	if err != nil {
+		return httperrors.NewGeneralError(err.Error(), nil)
+	}
+	key := "totp:" + id
+	if s.Attempts != nil {
+		lockout, err := s.lockoutDuration()
+		if err != nil {
+			return httperrors.Convert(err)
+		}
+		_, recorded, err := s.Attempts.Attempt(ctx, key, lockout, func(attempts Attempts) bool {
+			return attempts.Count < config.MaxAttempts
+		})
+		if err != nil {
+			return httperrors.Convert(err)
+		}
+		if !recorded {
+			return httperrors.NewTooManyRequests("Too many invalid two-factor codes", nil)
+		}
+	}
+	if err := s.verify(ctx, entity, code); err != nil {
+		return err
+	}
+	if s.Attempts != nil {
+		if err := s.Attempts.Reset(ctx, key); err != nil {
+			return httperrors.Convert(err)
+		}
+	}
+	return nil
+}
+
+func (s *TOTPStrategy) verify(ctx context.Context, entity map[string]interface{}, code string) error {
+	config := s.config()
+	secret, _ := entity[config.SecretField].(string)
+	if secret != "" && ValidateTOTP(secret, strings.TrimSpace(code), time.Now(), config.Skew) {
+		return nil
+	}
+
+	hash := hashRecoveryCode(code)
+	remaining := []string{}
+	found := false
+	for _, recoveryCode := range stringSlice(entity[config.RecoveryCodesField]) {
+		if !found && subtle.ConstantTimeCompare([]byte(recoveryCode), []byte(hash)) == 1 {
+			found = true
+			continue
+		}
+		remaining = append(remaining, recoveryCode)
+	}
+	if !found {
+		return httperrors.NewNotAuthenticated("Invalid two-factor code", nil)
+	}
+	_, err := s.patch(ctx, entity, map[string]interface{}{
+		config.RecoveryCodesField: remaining,
+	})
+	return err
+}
+
+// Enroll creates a new secret and recovery codes for entity. Two-factor authentication is enabled after Activate
+func (s *TOTPStrategy) Enroll(ctx context.Context, entity map[string]interface{}) (*TOTPEnrolment, error) {
+	config := s.config()
+	if s.Enabled(entity) {
+		return nil, httperrors.NewBadRequest("Two-factor authentication is already enabled", nil)
+	}
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, httperrors.Convert(err)
+	}
+	enrolment := &TOTPEnrolment{Secret: secret, RecoveryCodes: make([]string, config.RecoveryCodes)}
+	hashes := make([]string, config.RecoveryCodes)
+	for i := range enrolment.RecoveryCodes {
+		if enrolment.RecoveryCodes[i], err = newRecoveryCode(); err != nil {
+			return nil, httperrors.Convert(err)
+		}
+		hashes[i] = hashRecoveryCode(enrolment.RecoveryCodes[i])
+	}
+	account, ok := entity[config.AccountField].(string)
+	if !ok {
+		account, _ = entityID(entity["_id"])
+	}
+	enrolment.URI = TOTPURI(config.Issuer, account, secret)
+
+	_, err = s.patch(ctx, entity, map[string]interface{}{
+		config.EnabledField:       false,
+		config.SecretField:        secret,
+		config.RecoveryCodesField: hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return enrolment, nil
+}
+
+// Activate enables two-factor authentication of an enrolled entity if code is valid for its secret
+func (s *TOTPStrategy) Activate(ctx context.Context, entity map[string]interface{}, code string) error {
+	config := s.config()
+	secret, _ := entity[config.SecretField].(string)
+	if secret == "" {
+		return httperrors.NewBadRequest("Two-factor authentication is not enrolled", nil)
+	}
+	if !ValidateTOTP(secret, strings.TrimSpace(code), time.Now(), config.Skew) {
+		return httperrors.NewBadRequest("Invalid two-factor code", nil)
+	}
+	_, err := s.patch(ctx, entity, map[string]interface{}{
+		config.EnabledField: true,
+	})
+	return err
+}
+
+// Disable disables two-factor authentication of entity if code (or a recovery code) is valid
+func (s *TOTPStrategy) Disable(ctx context.Context, entity map[string]interface{}, code string) error {
+	config := s.config()
+	if !s.Enabled(entity) {
+		return httperrors.NewBadRequest("Two-factor authentication is not enabled", nil)
+	}
+	if err := s.Verify(ctx, entity, code); err != nil {
+		return err
+	}
+	_, err := s.patch(ctx, entity, map[string]interface{}{
+		config.EnabledField:       false,
+		config.SecretField:        nil,
+		config.RecoveryCodesField: nil,
+	})
+	return err
+}
+
+func (s *TOTPStrategy) patch(ctx context.Context, entity map[string]interface{}, data map[string]interface{}) (map[string]interface{}, error) {
+	service, ok := s.EntityService()
+	if !ok {
+		return nil, httperrors.NewGeneralError("Entity service is not registered", nil)
+	}
+	id, err := entityID(entity["_id"])
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err.Error(), nil)
+	}
+	result, err := feathers.ToMap(service.Patch(ctx, id, data, *feathers.NewParams()))
+	if err != nil {
+		return nil, httperrors.Convert(err)
+	}
+	return result, nil
+}
+
+func (s *TOTPStrategy) expiresIn() (time.Duration, error) {
+	config := s.config()
+	if config.ExpiresIn == nil {
+		return ParseDuration(DefaultPartialExpiresIn)
+	}
+	return ParseDuration(config.ExpiresIn)
+}
+
+// partial creates the partial result of a first factor. It contains only the partial token
+func (s *TOTPStrategy) partial(authService *AuthService, result map[string]interface{}) (map[string]interface{}, error) {
+	expiresIn, err := s.expiresIn()
+	if err != nil {
+		return nil, httperrors.Convert(err)
+	}
+	token, _, err := authService.createToken(result, tokenClaims{
+		expiresIn: expiresIn,
+		tokenType: "partial",
+	})
+	if err != nil {
+		return nil, httperrors.Convert(err)
+	}
+	return map[string]interface{}{
+		"partialToken": token,
+		"twoFactor":    s.name,
+	}, nil
+}
+
+func (s *TOTPStrategy) Authenticate(ctx context.Context, data Model, params feathers.Params) (map[string]interface{}, error) {
+	defaultConfig := s.DefaultConfig()
+	authService, ok := s.AuthService()
+	if !ok {
+		return nil, errors.New("Authentication service is not registered")
+	}
+	token, _ := data.Params["partialToken"].(string)
+	code, _ := data.Params["totp"].(string)
+	if token == "" || code == "" {
+		return nil, httperrors.NewNotAuthenticated("No partialToken or totp sent", nil)
+	}
+	payload, err := authService.verifyToken(ctx, token, "partial")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.claim(ctx, payload); err != nil {
+		return nil, err
+	}
+	if authService.RevocationStore != nil {
+		if err := authService.RevocationStore.Revoke(ctx, payload.JWTID, payload.ExpirationTime.Time); err != nil {
+			return nil, httperrors.Convert(err)
+		}
+	}
+
+	entityService, ok := s.EntityService()
+	if !ok {
+		return nil, errors.New("Entity service is not registered")
+	}
+	entity, err := feathers.ToMap(entityService.Get(ctx, payload.Subject, *feathers.NewParams()))
+	if err != nil {
+		return nil, httperrors.NewNotAuthenticated(err.Error(), nil)
+	}
+	if !s.Enabled(entity) {
+		return nil, httperrors.NewNotAuthenticated("Two-factor authentication is not enabled", nil)
+	}
+	if err := s.Verify(ctx, entity, code); err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{
+		"authentication": map[string]interface{}{
+			"strategy": s.name,
+		},
+	}
+	result[defaultConfig.Entity] = entity
+	return result, nil
+}
+
+// claim marks a partial token as used. It fails if the token was used before (also without RevocationStore)
+func (s *TOTPStrategy) claim(ctx context.Context, payload *jwtToken) error {
+	if s.Attempts == nil {
+		return httperrors.NewGeneralError("The totp strategy requires an attempt store", nil)
+	}
+	if payload.JWTID == "" {
+		return httperrors.NewNotAuthenticated("Invalid partial token", nil)
+	}
+	window := time.Minute
+	if payload.ExpirationTime != nil {
+		window = time.Until(payload.ExpirationTime.Time) + time.Minute
+	}
+	_, claimed, err := s.Attempts.Attempt(ctx, "partial:"+payload.JWTID, window, func(attempts Attempts) bool {
+		return attempts.Count == 0
+	})
+	if err != nil {
+		return httperrors.Convert(err)
+	}
+	if !claimed {
+		return httperrors.NewNotAuthenticated("Partial token has already been used", nil)
+	}
+	return nil
+}
+
+// NewTOTPStrategy creates a totp strategy with an in memory attempt store
+func NewTOTPStrategy() *TOTPStrategy {
+	return &TOTPStrategy{
+		BaseAuthStrategy: &BaseAuthStrategy{},
+		Attempts:         NewMemoryAttemptStore(),
+	}
+}
+
+// totpStrategy returns the registered totp strategy
+func (as *AuthService) totpStrategy() (*TOTPStrategy, bool) {
+	for _, strategy := range as.authStrategies {
+		if totp, ok := strategy.(*TOTPStrategy); ok {
+			return totp, true
+		}
+	}
+	return nil, false
+}
+
+// SecondFactor completes the result of a first factor strategy (e.g. local).
+/*
+If a TOTPStrategy is registered and the entity of result has two-factor authentication enabled, code is verified.
+Without code a partial result containing only a `partialToken` is returned which the totp strategy upgrades to an access token
+*/
+func (as *AuthService) SecondFactor(ctx context.Context, result map[string]interface{}, code string) (map[string]interface{}, error) {
+	strategy, ok := as.totpStrategy()
+	if !ok {
+		return result, nil
+	}
+	entity, err := feathers.ToMap(result[as.DefaultConfig().Entity])
+	if err != nil || !strategy.Enabled(entity) {
+		return result, nil
+	}
+	if code == "" {
+		return strategy.partial(as, result)
+	}
+	if err := strategy.Verify(ctx, entity, code); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// stringSlice converts a slice of strings (e.g. []interface{} or primitive.A read from a database) to []string
+func stringSlice(value interface{}) []string {
+	if values, ok := value.([]string); ok {
+		return values
+	}
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice {
+		return nil
+	}
+	result := make([]string, 0, reflected.Len())
+	for i := 0; i < reflected.Len(); i++ {
+		if str, ok := reflected.Index(i).Interface().(string); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
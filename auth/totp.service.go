@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// TOTPModel is the data of TOTPService.Create
+type TOTPModel struct {
+	Action string `mapstructure:"action" validate:"required,oneof=enroll activate disable"`
+	// Totp is the code of the authenticator app (or a recovery code for disable)
+	Totp string `mapstructure:"totp"`
+}
+
+func NewTOTPModel() interface{} {
+	return &TOTPModel{}
+}
+
+// TOTPService enrolls the authenticated user (`Params.User`) for two-factor authentication through create.
+/*
+Actions:
+`enroll` creates a new secret and returns it with its otpauth URI and the recovery codes (they are only returned once),
+`activate` (`totp`) enables two-factor authentication after the user has added the secret to an authenticator app,
+`disable` (`totp`) disables it again.
+The service has to be secured with AuthenticationHook
+*/
+type TOTPService struct {
+	*feathers.BaseService
+	*feathers.ModelService
+	app      *feathers.App
+	strategy string
+}
+
+func (s *TOTPService) totpStrategy() (*TOTPStrategy, error) {
+	authService, ok := s.app.ServiceClass("authentication").(*AuthService)
+	if !ok {
+		return nil, httperrors.NewGeneralError("Authentication service is not registered", nil)
+	}
+	strategy, ok := authService.authStrategies[s.strategy].(*TOTPStrategy)
+	if !ok {
+		return nil, httperrors.NewGeneralError("Strategy "+s.strategy+" is not a totp strategy", nil)
+	}
+	return strategy, nil
+}
+
+func (s *TOTPService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	model := TOTPModel{}
+	if err := s.MapAndValidateStruct(data, &model); err != nil {
+		return nil, httperrors.NewBadRequest(err.Error(), nil)
+	}
+	if params.User == nil {
+		return nil, httperrors.NewNotAuthenticated("Not authenticated", nil)
+	}
+	strategy, err := s.totpStrategy()
+	if err != nil {
+		return nil, err
+	}
+	switch model.Action {
+	case "enroll":
+		return strategy.Enroll(ctx, params.User)
+	case "activate":
+		if err := strategy.Activate(ctx, params.User, model.Totp); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"enabled": true}, nil
+	case "disable":
+		if err := strategy.Disable(ctx, params.User, model.Totp); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"enabled": false}, nil
+	}
+	return nil, httperrors.NewBadRequest("Unknown action "+model.Action, nil)
+}
+
+func (s *TOTPService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *TOTPService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *TOTPService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *TOTPService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+func (s *TOTPService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Not supported", nil)
+}
+
+// NewTOTPService creates an enrolment service for the totp strategy registered as strategy
+func NewTOTPService(app *feathers.App, strategy string) *TOTPService {
+	return &TOTPService{
+		BaseService:  &feathers.BaseService{},
+		ModelService: feathers.NewModelService(NewTOTPModel),
+		app:          app,
+		strategy:     strategy,
+	}
+}
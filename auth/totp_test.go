@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vectors (secret "12345678901234567890", last 6 digits)
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for key, test := range []struct {
+		time     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		code, err := TOTPCode(secret, time.Unix(test.time, 0))
+		if err != nil || code != test.expected {
+			t.Errorf("Failed #%d: expected %s, got %s (%v)", key+1, test.expected, code, err)
+		}
+	}
+	if !ValidateTOTP(secret, "287082", time.Unix(59+totpPeriod, 0), 1) {
+		t.Errorf("Code of previous step should be accepted")
+	}
+	if ValidateTOTP(secret, "287082", time.Unix(59+2*totpPeriod, 0), 1) {
+		t.Errorf("Code outside of skew should be rejected")
+	}
+}
+
+func TestTOTPEnrolmentAndLogin(t *testing.T) {
+	app, authService := newTestAppStrategies(t, map[string]AuthStrategy{
+		"jwt":  NewJwtStrategy(),
+		"totp": NewTOTPStrategy(),
+	})
+	app.AddService("2fa", NewTOTPService(app, "totp"))
+	users := app.ServiceClass("users").(*testUserService)
+	user := users.users["1"]
+
+	call := func(data map[string]interface{}) (interface{}, error) {
+		params := feathers.NewParams()
+		params.User = user
+		return app.Service("2fa").Create(context.Background(), data, *params)
+	}
+	result, err := call(map[string]interface{}{"action": "enroll"})
+	if err != nil {
+		t.Fatalf("Unexpected error on enroll: %s", err)
+	}
+	enrolment := result.(*TOTPEnrolment)
+	if !strings.HasPrefix(enrolment.URI, "otpauth://totp/feathers:test@example.com?") || len(enrolment.RecoveryCodes) != 10 {
+		t.Fatalf("Invalid enrolment: %#v", enrolment)
+	}
+	if user["totpRecoveryCodes"].([]string)[0] == enrolment.RecoveryCodes[0] {
+		t.Errorf("Recovery codes are not stored hashed")
+	}
+
+	if _, err := call(map[string]interface{}{"action": "activate", "totp": "000000"}); err == nil {
+		t.Errorf("Activation with invalid code should fail")
+	}
+	code, _ := TOTPCode(enrolment.Secret, time.Now())
+	if _, err := call(map[string]interface{}{"action": "activate", "totp": code}); err != nil {
+		t.Fatalf("Unexpected error on activate: %s", err)
+	}
+	if user["totpEnabled"] != true {
+		t.Fatalf("Two-factor authentication was not enabled")
+	}
+
+	login := func(secondFactor string) (map[string]interface{}, error) {
+		partial, err := authService.SecondFactor(context.Background(), map[string]interface{}{"user": user}, "")
+		if err != nil {
+			return nil, err
+		}
+		token, _ := partial["partialToken"].(string)
+		if token == "" || partial["user"] != nil {
+			t.Fatalf("Expected partial result, got: %#v", partial)
+		}
+		if _, err := authService.verifyAccessToken(context.Background(), token); err == nil {
+			t.Errorf("Partial token should not be accepted as access token")
+		}
+		result, err := authService.Create(context.Background(), map[string]interface{}{
+			"strategy":     "totp",
+			"partialToken": token,
+			"totp":         secondFactor,
+		}, *feathers.NewParams())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := authService.Create(context.Background(), map[string]interface{}{
+			"strategy":     "totp",
+			"partialToken": token,
+			"totp":         secondFactor,
+		}, *feathers.NewParams()); err == nil {
+			t.Errorf("Partial token should only be usable once")
+		}
+		return result.(map[string]interface{}), nil
+	}
+
+	auth, err := login(code)
+	if err != nil {
+		t.Fatalf("Unexpected error on login: %s", err)
+	}
+	if token, _ := auth["accessToken"].(string); token == "" {
+		t.Errorf("Login did not return an access token: %#v", auth)
+	}
+
+	_, err = login("123456")
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated for invalid code, got: %v", err)
+	}
+
+	if _, err := login(strings.ToUpper(enrolment.RecoveryCodes[3])); err != nil {
+		t.Fatalf("Unexpected error on login with recovery code: %s", err)
+	}
+	if len(user["totpRecoveryCodes"].([]string)) != 9 {
+		t.Errorf("Recovery code was not removed")
+	}
+	if _, err := login(enrolment.RecoveryCodes[3]); err == nil {
+		t.Errorf("Recovery code should only be usable once")
+	}
+
+	if _, err := call(map[string]interface{}{"action": "disable", "totp": enrolment.RecoveryCodes[4]}); err != nil {
+		t.Fatalf("Unexpected error on disable: %s", err)
+	}
+	auth, err = authService.SecondFactor(context.Background(), map[string]interface{}{"user": user}, "")
+	if err != nil || auth["user"] == nil {
+		t.Errorf("Disabled two-factor authentication should not require a second factor: %#v %v", auth, err)
+	}
+}
+
+func TestTOTPLockout(t *testing.T) {
+	app, authService := newTestAppStrategies(t, map[string]AuthStrategy{
+		"jwt":  NewJwtStrategy(),
+		"totp": NewTOTPStrategy(),
+	})
+	// single use of partial tokens does not depend on the revocation store
+	authService.RevocationStore = nil
+	user := app.ServiceClass("users").(*testUserService).users["1"]
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	user["totpSecret"] = secret
+	user["totpEnabled"] = true
+
+	partialToken := func() string {
+		partial, err := authService.SecondFactor(context.Background(), map[string]interface{}{"user": user}, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return partial["partialToken"].(string)
+	}
+	login := func(token string, code string) error {
+		_, err := authService.Create(context.Background(), map[string]interface{}{
+			"strategy":     "totp",
+			"partialToken": token,
+			"totp":         code,
+		}, *feathers.NewParams())
+		return err
+	}
+	code, _ := TOTPCode(secret, time.Now())
+
+	token := partialToken()
+	if err := login(token, code); err != nil {
+		t.Fatalf("Unexpected error on login: %s", err)
+	}
+	if err := login(token, code); err == nil {
+		t.Errorf("Partial token should only be usable once without revocation store")
+	}
+
+	for key, expected := range []int{
+		/* #1 */ 401,
+		/* #2 */ 401,
+		/* #3 */ 401,
+		/* #4 */ 401,
+		/* #5 */ 401,
+		/* #6 */ 429,
+	} {
+		err := login(partialToken(), "000000")
+		if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != expected {
+			t.Errorf("Failed #%d: expected %d, got: %v", key+1, expected, err)
+		}
+	}
+	if err := login(partialToken(), code); err == nil {
+		t.Errorf("Valid code should be rejected during lockout")
+	}
+}
@@ -2,10 +2,32 @@ package auth
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+type hexable interface {
+	Hex() string
+}
+
+// entityID converts the id of an entity (e.g. a string or a mongo ObjectID) to a string
+func entityID(id interface{}) (string, error) {
+	switch key := id.(type) {
+	case string:
+		return key, nil
+	case hexable:
+		return key.Hex(), nil
+	case fmt.Stringer:
+		return key.String(), nil
+	}
+	return "", errors.New("Cannot strinigy entity key")
+}
+
 func Uuid4() string {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
@@ -16,3 +38,72 @@ func Uuid4() string {
 		b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 	return uuid
 }
+
+var durationPattern = regexp.MustCompile(`^(-?(?:\d+)?\.?\d+)\s*([a-z]*)$`)
+
+var durationUnits = map[string]time.Duration{
+	"":             time.Millisecond,
+	"ms":           time.Millisecond,
+	"msec":         time.Millisecond,
+	"msecs":        time.Millisecond,
+	"millisecond":  time.Millisecond,
+	"milliseconds": time.Millisecond,
+	"s":            time.Second,
+	"sec":          time.Second,
+	"secs":         time.Second,
+	"second":       time.Second,
+	"seconds":      time.Second,
+	"m":            time.Minute,
+	"min":          time.Minute,
+	"mins":         time.Minute,
+	"minute":       time.Minute,
+	"minutes":      time.Minute,
+	"h":            time.Hour,
+	"hr":           time.Hour,
+	"hrs":          time.Hour,
+	"hour":         time.Hour,
+	"hours":        time.Hour,
+	"d":            24 * time.Hour,
+	"day":          24 * time.Hour,
+	"days":         24 * time.Hour,
+	"w":            7 * 24 * time.Hour,
+	"week":         7 * 24 * time.Hour,
+	"weeks":        7 * 24 * time.Hour,
+	"y":            time.Duration(365.25 * float64(24*time.Hour)),
+	"yr":           time.Duration(365.25 * float64(24*time.Hour)),
+	"yrs":          time.Duration(365.25 * float64(24*time.Hour)),
+	"year":         time.Duration(365.25 * float64(24*time.Hour)),
+	"years":        time.Duration(365.25 * float64(24*time.Hour)),
+}
+
+// ParseDuration parses a duration like feathers `expiresIn` (e.g. "1d", "15m", "2 hours").
+/*
+Numbers are seconds (like jsonwebtoken), strings without unit are milliseconds (like the ms package)
+*/
+func ParseDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		match := durationPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(v)))
+		if match == nil {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		unit, ok := durationUnits[match[2]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration unit %q", match[2])
+		}
+		amount, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		return time.Duration(amount * float64(unit)), nil
+	}
+	return 0, fmt.Errorf("cannot parse duration of type %T", value)
+}
@@ -0,0 +1,260 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// DefaultAuthenticationPath is the path of the authentication service of the remote app
+const DefaultAuthenticationPath = "authentication"
+
+// Client connects to a remote feathers app over REST (use New)
+type Client struct {
+	baseURL string
+	// HTTPClient sends the requests (http.DefaultClient if nil)
+	HTTPClient *http.Client
+	// Headers are sent with every request
+	Headers map[string]string
+	// ForwardHeaders are headers of the calling request (`Params.Headers`) which are forwarded to the remote app (e.g. `accept-language`)
+	ForwardHeaders []string
+	// AuthenticationPath is the path of the authentication service (DefaultAuthenticationPath if empty)
+	AuthenticationPath string
+
+	tokenLock   sync.RWMutex
+	accessToken string
+}
+
+// New creates a client for the feathers app at baseURL (e.g. `http://localhost:3030`)
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:            strings.TrimRight(baseURL, "/"),
+		Headers:            map[string]string{},
+		AuthenticationPath: DefaultAuthenticationPath,
+	}
+}
+
+// AccessToken returns the access token which is sent with every request
+func (c *Client) AccessToken() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+	return c.accessToken
+}
+
+// SetAccessToken sets the access token which is sent as `Authorization: Bearer` header (an empty token removes it)
+func (c *Client) SetAccessToken(token string) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	c.accessToken = token
+}
+
+func (c *Client) authenticationPath() string {
+	if c.AuthenticationPath == "" {
+		return DefaultAuthenticationPath
+	}
+	return c.AuthenticationPath
+}
+
+// Authenticate creates an authentication on the remote app (e.g. `{"strategy": "local", "email": ..., "password": ...}`) and stores the access token.
+/*
+The result of the authentication service is returned
+*/
+func (c *Client) Authenticate(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	result, err := c.request(ctx, http.MethodPost, c.authenticationPath(), "", nil, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	authResult, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, httperrors.NewGeneralError("Invalid authentication response")
+	}
+	if token, ok := authResult["accessToken"].(string); ok {
+		c.SetAccessToken(token)
+	}
+	return authResult, nil
+}
+
+// Logout removes the authentication on the remote app and clears the access token
+func (c *Client) Logout(ctx context.Context) error {
+	token := c.AccessToken()
+	if token == "" {
+		return nil
+	}
+	// the token is the id so the remove is not a multi remove
+	_, err := c.request(ctx, http.MethodDelete, c.authenticationPath(), token, nil, nil, nil)
+	c.SetAccessToken("")
+	return err
+}
+
+// Service returns the remote service at path. It implements feathers.Service and can be registered with `App.AddService` as proxy.
+/*
+All calls are sent with the access token of the client, so multi calls are only allowed for the methods passed as multi
+(e.g. `client.Service("messages", feathers.Patch)`), callers of a proxy can not affect multiple remote entities otherwise
+*/
+func (c *Client) Service(path string, multi ...feathers.RestMethod) *Service {
+	return &Service{
+		BaseService: &feathers.BaseService{
+			Multi: multi,
+		},
+		client: c,
+		path:   strings.Trim(path, "/"),
+	}
+}
+
+// requestOptions are additional options of a single request
+type requestOptions struct {
+	params *feathers.Params
+	method string
+}
+
+// request sends a request to the remote app and decodes the response (see decodeResult).
+/*
+Error responses are returned as httperrors.FeathersError
+*/
+func (c *Client) request(ctx context.Context, httpMethod string, path string, id string, query map[string]interface{}, data interface{}, opts *requestOptions) (interface{}, error) {
+	target := c.baseURL + "/" + path
+	if id != "" {
+		target += "/" + url.PathEscape(id)
+	}
+	if len(query) > 0 {
+		target += "?" + feathers.StringifyQuery(query)
+	}
+
+	var body io.Reader
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, httperrors.NewBadRequest("Could not encode request data: " + err.Error())
+		}
+		body = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequestWithContext(ctx, httpMethod, target, body)
+	if err != nil {
+		return nil, httperrors.NewGeneralError("Could not create request: " + err.Error())
+	}
+	request.Header.Set("Accept", "application/json")
+	if data != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if opts != nil && opts.params != nil {
+		for _, header := range c.ForwardHeaders {
+			if value, ok := opts.params.Headers[strings.ToLower(header)]; ok {
+				request.Header.Set(header, value)
+			}
+		}
+	}
+	for key, value := range c.Headers {
+		request.Header.Set(key, value)
+	}
+	if token := c.AccessToken(); token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	if opts != nil && opts.method != "" {
+		request.Header.Set(feathers.ServiceMethodHeader, opts.method)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, httperrors.NewTimeout(fmt.Sprintf("Request to %s timed out", target))
+		}
+		return nil, httperrors.NewUnavailable(fmt.Sprintf("Request to %s failed: %s", target, err.Error()))
+	}
+	defer response.Body.Close()
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, httperrors.NewUnavailable(fmt.Sprintf("Could not read response of %s: %s", target, err.Error()))
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, responseError(response.StatusCode, raw)
+	}
+	return decodeResponse(raw)
+}
+
+// responseError converts an error response into a FeathersError
+func responseError(statusCode int, raw []byte) error {
+	var featherErr httperrors.FeathersError
+	if err := json.Unmarshal(raw, &featherErr); err == nil && featherErr.Code != 0 && featherErr.Name != "" {
+		return featherErr
+	}
+	message := strings.TrimSpace(string(raw))
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return httperrors.FromCode(statusCode, message)
+}
+
+func decodeResponse(raw []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, httperrors.NewBadGateway("Invalid JSON response: " + err.Error())
+	}
+	return decodeResult(result), nil
+}
+
+// decodeResult converts a decoded JSON result into the types services return.
+/*
+Objects are returned as `map[string]interface{}`, arrays of objects as `[]map[string]interface{}` and paginated results as feathers.Page
+*/
+func decodeResult(result interface{}) interface{} {
+	switch v := result.(type) {
+	case map[string]interface{}:
+		if page, ok := toPage(v); ok {
+			return page
+		}
+		return v
+	case []interface{}:
+		return toMapSlice(v)
+	}
+	return result
+}
+
+// toPage converts a paginated result into a feathers.Page
+func toPage(result map[string]interface{}) (feathers.Page, bool) {
+	if len(result) != 4 {
+		return feathers.Page{}, false
+	}
+	total, totalOk := result["total"].(float64)
+	limit, limitOk := result["limit"].(float64)
+	skip, skipOk := result["skip"].(float64)
+	data, dataOk := result["data"].([]interface{})
+	if !totalOk || !limitOk || !skipOk || !dataOk {
+		return feathers.Page{}, false
+	}
+	return feathers.Page{
+		Total: int64(total),
+		Limit: int64(limit),
+		Skip:  int64(skip),
+		Data:  toMapSlice(data),
+	}, true
+}
+
+// toMapSlice converts a list of objects into `[]map[string]interface{}` (lists containing other values are returned unchanged)
+func toMapSlice(list []interface{}) interface{} {
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		mapItem, ok := item.(map[string]interface{})
+		if !ok {
+			return list
+		}
+		result = append(result, mapItem)
+	}
+	return result
+}
@@ -0,0 +1,252 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/client"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+type remoteService struct {
+	*feathers.BaseService
+	*feathers.BasePublishableService
+	headers map[string]string
+}
+
+func (s *remoteService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	s.headers = params.Headers
+	if params.Query["paginate"] == "true" {
+		return feathers.Page{Total: 1, Limit: 10, Data: []map[string]interface{}{{"_id": "a"}}}, nil
+	}
+	return []map[string]interface{}{{"_id": "a", "query": params.Query}}, nil
+}
+
+func (s *remoteService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	if id == "missing" {
+		return nil, httperrors.NewNotFound("No record found for id 'missing'")
+	}
+	return map[string]interface{}{"_id": id}, nil
+}
+
+func (s *remoteService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	data["_id"] = "new"
+	return data, nil
+}
+
+func (s *remoteService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	data["_id"] = id
+	return data, nil
+}
+
+func (s *remoteService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	data["_id"] = id
+	return data, nil
+}
+
+func (s *remoteService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return map[string]interface{}{"_id": id, "query": params.Query}, nil
+}
+
+type authenticationService struct {
+	*feathers.BaseService
+	lock       sync.Mutex
+	strategies []string
+}
+
+// authentications returns the strategies of all authentications
+func (s *authenticationService) authentications() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.strategies...)
+}
+
+func (s *authenticationService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Method find is not allowed")
+}
+
+func (s *authenticationService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Method get is not allowed")
+}
+
+func (s *authenticationService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.strategies = append(s.strategies, fmt.Sprint(data["strategy"]))
+	if data["password"] != "secret" && data["accessToken"] != "token" {
+		return nil, httperrors.NewNotAuthenticated("Invalid login")
+	}
+	return map[string]interface{}{"accessToken": "token"}, nil
+}
+
+func (s *authenticationService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Method update is not allowed")
+}
+
+func (s *authenticationService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, httperrors.NewMethodNotAllowed("Method patch is not allowed")
+}
+
+func (s *authenticationService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	if id != "token" {
+		return nil, httperrors.NewNotAuthenticated("Invalid token")
+	}
+	return map[string]interface{}{"accessToken": id}, nil
+}
+
+func newRemoteApp() (*feathers.App, *remoteService, *authenticationService) {
+	app := feathers.NewApp()
+	remote := &remoteService{
+		BaseService:            &feathers.BaseService{Multi: []feathers.RestMethod{feathers.All}},
+		BasePublishableService: feathers.NewBasePublishableService(),
+	}
+	remote.RegisterMethod("approve", func(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+		data["approved"] = true
+		return data, nil
+	})
+	authentication := &authenticationService{BaseService: &feathers.BaseService{}}
+	app.AddService("messages", remote)
+	app.AddService("authentication", authentication)
+	return app, remote, authentication
+}
+
+func newRemote(t *testing.T) (*remoteService, *client.Client) {
+	app, remote, _ := newRemoteApp()
+	server := httptest.NewServer(feathers.NewHttpProvider(app))
+	t.Cleanup(server.Close)
+	return remote, client.New(server.URL)
+}
+
+func TestServiceMethods(t *testing.T) {
+	_, remoteClient := newRemote(t)
+	service := remoteClient.Service("messages")
+	ctx := context.Background()
+	params := *feathers.NewParams()
+
+	result, err := service.Get(ctx, "a b", params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a b"}) {
+		t.Errorf("Get failed: %#v %v", result, err)
+	}
+	result, err = service.Create(ctx, map[string]interface{}{"text": "hello"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "new", "text": "hello"}) {
+		t.Errorf("Create failed: %#v %v", result, err)
+	}
+	result, err = service.Update(ctx, "a", map[string]interface{}{"text": "hello"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a", "text": "hello"}) {
+		t.Errorf("Update failed: %#v %v", result, err)
+	}
+	result, err = service.Patch(ctx, "a", map[string]interface{}{"text": "hello"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a", "text": "hello"}) {
+		t.Errorf("Patch failed: %#v %v", result, err)
+	}
+	result, err = service.Call(ctx, "approve", map[string]interface{}{"_id": "a"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a", "approved": true}) {
+		t.Errorf("Custom method failed: %#v %v", result, err)
+	}
+
+	params.Query = map[string]interface{}{
+		"id":     map[string]interface{}{"$in": []interface{}{"a", "b"}},
+		"$limit": 5,
+	}
+	result, err = service.Find(ctx, params)
+	expected := []map[string]interface{}{{"_id": "a", "query": map[string]interface{}{
+		"id":     map[string]interface{}{"$in": []interface{}{"a", "b"}},
+		"$limit": float64(5),
+	}}}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Find failed: wanted: %#v, got: %#v (%v)", expected, result, err)
+	}
+	result, err = service.Remove(ctx, "", *feathers.NewParamsQuery(map[string]interface{}{"done": true}))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "", "query": map[string]interface{}{"done": "true"}}) {
+		t.Errorf("Multi remove failed: %#v %v", result, err)
+	}
+
+	result, err = service.Find(ctx, *feathers.NewParamsQuery(map[string]interface{}{"paginate": true}))
+	page, ok := result.(feathers.Page)
+	if err != nil || !ok || page.Total != 1 || !reflect.DeepEqual(page.Data, []map[string]interface{}{{"_id": "a"}}) {
+		t.Errorf("Paginated find failed: %#v %v", result, err)
+	}
+}
+
+func TestServiceErrors(t *testing.T) {
+	_, remoteClient := newRemote(t)
+	_, err := remoteClient.Service("messages").Get(context.Background(), "missing", *feathers.NewParams())
+	featherErr, ok := err.(httperrors.FeathersError)
+	if !ok || featherErr.Code != 404 || featherErr.Name != "NotFound" || featherErr.Message != "No record found for id 'missing'" {
+		t.Errorf("Expected NotFound error, got: %#v", err)
+	}
+
+	unavailable := client.New("http://127.0.0.1:1")
+	_, err = unavailable.Service("messages").Get(context.Background(), "a", *feathers.NewParams())
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 503 {
+		t.Errorf("Expected Unavailable error, got: %#v", err)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	remote, remoteClient := newRemote(t)
+	ctx := context.Background()
+	_, err := remoteClient.Authenticate(ctx, map[string]interface{}{"strategy": "local", "password": "wrong"})
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 401 {
+		t.Errorf("Expected NotAuthenticated error, got: %#v", err)
+	}
+	if _, err := remoteClient.Authenticate(ctx, map[string]interface{}{"strategy": "local", "password": "secret"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if remoteClient.AccessToken() != "token" {
+		t.Errorf("Access token was not stored")
+	}
+
+	remoteClient.ForwardHeaders = []string{"Accept-Language"}
+	params := *feathers.NewParams()
+	params.Headers = map[string]string{"accept-language": "de", "cookie": "secret"}
+	if _, err := remoteClient.Service("messages").Find(ctx, params); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if remote.headers["authorization"] != "Bearer token" || remote.headers["accept-language"] != "de" || remote.headers["cookie"] != "" {
+		t.Errorf("Unexpected request headers: %#v", remote.headers)
+	}
+
+	if err := remoteClient.Logout(ctx); err != nil || remoteClient.AccessToken() != "" {
+		t.Errorf("Logout failed: %v", err)
+	}
+}
+
+func TestProxyService(t *testing.T) {
+	_, remoteClient := newRemote(t)
+	app := feathers.NewApp()
+	app.AddService("remote-messages", remoteClient.Service("messages"))
+	result, err := app.Service("remote-messages").Get(context.Background(), "a", *feathers.NewParams())
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a"}) {
+		t.Errorf("Proxy get failed: %#v %v", result, err)
+	}
+	_, err = app.Service("remote-messages").Get(context.Background(), "missing", *feathers.NewParams())
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 404 {
+		t.Errorf("Expected NotFound error, got: %#v", err)
+	}
+}
+
+func TestProxyServiceMulti(t *testing.T) {
+	_, remoteClient := newRemote(t)
+	app := feathers.NewApp()
+	app.AddService("remote-messages", remoteClient.Service("messages"))
+	app.AddService("remote-messages-multi", remoteClient.Service("messages", feathers.Remove))
+
+	params := feathers.Params{Provider: "rest", Query: map[string]interface{}{"done": true}}
+	_, err := app.Service("remote-messages").Remove(context.Background(), "", params)
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 405 {
+		t.Errorf("Expected MethodNotAllowed for multi remove, got: %#v", err)
+	}
+	if _, err := app.Service("remote-messages-multi").Remove(context.Background(), "", params); err != nil {
+		t.Errorf("Unexpected error on allowed multi remove: %s", err)
+	}
+	_, err = app.Service("remote-messages-multi").Patch(context.Background(), "", map[string]interface{}{"done": true}, params)
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 405 {
+		t.Errorf("Expected MethodNotAllowed for multi patch, got: %#v", err)
+	}
+}
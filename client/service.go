@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+)
+
+// Service is a service of the remote app (use Client.Service).
+/*
+Calls are sent to the remote app with the query of params. Multi patch and remove are sent if id is empty.
+Custom methods of the remote service can be called with Call or registered with `RegisterMethod(name, service.Method(name))`
+*/
+type Service struct {
+	*feathers.BaseService
+	client *Client
+	path   string
+}
+
+// Path returns the path of the service on the remote app
+func (s *Service) Path() string {
+	return s.path
+}
+
+func (s *Service) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	return s.client.request(ctx, http.MethodGet, s.path, "", params.Query, nil, &requestOptions{params: &params})
+}
+
+func (s *Service) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return s.client.request(ctx, http.MethodGet, s.path, id, params.Query, nil, &requestOptions{params: &params})
+}
+
+func (s *Service) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.request(ctx, http.MethodPost, s.path, "", params.Query, data, &requestOptions{params: &params})
+}
+
+// CreateMany creates multiple entities with a single request
+func (s *Service) CreateMany(ctx context.Context, data []map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.request(ctx, http.MethodPost, s.path, "", params.Query, data, &requestOptions{params: &params})
+}
+
+func (s *Service) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.request(ctx, http.MethodPut, s.path, id, params.Query, data, &requestOptions{params: &params})
+}
+
+func (s *Service) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.request(ctx, http.MethodPatch, s.path, id, params.Query, data, &requestOptions{params: &params})
+}
+
+func (s *Service) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return s.client.request(ctx, http.MethodDelete, s.path, id, params.Query, nil, &requestOptions{params: &params})
+}
+
+// Call calls the custom method of the remote service (sent as POST request with `X-Service-Method` header)
+func (s *Service) Call(ctx context.Context, method string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return s.client.request(ctx, http.MethodPost, s.path, "", params.Query, data, &requestOptions{params: &params, method: method})
+}
+
+// Method returns the custom method of the remote service as feathers.CustomMethod
+func (s *Service) Method(name string) feathers.CustomMethod {
+	return func(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+		return s.Call(ctx, name, data, params)
+	}
+}
@@ -0,0 +1,422 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	gosocketio "github.com/tobiasbeck/feathers-go/gosf-socketio"
+	"github.com/tobiasbeck/feathers-go/gosf-socketio/transport"
+)
+
+const (
+	// DefaultSocketTimeout is the timeout of socket calls
+	DefaultSocketTimeout = 10 * time.Second
+	// DefaultReconnectDelay is the delay before the first reconnect attempt (it doubles with every failed attempt)
+	DefaultReconnectDelay = 500 * time.Millisecond
+	// DefaultMaxReconnectDelay is the maximum delay between reconnect attempts
+	DefaultMaxReconnectDelay = 30 * time.Second
+	// DefaultEventBuffer is the number of events buffered per subscription
+	DefaultEventBuffer = 64
+)
+
+const (
+	// SocketDisconnected is sent to its subscribers when the connection is lost
+	SocketDisconnected = "disconnect"
+	// SocketReconnected is sent to its subscribers after the connection is restored (Data is the error of the re-authentication or nil)
+	SocketReconnected = "reconnect"
+)
+
+// SocketOptions configure a SocketClient (zero values use the defaults)
+type SocketOptions struct {
+	// Transport connects the socket (websocket transport with default settings if nil)
+	Transport transport.Transport
+	// Timeout of calls (DefaultSocketTimeout if 0)
+	Timeout time.Duration
+	// ReconnectDelay is the delay before the first reconnect attempt (DefaultReconnectDelay if 0)
+	ReconnectDelay time.Duration
+	// MaxReconnectDelay is the maximum delay between reconnect attempts (DefaultMaxReconnectDelay if 0)
+	MaxReconnectDelay time.Duration
+	// AuthenticationPath is the path of the authentication service (DefaultAuthenticationPath if empty)
+	AuthenticationPath string
+	// EventBuffer is the number of events buffered per subscription (DefaultEventBuffer if 0)
+	EventBuffer int
+}
+
+func (o *SocketOptions) setDefaults() {
+	if o.Transport == nil {
+		o.Transport = transport.GetDefaultWebsocketTransport()
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultSocketTimeout
+	}
+	if o.ReconnectDelay <= 0 {
+		o.ReconnectDelay = DefaultReconnectDelay
+	}
+	if o.MaxReconnectDelay <= 0 {
+		o.MaxReconnectDelay = DefaultMaxReconnectDelay
+	}
+	if o.AuthenticationPath == "" {
+		o.AuthenticationPath = DefaultAuthenticationPath
+	}
+	if o.EventBuffer <= 0 {
+		o.EventBuffer = DefaultEventBuffer
+	}
+}
+
+// Event is an event received from the remote app (e.g. `messages created`)
+type Event struct {
+	Name string
+	// Data is decoded like call results (entities are `map[string]interface{}`)
+	Data interface{}
+}
+
+// Subscription receives the events of one event name (use SocketClient.Subscribe)
+type Subscription struct {
+	// C receives the events. It is not closed by Unsubscribe
+	C <-chan Event
+
+	name   string
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+	client *SocketClient
+}
+
+// Unsubscribe stops the delivery of events
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.client.lock.Lock()
+		defer s.client.lock.Unlock()
+		delete(s.client.subscriptions[s.name], s)
+	})
+}
+
+// SocketClient connects to a remote feathers app over socket.io (use DialSocket).
+/*
+Calls are sent as ack emits like the feathers socket.io client does. If the connection is lost the client reconnects
+with exponential backoff and re-authenticates with the access token of the last authentication
+*/
+type SocketClient struct {
+	url     string
+	options SocketOptions
+
+	lock          sync.RWMutex
+	socket        *gosocketio.Client
+	closed        bool
+	accessToken   string
+	subscriptions map[string]map[*Subscription]bool
+}
+
+// DialSocket connects to the feathers app at url (e.g. `http://localhost:3030`, a `ws://` url with socket.io path is used as is)
+func DialSocket(url string, options SocketOptions) (*SocketClient, error) {
+	options.setDefaults()
+	client := &SocketClient{
+		url:           socketURL(url),
+		options:       options,
+		subscriptions: map[string]map[*Subscription]bool{},
+	}
+	socket, err := client.connect()
+	if err != nil {
+		return nil, httperrors.NewUnavailable(fmt.Sprintf("Could not connect to %s: %s", url, err.Error()))
+	}
+	client.socket = socket
+	return client, nil
+}
+
+// socketURL converts a http url into the websocket url of socket.io
+func socketURL(url string) string {
+	url = strings.TrimRight(url, "/")
+	switch {
+	case strings.HasPrefix(url, "https://"):
+		url = "wss://" + strings.TrimPrefix(url, "https://")
+	case strings.HasPrefix(url, "http://"):
+		url = "ws://" + strings.TrimPrefix(url, "http://")
+	}
+	if !strings.Contains(url, "/socket.io/") {
+		url += "/socket.io/?EIO=3&transport=websocket"
+	}
+	return url
+}
+
+// connect dials the socket and registers the handlers of all subscribed events
+func (c *SocketClient) connect() (*gosocketio.Client, error) {
+	socket, err := gosocketio.Dial(c.url, c.options.Transport)
+	if err != nil {
+		return nil, err
+	}
+	socket.On(gosocketio.OnDisconnection, func(channel *gosocketio.Channel) {
+		// called while the channel is locked
+		go c.reconnect(socket)
+	})
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for name := range c.subscriptions {
+		c.listen(socket, name)
+	}
+	return socket, nil
+}
+
+// listen registers the handler of event name on socket
+func (c *SocketClient) listen(socket *gosocketio.Client, name string) {
+	if name == SocketDisconnected || name == SocketReconnected {
+		return
+	}
+	socket.On(name, func(channel *gosocketio.Channel, args json.RawMessage) {
+		var data interface{}
+		if err := json.Unmarshal(eventData(args), &data); err != nil {
+			return
+		}
+		c.dispatch(Event{Name: name, Data: decodeResult(data)})
+	})
+}
+
+// eventData returns the data of an event.
+/*
+gosocketio wraps the data into a list if the message contains more than one comma (i.e. if the data contains a comma)
+*/
+func eventData(args json.RawMessage) []byte {
+	raw := strings.TrimSpace(string(args))
+	if strings.Contains(raw, ",") && strings.HasPrefix(raw, "[") && strings.HasSuffix(raw, "]") {
+		return []byte(raw[1 : len(raw)-1])
+	}
+	return []byte(raw)
+}
+
+// reconnect restores the lost connection with exponential backoff and re-authenticates it
+func (c *SocketClient) reconnect(lost *gosocketio.Client) {
+	c.lock.Lock()
+	if c.closed || c.socket != lost {
+		c.lock.Unlock()
+		return
+	}
+	c.socket = nil
+	c.lock.Unlock()
+	c.dispatch(Event{Name: SocketDisconnected})
+
+	delay := c.options.ReconnectDelay
+	for {
+		time.Sleep(delay)
+		if c.isClosed() {
+			return
+		}
+		socket, err := c.connect()
+		if err == nil {
+			c.lock.Lock()
+			if c.closed {
+				c.lock.Unlock()
+				socket.Close()
+				return
+			}
+			c.socket = socket
+			c.lock.Unlock()
+			var reconnected interface{}
+			if err := c.reauthenticate(); err != nil {
+				reconnected = err
+			}
+			c.dispatch(Event{Name: SocketReconnected, Data: reconnected})
+			return
+		}
+		delay *= 2
+		if delay > c.options.MaxReconnectDelay {
+			delay = c.options.MaxReconnectDelay
+		}
+	}
+}
+
+// reauthenticate authenticates a new connection with the jwt strategy (the access token is cleared if it fails)
+func (c *SocketClient) reauthenticate() error {
+	token := c.AccessToken()
+	if token == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.Timeout)
+	defer cancel()
+	_, err := c.Authenticate(ctx, map[string]interface{}{"strategy": "jwt", "accessToken": token})
+	if err != nil {
+		c.setAccessToken("")
+	}
+	return err
+}
+
+// dispatch sends event to the subscribers of its name (blocks until each subscriber received it or unsubscribed)
+func (c *SocketClient) dispatch(event Event) {
+	c.lock.RLock()
+	subscriptions := make([]*Subscription, 0, len(c.subscriptions[event.Name]))
+	for subscription := range c.subscriptions[event.Name] {
+		subscriptions = append(subscriptions, subscription)
+	}
+	c.lock.RUnlock()
+	for _, subscription := range subscriptions {
+		select {
+		case subscription.events <- event:
+		case <-subscription.done:
+		}
+	}
+}
+
+func (c *SocketClient) isClosed() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.closed
+}
+
+// Connected returns if the socket is connected
+func (c *SocketClient) Connected() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.socket != nil && c.socket.IsAlive()
+}
+
+// Close closes the connection (it is not reconnected)
+func (c *SocketClient) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	if c.socket != nil {
+		c.socket.Close()
+		c.socket = nil
+	}
+}
+
+// Subscribe subscribes to event name (e.g. `messages created`, SocketDisconnected or SocketReconnected).
+/*
+Subscriptions are kept on reconnect. Events are delivered blocking, so C has to be read until Unsubscribe is called
+*/
+func (c *SocketClient) Subscribe(name string) *Subscription {
+	events := make(chan Event, c.options.EventBuffer)
+	subscription := &Subscription{
+		C:      events,
+		name:   name,
+		events: events,
+		done:   make(chan struct{}),
+		client: c,
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.subscriptions[name]; !ok {
+		c.subscriptions[name] = map[*Subscription]bool{}
+		if c.socket != nil {
+			c.listen(c.socket, name)
+		}
+	}
+	c.subscriptions[name][subscription] = true
+	return subscription
+}
+
+// AccessToken returns the access token of the last authentication
+func (c *SocketClient) AccessToken() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.accessToken
+}
+
+func (c *SocketClient) setAccessToken(token string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.accessToken = token
+}
+
+// Authenticate authenticates the connection (e.g. `{"strategy": "local", "email": ..., "password": ...}`) and stores the access token.
+/*
+The result of the authentication service is returned
+*/
+func (c *SocketClient) Authenticate(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	result, err := c.call(ctx, "create", c.options.AuthenticationPath, data, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	authResult, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, httperrors.NewGeneralError("Invalid authentication response")
+	}
+	if token, ok := authResult["accessToken"].(string); ok {
+		c.setAccessToken(token)
+	}
+	return authResult, nil
+}
+
+// Logout removes the authentication of the connection and clears the access token
+func (c *SocketClient) Logout(ctx context.Context) error {
+	token := c.AccessToken()
+	if token == "" {
+		return nil
+	}
+	_, err := c.call(ctx, "remove", c.options.AuthenticationPath, token, map[string]interface{}{})
+	c.setAccessToken("")
+	return err
+}
+
+// Service returns the remote service at path. It implements feathers.Service and can be registered with `App.AddService` as proxy.
+/*
+All calls are sent with the authentication of the connection, so multi calls are only allowed for the methods passed as
+multi (e.g. `client.Service("messages", feathers.Patch)`)
+*/
+func (c *SocketClient) Service(path string, multi ...feathers.RestMethod) *SocketService {
+	return &SocketService{
+		BaseService: &feathers.BaseService{
+			Multi: multi,
+		},
+		client: c,
+		path:   strings.Trim(path, "/"),
+	}
+}
+
+// call emits method with args and waits for the acknowledgement
+func (c *SocketClient) call(ctx context.Context, method string, args ...interface{}) (interface{}, error) {
+	c.lock.RLock()
+	socket := c.socket
+	c.lock.RUnlock()
+	if socket == nil || !socket.IsAlive() {
+		return nil, httperrors.NewUnavailable("Socket is not connected")
+	}
+	timeout := c.options.Timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	raw, err := socket.AckArgs(method, args, timeout)
+	if err == gosocketio.ErrorSendTimeout {
+		return nil, httperrors.NewTimeout(fmt.Sprintf("Timeout of %s exceeded calling %s", timeout, method))
+	}
+	if err != nil {
+		return nil, httperrors.NewUnavailable(fmt.Sprintf("Could not call %s: %s", method, err.Error()))
+	}
+	return decodeAck(raw)
+}
+
+// decodeAck decodes the arguments of an acknowledgement (`error` or `null, result`)
+func decodeAck(raw string) (interface{}, error) {
+	var args []interface{}
+	if err := json.Unmarshal([]byte("["+raw+"]"), &args); err != nil {
+		return nil, httperrors.NewBadGateway("Invalid socket response: " + err.Error())
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if args[0] != nil {
+		return nil, ackError(args[0])
+	}
+	if len(args) < 2 {
+		return nil, nil
+	}
+	return decodeResult(args[1]), nil
+}
+
+// ackError converts the error of an acknowledgement into a FeathersError
+func ackError(value interface{}) error {
+	raw, _ := json.Marshal(value)
+	var featherErr httperrors.FeathersError
+	if err := json.Unmarshal(raw, &featherErr); err == nil && featherErr.Code != 0 && featherErr.Name != "" {
+		return featherErr
+	}
+	if featherErr.Message != "" {
+		return httperrors.NewGeneralError(featherErr.Message)
+	}
+	return httperrors.NewGeneralError("Unknown error")
+}
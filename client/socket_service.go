@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+)
+
+// SocketService is a service of the remote app called over socket.io (use SocketClient.Service).
+/*
+Calls are emitted with the arguments the feathers socket.io client uses (e.g. `patch` with path, id, data and query).
+Multi patch and remove are sent if id is empty
+*/
+type SocketService struct {
+	*feathers.BaseService
+	client *SocketClient
+	path   string
+}
+
+// Path returns the path of the service on the remote app
+func (s *SocketService) Path() string {
+	return s.path
+}
+
+// socketID returns the id argument of a call (null for multi calls)
+func socketID(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+// socketQuery returns the query argument of a call (the server expects an object)
+func socketQuery(params feathers.Params) map[string]interface{} {
+	if params.Query == nil {
+		return map[string]interface{}{}
+	}
+	return params.Query
+}
+
+func (s *SocketService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	return s.client.call(ctx, "find", s.path, socketQuery(params))
+}
+
+func (s *SocketService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return s.client.call(ctx, "get", s.path, id, socketQuery(params))
+}
+
+func (s *SocketService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.call(ctx, "create", s.path, data, socketQuery(params))
+}
+
+// CreateMany creates multiple entities with a single call
+func (s *SocketService) CreateMany(ctx context.Context, data []map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.call(ctx, "create", s.path, data, socketQuery(params))
+}
+
+func (s *SocketService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.call(ctx, "update", s.path, id, data, socketQuery(params))
+}
+
+func (s *SocketService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return s.client.call(ctx, "patch", s.path, socketID(id), data, socketQuery(params))
+}
+
+func (s *SocketService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return s.client.call(ctx, "remove", s.path, socketID(id), socketQuery(params))
+}
+
+// Call calls the custom method of the remote service (emitted with path, data and query)
+func (s *SocketService) Call(ctx context.Context, method string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return s.client.call(ctx, method, s.path, data, socketQuery(params))
+}
+
+// Method returns the custom method of the remote service as feathers.CustomMethod
+func (s *SocketService) Method(name string) feathers.CustomMethod {
+	return func(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+		return s.Call(ctx, name, data, params)
+	}
+}
+
+// On subscribes to an event of the service (e.g. `created` for `<path> created`)
+func (s *SocketService) On(event string) *Subscription {
+	return s.client.Subscribe(s.path + " " + event)
+}
@@ -0,0 +1,177 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/client"
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	gosocketio "github.com/tobiasbeck/feathers-go/gosf-socketio"
+)
+
+// newSocketRemote starts a socket.io server. Connections join the room `all` and are sent to the returned channel
+func newSocketRemote(t *testing.T) (*authenticationService, <-chan *gosocketio.Channel, *client.SocketClient) {
+	app, remote, authentication := newRemoteApp()
+	remote.RegisterPublishHandler("created", func(data interface{}, ctx *feathers.Context) []string {
+		return []string{"all"}
+	})
+	provider := feathers.NewSocketIOProvider(app, map[string]interface{}{})
+	app.AddProvider("socketio", provider)
+	channels := make(chan *gosocketio.Channel, 10)
+	connections, unregister := app.On("connection")
+	t.Cleanup(func() { unregister() })
+	go func() {
+		for connection := range connections {
+			channel := connection.(*gosocketio.Channel)
+			channel.Join("all")
+			channels <- channel
+		}
+	}()
+	mux := http.NewServeMux()
+	provider.Listen(0, mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	socketClient, err := client.DialSocket(server.URL, client.SocketOptions{
+		Timeout:           2 * time.Second,
+		ReconnectDelay:    10 * time.Millisecond,
+		MaxReconnectDelay: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	t.Cleanup(socketClient.Close)
+	return authentication, channels, socketClient
+}
+
+func receive(t *testing.T, subscription *client.Subscription) client.Event {
+	select {
+	case event := <-subscription.C:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatalf("No event received")
+	}
+	return client.Event{}
+}
+
+func TestSocketServiceMethods(t *testing.T) {
+	_, _, socketClient := newSocketRemote(t)
+	service := socketClient.Service("messages")
+	ctx := context.Background()
+	params := *feathers.NewParams()
+
+	result, err := service.Get(ctx, "a", params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a"}) {
+		t.Errorf("Get failed: %#v %v", result, err)
+	}
+	result, err = service.Patch(ctx, "a", map[string]interface{}{"text": "hello"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a", "text": "hello"}) {
+		t.Errorf("Patch failed: %#v %v", result, err)
+	}
+	result, err = service.Call(ctx, "approve", map[string]interface{}{"_id": "a"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "a", "approved": true}) {
+		t.Errorf("Custom method failed: %#v %v", result, err)
+	}
+	result, err = service.Find(ctx, *feathers.NewParamsQuery(map[string]interface{}{"text": "hello"}))
+	expected := []map[string]interface{}{{"_id": "a", "query": map[string]interface{}{"text": "hello"}}}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Find failed: wanted: %#v, got: %#v (%v)", expected, result, err)
+	}
+	result, err = service.Remove(ctx, "", *feathers.NewParamsQuery(map[string]interface{}{"done": true}))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "", "query": map[string]interface{}{"done": true}}) {
+		t.Errorf("Multi remove failed: %#v %v", result, err)
+	}
+
+	_, err = service.Get(ctx, "missing", params)
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 404 || featherErr.Message != "No record found for id 'missing'" {
+		t.Errorf("Expected NotFound error, got: %#v", err)
+	}
+}
+
+func TestSocketEvents(t *testing.T) {
+	_, channels, socketClient := newSocketRemote(t)
+	<-channels
+	service := socketClient.Service("messages")
+	created := service.On("created")
+	defer created.Unsubscribe()
+
+	if _, err := service.Create(context.Background(), map[string]interface{}{"text": "hello", "user": "a"}, *feathers.NewParams()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	event := receive(t, created)
+	expected := map[string]interface{}{"_id": "new", "text": "hello", "user": "a"}
+	if event.Name != "messages created" || !reflect.DeepEqual(event.Data, expected) {
+		t.Errorf("Unexpected event: %#v", event)
+	}
+
+	if _, err := service.Create(context.Background(), map[string]interface{}{}, *feathers.NewParams()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if event := receive(t, created); !reflect.DeepEqual(event.Data, map[string]interface{}{"_id": "new"}) {
+		t.Errorf("Unexpected event without comma: %#v", event)
+	}
+}
+
+func TestSocketReconnect(t *testing.T) {
+	authentication, channels, socketClient := newSocketRemote(t)
+	ctx := context.Background()
+	if _, err := socketClient.Authenticate(ctx, map[string]interface{}{"strategy": "local", "password": "secret"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if socketClient.AccessToken() != "token" {
+		t.Errorf("Access token was not stored")
+	}
+
+	disconnected := socketClient.Subscribe(client.SocketDisconnected)
+	reconnected := socketClient.Subscribe(client.SocketReconnected)
+	created := socketClient.Service("messages").On("created")
+	(<-channels).Close()
+
+	receive(t, disconnected)
+	if event := receive(t, reconnected); event.Data != nil {
+		t.Errorf("Re-authentication failed: %v", event.Data)
+	}
+	if !socketClient.Connected() {
+		t.Errorf("Client should be connected")
+	}
+	if strategies := authentication.authentications(); !reflect.DeepEqual(strategies, []string{"local", "jwt"}) {
+		t.Errorf("Expected re-authentication with jwt strategy, got: %v", strategies)
+	}
+
+	<-channels
+	if _, err := socketClient.Service("messages").Create(ctx, map[string]interface{}{"text": "again"}, *feathers.NewParams()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if event := receive(t, created); event.Name != "messages created" {
+		t.Errorf("Subscription was not restored: %#v", event)
+	}
+
+	if err := socketClient.Logout(ctx); err != nil || socketClient.AccessToken() != "" {
+		t.Errorf("Logout failed: %v", err)
+	}
+	socketClient.Close()
+	if _, err := socketClient.Service("messages").Get(ctx, "a", *feathers.NewParams()); err == nil {
+		t.Errorf("Calls on closed client should fail")
+	}
+}
+
+func TestSocketProxyServiceMulti(t *testing.T) {
+	_, _, socketClient := newSocketRemote(t)
+	app := feathers.NewApp()
+	app.AddService("remote-messages", socketClient.Service("messages"))
+	app.AddService("remote-messages-multi", socketClient.Service("messages", feathers.Remove))
+
+	params := feathers.Params{Provider: "socketio", Query: map[string]interface{}{"done": true}}
+	_, err := app.Service("remote-messages").Remove(context.Background(), "", params)
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 405 {
+		t.Errorf("Expected MethodNotAllowed for multi remove, got: %#v", err)
+	}
+	if _, err := app.Service("remote-messages-multi").Remove(context.Background(), "", params); err != nil {
+		t.Errorf("Unexpected error on allowed multi remove: %s", err)
+	}
+}
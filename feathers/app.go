@@ -67,6 +67,29 @@ func filterData(location locationType, method RestMethod, data map[string]interf
 	return nil
 }
 
+// normalizeRequestData splits request data into single entity data and multi data (for multi create)
+func normalizeRequestData(data interface{}) (Data, []Data, error) {
+	switch v := data.(type) {
+	case nil:
+		return Data{}, nil, nil
+	case map[string]interface{}:
+		return v, nil, nil
+	case []map[string]interface{}:
+		return Data{}, v, nil
+	case []interface{}:
+		items := make([]Data, 0, len(v))
+		for _, item := range v {
+			mapItem, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, nil, httperrors.NewBadRequest("Data list may only contain objects")
+			}
+			items = append(items, mapItem)
+		}
+		return Data{}, items, nil
+	}
+	return nil, nil, httperrors.NewBadRequest(fmt.Sprintf("Invalid data of type %T", data))
+}
+
 func paramContextCancelled(ctx Context) bool {
 	context := ctx.Context
 	if context.Err() == nil {
@@ -75,6 +98,52 @@ func paramContextCancelled(ctx Context) bool {
 	return true
 }
 
+// DefaultTimeout is the request timeout used if no timeout is configured
+const DefaultTimeout = 5 * time.Second
+
+// ParseTimeout parses a timeout from configuration.
+/*
+Numbers are milliseconds, strings are parsed as go duration (e.g. "30s") and `false` or 0 disables the timeout
+*/
+func ParseTimeout(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case int:
+		return time.Duration(v) * time.Millisecond, nil
+	case int64:
+		return time.Duration(v) * time.Millisecond, nil
+	case float64:
+		return time.Duration(v * float64(time.Millisecond)), nil
+	case bool:
+		if v {
+			return DefaultTimeout, nil
+		}
+		return 0, nil
+	case string:
+		return time.ParseDuration(v)
+	}
+	return 0, fmt.Errorf("cannot parse timeout of type %T", value)
+}
+
+// onceCaller makes sure a caller is only answered once (either by the pipeline or on timeout / cancellation)
+type onceCaller struct {
+	Caller
+	once sync.Once
+}
+
+func (c *onceCaller) Callback(data interface{}) {
+	c.once.Do(func() {
+		c.Caller.Callback(data)
+	})
+}
+
+func (c *onceCaller) CallbackError(err error) {
+	c.once.Do(func() {
+		c.Caller.CallbackError(err)
+	})
+}
+
 // ---------------
 
 // Provider handles requests and can listen for new connections
@@ -160,10 +229,15 @@ func (a *App) AddService(name string, service Service) {
 
 func (a *App) handleServerServiceCall(ctx context.Context, service string, method RestMethod, c Caller, data interface{}, id string, params Params) {
 	if serviceInstance, ok := a.services[service]; ok {
+		singleData, multiData, err := normalizeRequestData(data)
+		if err != nil {
+			c.CallbackError(err)
+			return
+		}
 		initContext := Context{
-			Context:      ctx,
 			App:          *a,
-			Data:         data.(map[string]interface{}),
+			Data:         singleData,
+			MultiData:    multiData,
 			Method:       method,
 			Path:         service,
 			ID:           id,
@@ -172,7 +246,7 @@ func (a *App) handleServerServiceCall(ctx context.Context, service string, metho
 			Type:         Before,
 			Params:       params,
 		}
-		go a.handlePipeline(&initContext, serviceInstance, c)
+		a.startPipeline(ctx, &initContext, serviceInstance, c)
 		return
 	}
 	c.CallbackError(httperrors.NewNotFound(fmt.Sprintf("Unknown Service %s", service)))
@@ -181,14 +255,17 @@ func (a *App) handleServerServiceCall(ctx context.Context, service string, metho
 }
 
 // HandleRequest handles a request received by a provider. It starts the pipeline and schedules tasks
-func (a *App) HandleRequest(provider string, method RestMethod, c Caller, service string, data map[string]interface{}, id string, query map[string]interface{}) {
+/*
+data is either a map or a list of maps (multi create)
+*/
+func (a *App) HandleRequest(provider string, method RestMethod, c Caller, service string, data interface{}, id string, query map[string]interface{}) {
 	// fmt.Printf("Request:\n  service: %s\n  method: %s\n  data: %+v\n query: %+v\n\n", service, method, data, query)
 	if serviceInstance, ok := a.services[service]; ok {
-		context, _ := context.WithTimeout(context.Background(), 5*time.Second)
-		go func() {
-			<-context.Done()
-		}()
-
+		singleData, multiData, err := normalizeRequestData(data)
+		if err != nil {
+			go c.CallbackError(err)
+			return
+		}
 		authenticated := false
 
 		if connection := c.SocketConnection(); connection != nil {
@@ -198,15 +275,15 @@ func (a *App) HandleRequest(provider string, method RestMethod, c Caller, servic
 		var user map[string]interface{}
 		if connection := c.SocketConnection(); connection != nil {
 			if connection.IsAuthenticated() {
-				user = connection.AuthEntity().(map[string]interface{})
+				user, _ = connection.AuthEntity().(map[string]interface{})
 			}
 
 		}
 
 		initContext := Context{
-			Context:      context,
 			App:          *a,
-			Data:         data,
+			Data:         singleData,
+			MultiData:    multiData,
 			Method:       method,
 			Path:         service,
 			ID:           id,
@@ -226,7 +303,24 @@ func (a *App) HandleRequest(provider string, method RestMethod, c Caller, servic
 				Authenticated: authenticated,
 			},
 		}
-		go a.handlePipeline(&initContext, serviceInstance, c)
+		if headerCaller, ok := c.(HeaderCaller); ok {
+			initContext.Params.Headers = headerCaller.Headers()
+		}
+		if addressCaller, ok := c.(RemoteAddressCaller); ok {
+			initContext.Params.Set("remoteAddress", addressCaller.RemoteAddress())
+		}
+		if connection, ok := c.SocketConnection().(AuthenticationConnection); ok && connection.Authentication() != nil {
+			authentication := connection.Authentication()
+			initContext.Params.Set("authentication", authentication)
+			if permissions, ok := authentication["permissions"]; ok {
+				initContext.Params.Set("permissions", permissions)
+			}
+		}
+		parent := context.Background()
+		if contextCaller, ok := c.(ContextCaller); ok {
+			parent = contextCaller.Context()
+		}
+		a.startPipeline(parent, &initContext, serviceInstance, c)
 		return
 	}
 	go func() {
@@ -236,6 +330,58 @@ func (a *App) HandleRequest(provider string, method RestMethod, c Caller, servic
 	return
 }
 
+// requestTimeout returns the timeout for a call of method on service (0 means no timeout)
+/*
+The timeout configured by the service (see `BaseService.Timeouts`) has precedence over the `timeout` config key of the app
+*/
+func (a *App) requestTimeout(service Service, method RestMethod) time.Duration {
+	if timeoutService, ok := service.(TimeoutService); ok {
+		if timeout, ok := timeoutService.Timeout(method); ok {
+			return timeout
+		}
+	}
+	if value, ok := a.config["timeout"]; ok {
+		timeout, err := ParseTimeout(value)
+		if err == nil {
+			return timeout
+		}
+		log.Warnln("Invalid timeout config: " + err.Error())
+	}
+	return DefaultTimeout
+}
+
+// startPipeline runs the pipeline for initContext with a context derived from parent.
+/*
+The context is cancelled if parent is cancelled, if the timeout is exceeded or when the pipeline has finished.
+The caller is answered as soon as the context is done, even if the service does not respect the context
+*/
+func (a *App) startPipeline(parent context.Context, initContext *Context, service Service, c Caller) {
+	timeout := a.requestTimeout(service, initContext.Method)
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	initContext.Context = ctx
+	caller := &onceCaller{Caller: c}
+
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() == context.DeadlineExceeded {
+			caller.CallbackError(httperrors.NewTimeout(fmt.Sprintf("Timeout exceeded calling %s on %s", initContext.Method, initContext.Path)))
+			return
+		}
+		// If the pipeline has finished already this does nothing
+		caller.CallbackError(httperrors.NewGeneralError("Request was cancelled"))
+	}()
+	go func() {
+		defer cancel()
+		a.handlePipeline(initContext, service, caller)
+	}()
+}
+
 func (a *App) handlePipeline(ctx *Context, service Service, c Caller) {
 	var err error
 
@@ -254,9 +400,17 @@ func (a *App) handlePipeline(ctx *Context, service Service, c Caller) {
 	}
 	if ctx.Result == nil {
 		var result interface{}
+		if err = checkMulti(ctx, service); err != nil {
+			a.handlePipelineError(err, ctx, service, c)
+			return
+		}
 		switch ctx.Method {
 		case Create:
-			result, err = service.Create(ctx, ctx.Data, ctx.Params)
+			if ctx.MultiData != nil {
+				result, err = createMany(ctx, service)
+			} else {
+				result, err = service.Create(ctx, ctx.Data, ctx.Params)
+			}
 		case Update:
 			result, err = service.Update(ctx, ctx.ID, ctx.Data, ctx.Params)
 		case Patch:
@@ -267,6 +421,8 @@ func (a *App) handlePipeline(ctx *Context, service Service, c Caller) {
 			result, err = service.Find(ctx, ctx.Params)
 		case Get:
 			result, err = service.Get(ctx, ctx.ID, ctx.Params)
+		default:
+			result, err = callCustomMethod(ctx, service)
 		}
 		if err != nil {
 			a.handlePipelineError(err, ctx, service, c)
@@ -280,10 +436,92 @@ func (a *App) handlePipeline(ctx *Context, service Service, c Caller) {
 		return
 	}
 	c.Callback(ctx.Result)
-	go a.TriggerUpdate(ctx)
+	// The pipeline already runs in its own go routine. Publishing here keeps the request context alive until it is done
+	a.TriggerUpdate(ctx)
 
 }
 
+// checkMulti returns a error if the call affects multiple entities but the service does not allow it for the method
+func checkMulti(ctx *Context, service Service) error {
+	isMulti := false
+	switch ctx.Method {
+	case Create:
+		isMulti = ctx.MultiData != nil
+	case Patch, Remove:
+		isMulti = ctx.ID == ""
+	case Update:
+		if ctx.ID == "" {
+			return httperrors.NewBadRequest("You can not replace multiple instances. Did you mean 'patch'?")
+		}
+	}
+	if !isMulti {
+		return nil
+	}
+	if multiService, ok := service.(MultiService); ok && multiService.AllowsMulti(ctx.Method) {
+		return nil
+	}
+	return httperrors.NewMethodNotAllowed(fmt.Sprintf("Can not %s multiple entries", ctx.Method))
+}
+
+// callCustomMethod calls the custom method of the service named like the context method
+func callCustomMethod(ctx *Context, service Service) (interface{}, error) {
+	if customService, ok := service.(CustomMethodService); ok {
+		if method, ok := customService.CustomMethod(ctx.Method.String()); ok {
+			return method(ctx, ctx.Data, ctx.Params)
+		}
+	}
+	return nil, httperrors.NewMethodNotAllowed(fmt.Sprintf("Method '%s' not allowed on service '%s'", ctx.Method, ctx.Path))
+}
+
+// customMethodNames returns the names of all custom methods of all registered services
+func (a *App) customMethodNames() []string {
+	a.servicesLock.RLock()
+	defer a.servicesLock.RUnlock()
+	names := []string{}
+	for _, service := range a.services {
+		if customService, ok := service.(CustomMethodService); ok {
+			for _, name := range customService.CustomMethodNames() {
+				if !Contains(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// createMany calls `CreateMany` of the service or `Create` for each entity if it is not implemented
+func createMany(ctx *Context, service Service) (interface{}, error) {
+	data := ctx.MultiData
+	if multiCreateService, ok := service.(MultiCreateService); ok {
+		return multiCreateService.CreateMany(ctx, data, ctx.Params)
+	}
+	results := make([]interface{}, 0, len(data))
+	for _, item := range data {
+		result, err := service.Create(ctx, item, ctx.Params)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// publishItems splits a result into the entities for which events are published
+func publishItems(result interface{}) []interface{} {
+	switch v := result.(type) {
+	case []interface{}:
+		return v
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	}
+	return []interface{}{result}
+}
+
 func (a *App) TriggerUpdate(ctx *Context) {
 	// fmt.Printf("TRIGGER UPDATE: %s\n\n", ctx.Path)
 	// fmt.Printf("Service: %T\n", ctx.ServiceClass)
@@ -291,10 +529,15 @@ func (a *App) TriggerUpdate(ctx *Context) {
 	//Afterwards trigger updates
 	if service, ok := ctx.Service.(PublishableService); ok {
 		if event := eventFromCallMethod(ctx.Method); event != "" {
-			if rooms, err := service.Publish(event, ctx.Result, ctx); err == nil {
-				serviceEvent := fmt.Sprintf("%s %s", ctx.Path, event)
+			serviceEvent := fmt.Sprintf("%s %s", ctx.Path, event)
+			// Multi calls publish one event per entity
+			for _, item := range publishItems(ctx.Result) {
+				rooms, err := service.Publish(event, item, ctx)
+				if err != nil {
+					continue
+				}
 				for _, room := range rooms {
-					data, err := service.BeforePublish(room, ctx.Result, ctx)
+					data, err := service.BeforePublish(room, item, ctx)
 					if err != nil || data == nil {
 						fmt.Println("SKIP SENDING", err, data)
 						continue
@@ -326,7 +569,11 @@ func (a *App) handleHookChain(ctx *Context, chainType HookType, service Service)
 func (a *App) handlePipelineError(err error, ctx *Context, service Service, c Caller) {
 	featherError := err
 	if _, ok := err.(httperrors.FeathersError); !ok {
-		featherError = httperrors.Convert(err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			featherError = httperrors.NewTimeout(fmt.Sprintf("Timeout exceeded calling %s on %s", ctx.Method, ctx.Path))
+		} else {
+			featherError = httperrors.Convert(err)
+		}
 	}
 	ctx.Error = featherError
 	ctx, chainErr := a.handleHookChain(ctx, Error, service)
@@ -16,6 +16,8 @@ type Context struct {
 	App App
 	// Data is the data passed from the requesting instance
 	Data Data
+	// MultiData contains the entities passed to a multi create call (Data is empty in that case)
+	MultiData []Data
 	// Error contains the error which was triggered while executing the route
 	Error error
 	// ID is the id passed fromt the requesting instance
@@ -23,13 +23,23 @@ func ToMapSlice(result interface{}, errs ...error) ([]map[string]interface{}, er
 			}
 		}
 	}
-	if list, ok := result.([]map[string]interface{}); ok {
+	switch list := result.(type) {
+	case []map[string]interface{}:
 		return list, nil
+	case Page:
+		return ToMapSlice(list.Data)
+	case *Page:
+		return ToMapSlice(list.Data)
 	}
 	return nil, errors.New("result is not a map slice")
 }
 
 func ToMap(result interface{}, errs ...error) (map[string]interface{}, error) {
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
 	if result == nil {
 		return nil, nil
 	}
@@ -48,6 +48,22 @@ const (
 	All RestMethod = "all"
 )
 
+// standardMethods are the methods every service implements
+var standardMethods = []RestMethod{Find, Get, Create, Update, Patch, Remove}
+
+// isCustomMethod returns true if method is not one of the standard service methods
+func isCustomMethod(method RestMethod) bool {
+	if method == All {
+		return false
+	}
+	for _, standard := range standardMethods {
+		if standard == method {
+			return false
+		}
+	}
+	return true
+}
+
 func eventFromCallMethod(method RestMethod) string {
 	switch method {
 	case Create:
@@ -136,7 +152,12 @@ func (hc *Params) Copy() Params {
 	np.User = hc.User
 	np.Authenticated = hc.Authenticated
 	np.Params = deepCopyMap(hc.Params)
-	// np.Headers = deepCopyMap(hc.Headers)
+	if hc.Headers != nil {
+		np.Headers = make(map[string]string, len(hc.Headers))
+		for key, value := range hc.Headers {
+			np.Headers[key] = value
+		}
+	}
 	np.fields = deepCopyMap(hc.fields)
 	return np
 }
@@ -1,8 +1,11 @@
 package feathers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -17,7 +20,34 @@ type requestRegistration struct {
 	query   map[string]interface{}
 }
 type httpCaller struct {
-	response chan<- interface{}
+	response      chan<- interface{}
+	ctx           context.Context
+	headers       map[string]string
+	remoteAddress string
+}
+
+// Context returns the context of the http request (cancelled if the client goes away)
+func (c *httpCaller) Context() context.Context {
+	return c.ctx
+}
+
+// Headers returns the http headers of the request
+func (c *httpCaller) Headers() map[string]string {
+	return c.headers
+}
+
+// RemoteAddress returns the network address of the client
+func (c *httpCaller) RemoteAddress() string {
+	return c.remoteAddress
+}
+
+// requestHeaders converts http headers into a map with lower case keys (multiple values are joined by ", ")
+func requestHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for key, values := range header {
+		headers[strings.ToLower(key)] = strings.Join(values, ", ")
+	}
+	return headers
 }
 
 func (c *httpCaller) Callback(data interface{}) {
@@ -38,22 +68,45 @@ func (c *httpCaller) SocketConnection() Connection {
 	return nil
 }
 
+// ServiceMethodHeader is the http header which selects a custom service method for POST requests
+const ServiceMethodHeader = "X-Service-Method"
+
+// DefaultMaxBodySize is the maximum size of a request body in bytes if not configured otherwise
+const DefaultMaxBodySize int64 = 1 << 20
+
 //HttpProvider is a provider for feathers-go which listens to http requests
 type HttpProvider struct {
 	server *http.ServeMux
 	app    *App
+	// MaxBodySize is the maximum size of a request body in bytes. Larger bodies are rejected
+	MaxBodySize int64
 }
 
 // NewHttpProvider creates a new http provider (injection to app happens through module: `onfigureHttpProvider`)
 func NewHttpProvider(app *App) *HttpProvider {
 	provider := new(HttpProvider)
 	provider.app = app
+	provider.MaxBodySize = DefaultMaxBodySize
 	return provider
 }
 
 // Use this in combination with `App.Configure` to be able to listen for http requests
+/*
+Supported config keys:
+`maxBodySize` maximum size of a request body in bytes (default: 1MB)
+*/
 func ConfigureHttpProvider(app *App, config map[string]interface{}) error {
 	provider := NewHttpProvider(app)
+	if maxBodySize, ok := config["maxBodySize"]; ok {
+		switch v := maxBodySize.(type) {
+		case int:
+			provider.MaxBodySize = int64(v)
+		case int64:
+			provider.MaxBodySize = v
+		default:
+			return fmt.Errorf("maxBodySize has to be an integer (got %T)", maxBodySize)
+		}
+	}
 	app.AddProvider("http", provider)
 	return nil
 }
@@ -69,13 +122,19 @@ func (p *HttpProvider) Publish(room string, event string, data interface{}, path
 
 //ServceHttp is implemented from http.Handler. It handles a request
 func (h *HttpProvider) ServeHTTP(response http.ResponseWriter, request *http.Request) {
-	// Todo imporove error handling
-	serviceRequest, _ := RequestVars(*request)
+	serviceRequest, err := RequestVars(*request)
+	if err != nil {
+		h.respond(response, err)
+		return
+	}
 
 	if _, ok := h.app.services[serviceRequest.service]; ok {
 		chanResponse := make(chan interface{}, 0)
 		caller := httpCaller{
-			response: chanResponse,
+			response:      chanResponse,
+			ctx:           request.Context(),
+			headers:       requestHeaders(request.Header),
+			remoteAddress: request.RemoteAddr,
 		}
 
 		switch request.Method {
@@ -92,17 +151,38 @@ func (h *HttpProvider) ServeHTTP(response http.ResponseWriter, request *http.Req
 
 			h.respond(response, result)
 		case "POST":
-			h.app.HandleRequest("http", Create, &caller, serviceRequest.service, make(map[string]interface{}), serviceRequest.id, serviceRequest.query)
+			method := Create
+			var data interface{}
+			if customMethod := request.Header.Get(ServiceMethodHeader); customMethod != "" {
+				method, data, err = h.customMethodRequest(response, request, customMethod)
+			} else {
+				data, err = ParseRequestBody(response, request, h.MaxBodySize)
+			}
+			if err != nil {
+				h.respond(response, err)
+				return
+			}
+			h.app.HandleRequest("http", method, &caller, serviceRequest.service, data, serviceRequest.id, serviceRequest.query)
 			result := <-chanResponse
 			h.respond(response, result)
 
 		case "PUT":
-			h.app.HandleRequest("http", Update, &caller, serviceRequest.service, make(map[string]interface{}), serviceRequest.id, serviceRequest.query)
+			data, err := h.requestData(response, request)
+			if err != nil {
+				h.respond(response, err)
+				return
+			}
+			h.app.HandleRequest("http", Update, &caller, serviceRequest.service, data, serviceRequest.id, serviceRequest.query)
 			result := <-chanResponse
 			h.respond(response, result)
 
 		case "PATCH":
-			h.app.HandleRequest("http", Patch, &caller, serviceRequest.service, make(map[string]interface{}), serviceRequest.id, serviceRequest.query)
+			data, err := h.requestData(response, request)
+			if err != nil {
+				h.respond(response, err)
+				return
+			}
+			h.app.HandleRequest("http", Patch, &caller, serviceRequest.service, data, serviceRequest.id, serviceRequest.query)
 			result := <-chanResponse
 			h.respond(response, result)
 		case "DELETE":
@@ -115,6 +195,98 @@ func (h *HttpProvider) ServeHTTP(response http.ResponseWriter, request *http.Req
 	http.StripPrefix("/", http.FileServer(http.Dir("./public/"))).ServeHTTP(response, request)
 }
 
+// customMethodRequest returns the custom service method and data of a POST request with `X-Service-Method` header
+func (h *HttpProvider) customMethodRequest(response http.ResponseWriter, request *http.Request, customMethod string) (RestMethod, interface{}, error) {
+	method := RestMethod(customMethod)
+	if !isCustomMethod(method) {
+		return method, nil, httperrors.NewMethodNotAllowed(fmt.Sprintf("Method '%s' cannot be called through %s header", customMethod, ServiceMethodHeader))
+	}
+	data, err := h.requestData(response, request)
+	return method, data, err
+}
+
+// requestData parses the request body and returns it as service data (only single entities are allowed)
+func (h *HttpProvider) requestData(response http.ResponseWriter, request *http.Request) (map[string]interface{}, error) {
+	body, err := ParseRequestBody(response, request, h.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := body.(map[string]interface{}); ok {
+		return data, nil
+	}
+	return nil, httperrors.NewBadRequest("Request body has to be an object")
+}
+
+// ParseRequestBody reads the body of a http request according to its Content-Type.
+/*
+JSON bodies are returned as `map[string]interface{}` or as `[]map[string]interface{}` (for arrays of objects).
+`application/x-www-form-urlencoded` bodies are returned as `map[string]interface{}`.
+An empty body results in an empty map. Bodies larger than `maxBodySize` bytes are rejected.
+*/
+func ParseRequestBody(response http.ResponseWriter, request *http.Request, maxBodySize int64) (interface{}, error) {
+	if request.Body == nil {
+		return map[string]interface{}{}, nil
+	}
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	if request.ContentLength > maxBodySize {
+		return nil, httperrors.NewPayloadTooLarge(fmt.Sprintf("Request body exceeds limit of %d bytes", maxBodySize))
+	}
+	raw, err := ioutil.ReadAll(http.MaxBytesReader(response, request.Body, maxBodySize))
+	if err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			return nil, httperrors.NewPayloadTooLarge(fmt.Sprintf("Request body exceeds limit of %d bytes", maxBodySize))
+		}
+		return nil, httperrors.NewBadRequest("Could not read request body")
+	}
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	mediaType := "application/json"
+	if contentType := request.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, httperrors.NewBadRequest("Invalid Content-Type header")
+		}
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return parseJSONBody(raw)
+	case mediaType == "application/x-www-form-urlencoded":
+		data, err := parseNestedValues(string(raw))
+		if err != nil {
+			return nil, httperrors.NewBadRequest("Malformed form body")
+		}
+		return data, nil
+	}
+	return nil, httperrors.NewBadRequest(fmt.Sprintf("Unsupported Content-Type %s", mediaType))
+}
+
+func parseJSONBody(raw []byte) (interface{}, error) {
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, httperrors.NewBadRequest("Malformed JSON body: " + err.Error())
+	}
+	switch v := body.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		items := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			mapItem, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, httperrors.NewBadRequest("JSON array body may only contain objects")
+			}
+			items = append(items, mapItem)
+		}
+		return items, nil
+	}
+	return nil, httperrors.NewBadRequest("JSON body has to be an object or an array")
+}
+
 func (h *HttpProvider) respond(response http.ResponseWriter, data interface{}) {
 	dataEnc, err := json.Marshal(data)
 	if err != nil {
@@ -123,6 +295,7 @@ func (h *HttpProvider) respond(response http.ResponseWriter, data interface{}) {
 		return
 	}
 	// fmt.Printf("response: %#v", dataEnc)
+	response.Header().Set("Content-Type", "application/json")
 	response.WriteHeader(responseCode(data))
 	response.Write(dataEnc)
 }
@@ -137,15 +310,15 @@ func responseCode(data interface{}) int {
 
 // RequestVars parses a http request and extracts service related information
 func RequestVars(request http.Request) (requestRegistration, error) {
-	url, _ := url.Parse(request.RequestURI)
+	url, err := url.Parse(request.RequestURI)
+	if err != nil {
+		return requestRegistration{}, httperrors.NewBadRequest("Invalid request url")
+	}
 	var serviceName, id string
 	pathParts := strings.Split(url.Path, "/")
-	query := map[string]interface{}{}
-	for key, value := range url.Query() {
-		if len(value) == 0 {
-			continue
-		}
-		query[key] = value[0]
+	query, err := ParseQueryString(url.RawQuery)
+	if err != nil {
+		return requestRegistration{}, err
 	}
 	if len(pathParts) >= 2 {
 		serviceName = pathParts[1]
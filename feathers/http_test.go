@@ -0,0 +1,53 @@
+package feathers_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+var parseRequestBodyTests = []struct {
+	contentType string
+	body        string
+	expected    interface{}
+	errorCode   int
+}{
+	/* #1 */ {"application/json", `{"name":"test","age":3}`, map[string]interface{}{"name": "test", "age": float64(3)}, 0},
+	/* #2 */ {"application/json; charset=utf-8", `[{"name":"a"},{"name":"b"}]`, []map[string]interface{}{{"name": "a"}, {"name": "b"}}, 0},
+	/* #3 */ {"application/x-www-form-urlencoded", `name=test&tag=a&tag=b`, map[string]interface{}{"name": "test", "tag": []interface{}{"a", "b"}}, 0},
+	/* #4 */ {"", ``, map[string]interface{}{}, 0},
+	/* #5 */ {"", `{"name":"test"}`, map[string]interface{}{"name": "test"}, 0},
+	/* #6 */ {"application/json", `{"name":`, nil, 400},
+	/* #7 */ {"application/json", `[1,2]`, nil, 400},
+	/* #8 */ {"application/json", `"test"`, nil, 400},
+	/* #9 */ {"text/plain", `test`, nil, 400},
+	/* #10 */ {"application/json", `{"name":"` + strings.Repeat("a", 64) + `"}`, nil, 413},
+}
+
+func TestParseRequestBody(t *testing.T) {
+	for key, data := range parseRequestBodyTests {
+		request := httptest.NewRequest("POST", "/test", strings.NewReader(data.body))
+		if data.contentType != "" {
+			request.Header.Set("Content-Type", data.contentType)
+		}
+		result, err := feathers.ParseRequestBody(httptest.NewRecorder(), request, 64)
+		if data.errorCode != 0 {
+			featherErr, ok := err.(httperrors.FeathersError)
+			if !ok || featherErr.Code != data.errorCode {
+				t.Errorf("Failed #%d: wanted error code %d, got: %v", key+1, data.errorCode, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed #%d: unexpected error: %s", key+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, data.expected) {
+			t.Errorf("Failed #%d: wanted: %#v, got: %#v", key+1, data.expected, result)
+		}
+	}
+}
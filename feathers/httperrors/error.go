@@ -94,7 +94,7 @@ func NewTimeout(message string, data ...interface{}) FeathersError {
 	return FeathersError{
 		Name:      "Timeout",
 		Message:   message,
-		Code:      407,
+		Code:      408,
 		ClassName: "timeout",
 		Data:      retrieveData(data),
 	}
@@ -130,6 +130,16 @@ func NewLengthRequired(message string, data ...interface{}) FeathersError {
 	}
 }
 
+func NewPayloadTooLarge(message string, data ...interface{}) FeathersError {
+	return FeathersError{
+		Name:      "PayloadTooLarge",
+		Message:   message,
+		Code:      413,
+		ClassName: "payload-too-large",
+		Data:      retrieveData(data),
+	}
+}
+
 func NewUnprocessable(message string, data ...interface{}) FeathersError {
 	return FeathersError{
 		Name:      "Unprocessable",
@@ -190,6 +200,54 @@ func NewUnavailable(message string, data ...interface{}) FeathersError {
 	}
 }
 
+// Convert converts err to a FeathersError. FeathersErrors are returned unchanged, other errors become a GeneralError
 func Convert(err error) FeathersError {
+	if featherErr, ok := err.(FeathersError); ok {
+		return featherErr
+	}
 	return NewGeneralError(err.Error())
 }
+
+// FromCode returns the FeathersError of a http status code. Unknown client error codes become a BadRequest, other codes a GeneralError
+func FromCode(code int, message string, data ...interface{}) FeathersError {
+	switch code {
+	case 400:
+		return NewBadRequest(message, data...)
+	case 401:
+		return NewNotAuthenticated(message, data...)
+	case 402:
+		return NewPaymentError(message, data...)
+	case 403:
+		return NewForbidden(message, data...)
+	case 404:
+		return NewNotFound(message, data...)
+	case 405:
+		return NewMethodNotAllowed(message, data...)
+	case 406:
+		return NewNotAcceptable(message, data...)
+	case 408:
+		return NewTimeout(message, data...)
+	case 409:
+		return NewConflict(message, data...)
+	case 410:
+		return NewGone(message, data...)
+	case 411:
+		return NewLengthRequired(message, data...)
+	case 413:
+		return NewPayloadTooLarge(message, data...)
+	case 422:
+		return NewUnprocessable(message, data...)
+	case 429:
+		return NewTooManyRequests(message, data...)
+	case 501:
+		return NewNotImplemented(message, data...)
+	case 502:
+		return NewBadGateway(message, data...)
+	case 503:
+		return NewUnavailable(message, data...)
+	}
+	if code >= 400 && code < 500 {
+		return NewBadRequest(message, data...)
+	}
+	return NewGeneralError(message, data...)
+}
@@ -1,5 +1,10 @@
 package feathers
 
+import (
+	"context"
+	"time"
+)
+
 // Caller represents a caller of a request. It handles Callbacks
 type Caller interface {
 	Callback(data interface{})
@@ -8,6 +13,24 @@ type Caller interface {
 	SocketConnection() Connection
 }
 
+// ContextCaller is a caller which provides a context that is cancelled when the caller goes away
+/*
+e.g. a http request is cancelled or a socket disconnects
+*/
+type ContextCaller interface {
+	Context() context.Context
+}
+
+// HeaderCaller is a caller which provides the headers of the request (keys are lower case)
+type HeaderCaller interface {
+	Headers() map[string]string
+}
+
+// RemoteAddressCaller is a caller which provides the network address of the client (`host:port`)
+type RemoteAddressCaller interface {
+	RemoteAddress() string
+}
+
 type Connection interface {
 	Join(room string) error
 	Leave(room string) error
@@ -16,6 +39,25 @@ type Connection interface {
 	SetAuthEntity(interface{})
 }
 
+// AuthenticationConnection is a connection which stores the authentication information (strategy, accessToken) of its entity
+type AuthenticationConnection interface {
+	Authentication() map[string]interface{}
+	SetAuthentication(authentication map[string]interface{})
+}
+
+// ExpiringConnection is a connection whose authentication expires (e.g. with its access token)
+type ExpiringConnection interface {
+	// AuthExpiresAt returns when the authentication expires (zero if it does not expire)
+	AuthExpiresAt() time.Time
+	// SetAuthExpiry sets when the authentication expires. onExpire is called at expiresAt unless the expiry is replaced before
+	SetAuthExpiry(expiresAt time.Time, onExpire func())
+}
+
+// DisconnectableConnection is a connection which can be closed by the server
+type DisconnectableConnection interface {
+	Disconnect()
+}
+
 type WritableConnection interface {
 	Emit(event string, data interface{}) error
 }
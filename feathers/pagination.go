@@ -0,0 +1,88 @@
+package feathers
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// PaginateOptions configures pagination of find calls (similar to feathers `paginate` option)
+type PaginateOptions struct {
+	// Default is the limit used if no `$limit` is passed in the query
+	Default int64 `mapstructure:"default"`
+	// Max is the maximum limit a client can request (0 means no maximum)
+	Max int64 `mapstructure:"max"`
+}
+
+// Limit returns the limit to use for a find call based on the requested `$limit` (same as feathers `getLimit`).
+/*
+A negative or missing `$limit` falls back to Default (or Max if Default is not set) and the result is capped at Max.
+Returns nil if neither a limit was requested nor Default or Max are set
+*/
+func (p *PaginateOptions) Limit(requested *int64) *int64 {
+	if p.Default == 0 && p.Max == 0 {
+		return requested
+	}
+	limit := p.Default
+	if limit == 0 {
+		limit = p.Max
+	}
+	if requested != nil && *requested >= 0 {
+		limit = *requested
+	}
+	if p.Max > 0 && limit > p.Max {
+		limit = p.Max
+	}
+	return &limit
+}
+
+// Page is the result of a paginated find call
+type Page struct {
+	Total int64       `json:"total" mapstructure:"total"`
+	Limit int64       `json:"limit" mapstructure:"limit"`
+	Skip  int64       `json:"skip" mapstructure:"skip"`
+	Data  interface{} `json:"data" mapstructure:"data"`
+}
+
+// ParsePaginateOptions parses pagination options from configuration.
+/*
+Accepts `false` (pagination disabled), a `*PaginateOptions` or a map with `default` and `max` keys
+*/
+func ParsePaginateOptions(value interface{}) (*PaginateOptions, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if v {
+			return nil, fmt.Errorf("paginate has to be false or define default and max")
+		}
+		return nil, nil
+	case *PaginateOptions:
+		return v, nil
+	case PaginateOptions:
+		return &v, nil
+	case map[string]interface{}:
+		options := &PaginateOptions{}
+		if err := mapstructure.WeakDecode(v, options); err != nil {
+			return nil, err
+		}
+		return options, nil
+	}
+	return nil, fmt.Errorf("cannot parse paginate options of type %T", value)
+}
+
+// ResolvePaginate returns the pagination options for a call. It returns nil if pagination is disabled.
+/*
+Pagination can be overwritten per call by setting `paginate` in params (`params.Set("paginate", false)`
+disables it, a `*PaginateOptions` or a map with `default` and `max` replaces the service options)
+*/
+func ResolvePaginate(params Params, serviceOptions *PaginateOptions) *PaginateOptions {
+	if value, ok := params.Lookup("paginate"); ok {
+		options, err := ParsePaginateOptions(value)
+		if err != nil {
+			return serviceOptions
+		}
+		return options
+	}
+	return serviceOptions
+}
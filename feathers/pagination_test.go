@@ -0,0 +1,52 @@
+package feathers_test
+
+import (
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+)
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+var paginateLimitTests = []struct {
+	options   feathers.PaginateOptions
+	requested *int64
+	expected  *int64
+}{
+	/* #1 */ {feathers.PaginateOptions{Default: 10, Max: 50}, nil, int64Ptr(10)},
+	/* #2 */ {feathers.PaginateOptions{Default: 10, Max: 50}, int64Ptr(20), int64Ptr(20)},
+	/* #3 */ {feathers.PaginateOptions{Default: 10, Max: 50}, int64Ptr(100), int64Ptr(50)},
+	/* #4 */ {feathers.PaginateOptions{Default: 10, Max: 50}, int64Ptr(0), int64Ptr(0)},
+	/* #5 */ {feathers.PaginateOptions{Default: 10, Max: 50}, int64Ptr(-1), int64Ptr(10)},
+	/* #6 */ {feathers.PaginateOptions{}, nil, nil},
+	/* #7 */ {feathers.PaginateOptions{Max: 5}, nil, int64Ptr(5)},
+	/* #8 */ {feathers.PaginateOptions{Max: 5}, int64Ptr(-1), int64Ptr(5)},
+	/* #9 */ {feathers.PaginateOptions{Max: 5}, int64Ptr(3), int64Ptr(3)},
+}
+
+func TestPaginateLimit(t *testing.T) {
+	for key, data := range paginateLimitTests {
+		result := data.options.Limit(data.requested)
+		if (result == nil) != (data.expected == nil) || (result != nil && *result != *data.expected) {
+			t.Errorf("Failed #%d: wanted: %v, got: %v", key+1, data.expected, result)
+		}
+	}
+}
+
+func TestResolvePaginate(t *testing.T) {
+	serviceOptions := &feathers.PaginateOptions{Default: 10, Max: 50}
+	params := feathers.NewParams()
+	if feathers.ResolvePaginate(*params, serviceOptions) != serviceOptions {
+		t.Errorf("Should use service options by default")
+	}
+	params.Set("paginate", false)
+	if feathers.ResolvePaginate(*params, serviceOptions) != nil {
+		t.Errorf("Should disable pagination through params")
+	}
+	params.Set("paginate", map[string]interface{}{"default": 5})
+	if options := feathers.ResolvePaginate(*params, serviceOptions); options == nil || options.Default != 5 {
+		t.Errorf("Should replace pagination options through params")
+	}
+}
@@ -0,0 +1,453 @@
+// Package query parses the feathers query syntax into a typed representation which service adapters translate
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+// DefaultOperators are the operators allowed if a service does not define its own list
+var DefaultOperators = []string{"$eq", "$ne", "$in", "$nin", "$lt", "$lte", "$gt", "$gte", "$or", "$and", "$nor"}
+
+// groupOperators combine a list of queries
+var groupOperators = []string{"$or", "$and", "$nor"}
+
+// Eq is the operator of conditions which compare a field with a plain value (`{"name": "a"}`)
+const Eq = "$eq"
+
+// Query is a parsed feathers query (use `Parse`)
+type Query struct {
+	// Filter contains the conditions the entities have to match
+	Filter Filter
+	// Sort contains the fields of `$sort` in order
+	Sort []Sort
+	// Skip is the value of `$skip` (0 if not set)
+	Skip int64
+	// Limit is the value of `$limit` (nil if not set)
+	Limit *int64
+	// Select contains the fields of `$select` (nil if all fields are selected)
+	Select []string
+}
+
+// Filter is a list of nodes which all have to match. Nodes are sorted by field (groups by operator)
+type Filter []Node
+
+// Node is a `Condition` or a `Group`
+type Node interface {
+	node()
+}
+
+// Condition compares the value of Field with Value using Operator (e.g. `$lt`).
+/*
+Field may contain dots for nested fields. Operator is `Eq` for plain values. Value is a list ([]interface{}) for
+`$in` and `$nin`, a bool for `$exists` and a plain value (which contains no operators) otherwise
+*/
+type Condition struct {
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+func (Condition) node() {}
+
+// Group combines filters with Operator (`$or`, `$and` or `$nor`)
+type Group struct {
+	Operator string
+	Filters  []Filter
+}
+
+func (Group) node() {}
+
+// Sort is a field of `$sort`. Direction is 1 (ascending) or -1 (descending)
+type Sort struct {
+	Field     string
+	Direction int
+}
+
+// Parse parses a query (e.g. `Params.Query`). raw is not modified.
+/*
+operators is the list of allowed operators (`DefaultOperators` if nil). Plain values can always be compared.
+Keys starting with `$` which are neither options ($limit, $skip, $sort, $select) nor allowed operators
+result in a BadRequest error, as do values which contain operators where plain values are expected
+*/
+func Parse(raw map[string]interface{}, operators []string) (*Query, error) {
+	if operators == nil {
+		operators = DefaultOperators
+	}
+	query := &Query{}
+	filter := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		var err error
+		switch key {
+		case "$limit":
+			var limit int64
+			limit, err = toInt64(value)
+			if err != nil || limit < 0 {
+				return nil, httperrors.NewBadRequest("$limit has to be a positive number")
+			}
+			query.Limit = &limit
+		case "$skip":
+			query.Skip, err = toInt64(value)
+			if err != nil || query.Skip < 0 {
+				return nil, httperrors.NewBadRequest("$skip has to be a positive number")
+			}
+		case "$sort":
+			query.Sort, err = parseSort(value)
+		case "$select":
+			query.Select, err = parseSelect(value)
+		default:
+			filter[key] = value
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	query.Filter, err = parseFilter(filter, operators)
+	if err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// Operators returns all operators used in the filter (including the operators of groups)
+func (f Filter) Operators() []string {
+	operators := []string{}
+	for _, node := range f {
+		switch n := node.(type) {
+		case Condition:
+			operators = append(operators, n.Operator)
+		case Group:
+			operators = append(operators, n.Operator)
+			for _, filter := range n.Filters {
+				operators = append(operators, filter.Operators()...)
+			}
+		}
+	}
+	return operators
+}
+
+// Unsupported returns an error for the first operator of the filter which is not in supported (nil if all are)
+/*
+Adapters use it for operators which are allowed by the service but cannot be translated
+*/
+func (f Filter) Unsupported(supported []string) error {
+	for _, operator := range f.Operators() {
+		if operator != Eq && !contains(supported, operator) {
+			return httperrors.NewBadRequest(fmt.Sprintf("Query operator %s is not supported", operator))
+		}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func parseFilter(raw map[string]interface{}, operators []string) (Filter, error) {
+	filter := Filter{}
+	for _, key := range sortedKeys(raw) {
+		value := raw[key]
+		switch {
+		case contains(groupOperators, key) && contains(operators, key):
+			group, err := parseGroup(key, value, operators)
+			if err != nil {
+				return nil, err
+			}
+			filter = append(filter, group)
+		case strings.HasPrefix(key, "$"):
+			return nil, httperrors.NewBadRequest(fmt.Sprintf("Invalid query parameter %s", key))
+		default:
+			conditions, err := parseConditions(key, value, operators)
+			if err != nil {
+				return nil, err
+			}
+			filter = append(filter, conditions...)
+		}
+	}
+	return filter, nil
+}
+
+func parseGroup(operator string, value interface{}, operators []string) (Group, error) {
+	queries := toSlice(value)
+	if queries == nil {
+		return Group{}, httperrors.NewBadRequest(fmt.Sprintf("%s has to be a list of queries", operator))
+	}
+	group := Group{Operator: operator, Filters: make([]Filter, 0, len(queries))}
+	for _, query := range queries {
+		queryMap, ok := query.(map[string]interface{})
+		if !ok {
+			return Group{}, httperrors.NewBadRequest(fmt.Sprintf("%s has to be a list of queries", operator))
+		}
+		filter, err := parseFilter(queryMap, operators)
+		if err != nil {
+			return Group{}, err
+		}
+		group.Filters = append(group.Filters, filter)
+	}
+	return group, nil
+}
+
+// parseConditions parses the value of field (a plain value or a map of operators)
+func parseConditions(field string, value interface{}, operators []string) ([]Node, error) {
+	if err := validateField(field); err != nil {
+		return nil, err
+	}
+	operatorMap, ok := value.(map[string]interface{})
+	if !ok || !hasOperator(operatorMap) {
+		if err := validateValue(field, value); err != nil {
+			return nil, err
+		}
+		return []Node{Condition{Field: field, Operator: Eq, Value: value}}, nil
+	}
+
+	conditions := make([]Node, 0, len(operatorMap))
+	for _, operator := range sortedKeys(operatorMap) {
+		if !strings.HasPrefix(operator, "$") {
+			return nil, httperrors.NewBadRequest(fmt.Sprintf("Query of '%s' mixes operators and fields", field))
+		}
+		if contains(groupOperators, operator) || !contains(operators, operator) {
+			return nil, httperrors.NewBadRequest(fmt.Sprintf("Invalid query operator %s of '%s'", operator, field))
+		}
+		operand := operatorMap[operator]
+		switch operator {
+		case "$in", "$nin":
+			list := toSlice(operand)
+			if list == nil {
+				return nil, httperrors.NewBadRequest(fmt.Sprintf("%s of '%s' has to be a list", operator, field))
+			}
+			if err := validateValue(field, list); err != nil {
+				return nil, err
+			}
+			operand = list
+		case "$exists":
+			exists, err := toBool(operand)
+			if err != nil {
+				return nil, httperrors.NewBadRequest(fmt.Sprintf("$exists of '%s' has to be a boolean", field))
+			}
+			operand = exists
+		default:
+			if err := validateValue(field, operand); err != nil {
+				return nil, err
+			}
+		}
+		conditions = append(conditions, Condition{Field: field, Operator: operator, Value: operand})
+	}
+	return conditions, nil
+}
+
+// validateField checks that no part of a (dot separated) field name is an operator
+func validateField(field string) error {
+	for _, part := range strings.Split(field, ".") {
+		if part == "" || strings.HasPrefix(part, "$") {
+			return httperrors.NewBadRequest(fmt.Sprintf("Invalid query field '%s'", field))
+		}
+	}
+	return nil
+}
+
+func hasOperator(value map[string]interface{}) bool {
+	for key := range value {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateValue checks that a plain value does not contain operators (at any depth)
+func validateValue(field string, value interface{}) error {
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		for key, item := range valueMap {
+			if strings.HasPrefix(key, "$") {
+				return httperrors.NewBadRequest(fmt.Sprintf("Invalid value of '%s'", field))
+			}
+			if err := validateValue(field, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, ok := value.([]byte); ok {
+		return nil
+	}
+	for _, item := range toSlice(value) {
+		if err := validateValue(field, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toSlice returns the elements of a slice value (nil if value is not a slice)
+func toSlice(value interface{}) []interface{} {
+	if values, ok := value.([]interface{}); ok {
+		return values
+	}
+	reflected := reflect.ValueOf(value)
+	if !reflected.IsValid() || reflected.Kind() != reflect.Slice {
+		return nil
+	}
+	result := make([]interface{}, reflected.Len())
+	for i := range result {
+		result[i] = reflected.Index(i).Interface()
+	}
+	return result
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float32:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to number", value)
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("cannot convert %T to bool", value)
+}
+
+func sortDirection(field string, value interface{}) (int, error) {
+	direction, err := toInt64(value)
+	if err != nil || (direction != 1 && direction != -1) {
+		return 0, httperrors.NewBadRequest(fmt.Sprintf("$sort direction of '%s' has to be 1 or -1", field))
+	}
+	return int(direction), nil
+}
+
+// parseSort parses `$sort`.
+/*
+Because go maps are unordered, fields of a map are sorted by name. If the order of the fields matters the sort
+can be passed as a list of single key maps (`[{"name": 1}, {"age": -1}]`) or as an ordered document like `bson.D`
+(a slice of structs with the fields Key and Value)
+*/
+func parseSort(value interface{}) ([]Sort, error) {
+	fields := []Sort{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range sortedKeys(v) {
+			direction, err := sortDirection(name, v[name])
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, Sort{Field: name, Direction: direction})
+		}
+	case []interface{}:
+		for _, item := range v {
+			itemFields, err := parseSort(item)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, itemFields...)
+		}
+	default:
+		elements, ok := orderedDocument(value)
+		if !ok {
+			return nil, httperrors.NewBadRequest("$sort has to be an object")
+		}
+		for _, element := range elements {
+			direction, err := sortDirection(element.field, element.value)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, Sort{Field: element.field, Direction: direction})
+		}
+	}
+	for _, field := range fields {
+		if err := validateField(field.Field); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+type documentElement struct {
+	field string
+	value interface{}
+}
+
+// orderedDocument returns the elements of a slice of structs with the fields Key (string) and Value
+func orderedDocument(value interface{}) ([]documentElement, bool) {
+	reflected := reflect.ValueOf(value)
+	if !reflected.IsValid() || reflected.Kind() != reflect.Slice || reflected.Type().Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	elementType := reflected.Type().Elem()
+	keyField, hasKey := elementType.FieldByName("Key")
+	_, hasValue := elementType.FieldByName("Value")
+	if !hasKey || !hasValue || keyField.Type.Kind() != reflect.String {
+		return nil, false
+	}
+	elements := make([]documentElement, reflected.Len())
+	for i := range elements {
+		element := reflected.Index(i)
+		elements[i] = documentElement{
+			field: element.FieldByName("Key").String(),
+			value: element.FieldByName("Value").Interface(),
+		}
+	}
+	return elements, true
+}
+
+// parseSelect parses `$select` into a list of field names
+func parseSelect(value interface{}) ([]string, error) {
+	var fields []string
+	switch v := value.(type) {
+	case string:
+		fields = []string{v}
+	case []string:
+		fields = v
+	case []interface{}:
+		fields = make([]string, 0, len(v))
+		for _, field := range v {
+			fieldName, ok := field.(string)
+			if !ok {
+				return nil, httperrors.NewBadRequest("$select has to be a list of field names")
+			}
+			fields = append(fields, fieldName)
+		}
+	default:
+		return nil, httperrors.NewBadRequest("$select has to be a list of field names")
+	}
+	for _, field := range fields {
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,131 @@
+package query_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+)
+
+type element struct {
+	Key   string
+	Value interface{}
+}
+
+func TestParse(t *testing.T) {
+	limit := int64(2)
+	for key, test := range []struct {
+		raw      map[string]interface{}
+		expected *query.Query
+	}{
+		/* #1 */ {map[string]interface{}{}, &query.Query{Filter: query.Filter{}}},
+		/* #2 */ {
+			map[string]interface{}{"name": "a", "age": map[string]interface{}{"$lt": "30", "$gte": 18}},
+			&query.Query{Filter: query.Filter{
+				query.Condition{Field: "age", Operator: "$gte", Value: 18},
+				query.Condition{Field: "age", Operator: "$lt", Value: "30"},
+				query.Condition{Field: "name", Operator: query.Eq, Value: "a"},
+			}},
+		},
+		/* #3 */ {
+			map[string]interface{}{"id": map[string]interface{}{"$in": []string{"a", "b"}}, "address": map[string]interface{}{"city": "Berlin"}},
+			&query.Query{Filter: query.Filter{
+				query.Condition{Field: "address", Operator: query.Eq, Value: map[string]interface{}{"city": "Berlin"}},
+				query.Condition{Field: "id", Operator: "$in", Value: []interface{}{"a", "b"}},
+			}},
+		},
+		/* #4 */ {
+			map[string]interface{}{"$or": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"$and": []interface{}{}}}},
+			&query.Query{Filter: query.Filter{
+				query.Group{Operator: "$or", Filters: []query.Filter{
+					{query.Condition{Field: "name", Operator: query.Eq, Value: "a"}},
+					{query.Group{Operator: "$and", Filters: []query.Filter{}}},
+				}},
+			}},
+		},
+		/* #5 */ {
+			map[string]interface{}{"$limit": "2", "$skip": 4, "$select": []interface{}{"name"}, "$sort": []interface{}{map[string]interface{}{"name": 1}, map[string]interface{}{"age": "-1"}}},
+			&query.Query{Filter: query.Filter{}, Limit: &limit, Skip: 4, Select: []string{"name"}, Sort: []query.Sort{{Field: "name", Direction: 1}, {Field: "age", Direction: -1}}},
+		},
+		/* #6 */ {
+			map[string]interface{}{"$sort": []element{{"name", -1}, {"age", 1}}},
+			&query.Query{Filter: query.Filter{}, Sort: []query.Sort{{Field: "name", Direction: -1}, {Field: "age", Direction: 1}}},
+		},
+	} {
+		parsed, err := query.Parse(test.raw, nil)
+		if err != nil {
+			t.Errorf("Failed #%d: unexpected error: %s", key+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(parsed, test.expected) {
+			t.Errorf("Failed #%d: wanted: %#v, got: %#v", key+1, test.expected, parsed)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for key, raw := range []map[string]interface{}{
+		/* #1 */ {"$where": "sleep(1000)"},
+		/* #2 */ {"name": map[string]interface{}{"$regex": ".*"}},
+		/* #3 */ {"name": map[string]interface{}{"$or": []interface{}{}}},
+		/* #4 */ {"$or": map[string]interface{}{"name": "a"}},
+		/* #5 */ {"$or": []interface{}{"name"}},
+		/* #6 */ {"$and": []interface{}{map[string]interface{}{"$expr": true}}},
+		/* #7 */ {"name": map[string]interface{}{"$ne": "a", "first": "b"}},
+		/* #8 */ {"name": map[string]interface{}{"$in": "a"}},
+		/* #9 */ {"name": map[string]interface{}{"$in": []interface{}{map[string]interface{}{"$gt": ""}}}},
+		/* #10 */ {"address": map[string]interface{}{"city": map[string]interface{}{"$ne": ""}}},
+		/* #11 */ {"name": map[string]interface{}{"$eq": map[string]interface{}{"$where": "true"}}},
+		/* #12 */ {"address.$where": "a"},
+		/* #13 */ {"$limit": -1},
+		/* #14 */ {"$skip": "a"},
+		/* #15 */ {"$sort": map[string]interface{}{"age": 2}},
+		/* #16 */ {"$select": []interface{}{1}},
+		/* #17 */ {"$select": []interface{}{"$where"}},
+	} {
+		_, err := query.Parse(raw, nil)
+		if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 400 {
+			t.Errorf("Failed #%d: expected BadRequest, got: %v", key+1, err)
+		}
+	}
+}
+
+func TestOperatorWhitelist(t *testing.T) {
+	raw := map[string]interface{}{
+		"$nor": []interface{}{map[string]interface{}{"name": map[string]interface{}{"$exists": "true"}}},
+	}
+	if _, err := query.Parse(raw, nil); err == nil {
+		t.Errorf("$exists should not be allowed by default")
+	}
+	if _, err := query.Parse(raw, []string{"$exists"}); err == nil {
+		t.Errorf("$nor should only be allowed if it is in the list")
+	}
+	parsed, err := query.Parse(raw, append(query.DefaultOperators, "$exists"))
+	expected := query.Filter{query.Group{Operator: "$nor", Filters: []query.Filter{
+		{query.Condition{Field: "name", Operator: "$exists", Value: true}},
+	}}}
+	if err != nil || !reflect.DeepEqual(parsed.Filter, expected) {
+		t.Fatalf("Parse failed: %#v %v", parsed, err)
+	}
+	if operators := parsed.Filter.Operators(); !reflect.DeepEqual(operators, []string{"$nor", "$exists"}) {
+		t.Errorf("Unexpected operators: %v", operators)
+	}
+	if err := parsed.Filter.Unsupported([]string{"$nor"}); err == nil {
+		t.Errorf("$exists should be unsupported")
+	}
+	if err := parsed.Filter.Unsupported([]string{"$nor", "$exists"}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestParseDoesNotModifyQuery(t *testing.T) {
+	raw := map[string]interface{}{"name": "a", "$limit": 1, "$or": []interface{}{map[string]interface{}{"age": 1}}}
+	expected := map[string]interface{}{"name": "a", "$limit": 1, "$or": []interface{}{map[string]interface{}{"age": 1}}}
+	if _, err := query.Parse(raw, nil); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(raw, expected) {
+		t.Errorf("Query was modified: %#v", raw)
+	}
+}
@@ -0,0 +1,389 @@
+package feathers
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+const (
+	// qsDepth is the maximum nesting depth of bracket keys (same as qs)
+	qsDepth = 5
+	// qsArrayLimit is the highest index which is parsed as array index (same as qs)
+	qsArrayLimit = 20
+	// qsParameterLimit is the maximum number of parameters which are parsed (same as qs)
+	qsParameterLimit = 1000
+)
+
+// qsList is a array which is being built while parsing
+type qsList []interface{}
+
+// ParseQueryString parses a url query string the same way feathers (using qs) does.
+/*
+Bracket keys are parsed into nested maps and arrays:
+	`age[$gt]=18` -> {"age": {"$gt": "18"}}
+	`$sort[name]=1` -> {"$sort": {"name": 1}}
+	`$sort[name]=1&$sort[age]=-1` -> {"$sort": [{"name": 1}, {"age": -1}]}
+	`id[$in][]=a&id[$in][]=b` -> {"id": {"$in": ["a", "b"]}}
+Values of `$limit`, `$skip` and `$sort` are converted to numbers and `true`/`false` of `$exists` to booleans (other
+values stay strings, as for a field they can not be told apart from text). A `$sort` with multiple fields is returned as list of
+single key maps, so the order of the fields in the query string is kept. Empty values of list operators (`id[$in]=`) are
+parsed as empty lists (see StringifyQuery).
+The result has the same shape as a query sent through socket.io
+*/
+func ParseQueryString(raw string) (map[string]interface{}, error) {
+	query, err := parseNestedValues(raw)
+	if err != nil {
+		return nil, err
+	}
+	coerceQuery(query)
+	orderSort(query, qsSortFields(raw))
+	return query, nil
+}
+
+// qsSortFields returns the fields of `$sort` in the order of the query string
+func qsSortFields(raw string) []string {
+	fields := []string{}
+	seen := map[string]bool{}
+	for _, pair := range strings.Split(raw, "&") {
+		rawKey := pair
+		if idx := strings.Index(pair, "="); idx >= 0 {
+			rawKey = pair[:idx]
+		}
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			continue
+		}
+		segments := qsSegments(key)
+		if len(segments) > 1 && segments[0] == "$sort" && !seen[segments[1]] {
+			seen[segments[1]] = true
+			fields = append(fields, segments[1])
+		}
+	}
+	return fields
+}
+
+// orderSort converts a `$sort` map with multiple fields into a list of single key maps in the order of fields
+func orderSort(query map[string]interface{}, fields []string) {
+	sortQuery, ok := query["$sort"].(map[string]interface{})
+	if !ok || len(sortQuery) < 2 {
+		return
+	}
+	ordered := make([]interface{}, 0, len(sortQuery))
+	for _, field := range fields {
+		if direction, ok := sortQuery[field]; ok {
+			ordered = append(ordered, map[string]interface{}{field: direction})
+		}
+	}
+	if len(ordered) != len(sortQuery) {
+		// the fields can not be matched with the query string (e.g. list indices), keep the map
+		return
+	}
+	query["$sort"] = ordered
+}
+
+// parseNestedValues parses a urlencoded string with bracket nesting but without any type coercion
+func parseNestedValues(raw string) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	pairs := strings.Split(raw, "&")
+	if len(pairs) > qsParameterLimit {
+		pairs = pairs[:qsParameterLimit]
+	}
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue := pair, ""
+		if idx := strings.Index(pair, "="); idx >= 0 {
+			rawKey, rawValue = pair[:idx], pair[idx+1:]
+		}
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			return nil, httperrors.NewBadRequest(fmt.Sprintf("Invalid query key '%s'", rawKey))
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, httperrors.NewBadRequest(fmt.Sprintf("Invalid query value for '%s'", key))
+		}
+		segments := qsSegments(key)
+		if len(segments) == 0 || segments[0] == "" {
+			continue
+		}
+		root[segments[0]] = qsSet(root[segments[0]], segments[1:], value)
+	}
+	for key, value := range root {
+		root[key] = qsCompact(value)
+	}
+	return root, nil
+}
+
+// qsSegments splits a key like `a[b][]` into its segments ("a", "b" and "")
+func qsSegments(key string) []string {
+	open := strings.Index(key, "[")
+	if open < 0 {
+		return []string{key}
+	}
+	segments := []string{}
+	if open > 0 {
+		segments = append(segments, key[:open])
+	}
+	rest := key[open:]
+	depth := 0
+	for len(rest) > 0 && depth < qsDepth {
+		if rest[0] != '[' {
+			break
+		}
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			break
+		}
+		segment := rest[1:end]
+		if strings.Contains(segment, "[") {
+			break
+		}
+		segments = append(segments, segment)
+		rest = rest[end+1:]
+		depth++
+	}
+	if len(rest) > 0 {
+		segments = append(segments, rest)
+	}
+	if open == 0 && len(segments) > 0 && segments[0] == "" {
+		segments = segments[1:]
+	}
+	return segments
+}
+
+// qsSet sets value at segments inside of node and returns the updated node
+func qsSet(node interface{}, segments []string, value string) interface{} {
+	if len(segments) == 0 {
+		switch n := node.(type) {
+		case nil:
+			return value
+		case string:
+			return qsList{n, value}
+		case qsList:
+			return append(n, value)
+		case map[string]interface{}:
+			n[strconv.Itoa(len(n))] = value
+			return n
+		}
+		return value
+	}
+
+	segment, rest := segments[0], segments[1:]
+	if segment == "" {
+		switch n := node.(type) {
+		case nil:
+			return qsList{qsSet(nil, rest, value)}
+		case string:
+			return qsList{n, qsSet(nil, rest, value)}
+		case qsList:
+			return append(n, qsSet(nil, rest, value))
+		case map[string]interface{}:
+			n[strconv.Itoa(len(n))] = qsSet(nil, rest, value)
+			return n
+		}
+	}
+
+	var target map[string]interface{}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		target = n
+	case qsList:
+		target = make(map[string]interface{}, len(n))
+		for i, item := range n {
+			target[strconv.Itoa(i)] = item
+		}
+	case string:
+		target = map[string]interface{}{"0": n}
+	default:
+		target = map[string]interface{}{}
+	}
+	target[segment] = qsSet(target[segment], rest, value)
+	return target
+}
+
+// qsCompact converts temporary lists and maps with index keys into arrays
+func qsCompact(node interface{}) interface{} {
+	switch n := node.(type) {
+	case qsList:
+		list := make([]interface{}, len(n))
+		for i, item := range n {
+			list[i] = qsCompact(item)
+		}
+		return list
+	case map[string]interface{}:
+		indices := make([]int, 0, len(n))
+		for key, value := range n {
+			n[key] = qsCompact(value)
+			if index, err := strconv.Atoi(key); err == nil && index >= 0 && index <= qsArrayLimit && strconv.Itoa(index) == key {
+				indices = append(indices, index)
+			}
+		}
+		if len(indices) == 0 || len(indices) != len(n) {
+			return n
+		}
+		sort.Ints(indices)
+		list := make([]interface{}, len(indices))
+		for i, index := range indices {
+			list[i] = n[strconv.Itoa(index)]
+		}
+		return list
+	}
+	return node
+}
+
+// coerceQuery converts values of reserved query keys into the types expected by services
+func coerceQuery(query map[string]interface{}) {
+	for _, key := range []string{"$limit", "$skip"} {
+		if value, ok := query[key].(string); ok {
+			if number, err := strconv.Atoi(value); err == nil {
+				query[key] = number
+			}
+		}
+	}
+	switch sortQuery := query["$sort"].(type) {
+	case map[string]interface{}:
+		coerceSort(sortQuery)
+	case []interface{}:
+		for _, entry := range sortQuery {
+			if fields, ok := entry.(map[string]interface{}); ok {
+				coerceSort(fields)
+			}
+		}
+	}
+	coerceOperators(query)
+}
+
+// coerceSort converts the directions of sortQuery into numbers
+func coerceSort(sortQuery map[string]interface{}) {
+	for field, value := range sortQuery {
+		if direction, ok := value.(string); ok {
+			if number, err := strconv.Atoi(direction); err == nil {
+				sortQuery[field] = number
+			}
+		}
+	}
+}
+
+// coerceOperators converts empty values of list operators into empty lists and values of `$exists` into booleans
+func coerceOperators(node interface{}) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			switch key {
+			case "$in", "$nin", "$or", "$and", "$nor":
+				if value == "" {
+					n[key] = []interface{}{}
+					continue
+				}
+			case "$exists":
+				if value == "true" || value == "false" {
+					n[key] = value == "true"
+					continue
+				}
+			}
+			coerceOperators(value)
+		}
+	case []interface{}:
+		for _, item := range n {
+			coerceOperators(item)
+		}
+	}
+}
+
+type hexable interface {
+	Hex() string
+}
+
+// StringifyQuery serializes a query into a url query string which ParseQueryString (and qs) parse into the same shape.
+/*
+Nested maps are encoded with bracket keys, lists of values with empty brackets and lists of maps with indices:
+	{"age": {"$gt": 18}} -> `age[$gt]=18`
+	{"id": {"$in": ["a", "b"]}} -> `id[$in][]=a&id[$in][]=b`
+	{"$or": [{"name": "a"}]} -> `$or[0][name]=a`
+	{"id": {"$in": []}} -> `id[$in]=`
+Empty lists are encoded with an empty value, so they are not dropped (`{"$in": []}` matches nothing, without it a query
+would match all entities). Keys are sorted. All values are sent as strings (like the feathers REST client does)
+*/
+func StringifyQuery(query map[string]interface{}) string {
+	pairs := []string{}
+	qsStringify(&pairs, "", query)
+	return strings.Join(pairs, "&")
+}
+
+func qsStringify(pairs *[]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prefix != "" {
+				qsStringify(pairs, prefix+"["+key+"]", v[key])
+			} else {
+				qsStringify(pairs, key, v[key])
+			}
+		}
+		return
+	case []map[string]interface{}:
+		if len(v) == 0 {
+			*pairs = append(*pairs, url.QueryEscape(prefix)+"=")
+			return
+		}
+		for i, item := range v {
+			qsStringify(pairs, prefix+"["+strconv.Itoa(i)+"]", item)
+		}
+		return
+	}
+	reflected := reflect.ValueOf(value)
+	if reflected.IsValid() && (reflected.Kind() == reflect.Slice || reflected.Kind() == reflect.Array) && reflected.Type().Elem().Kind() != reflect.Uint8 {
+		if reflected.Len() == 0 {
+			*pairs = append(*pairs, url.QueryEscape(prefix)+"=")
+			return
+		}
+		for i := 0; i < reflected.Len(); i++ {
+			item := reflected.Index(i).Interface()
+			switch item.(type) {
+			case map[string]interface{}, []interface{}, []map[string]interface{}:
+				qsStringify(pairs, prefix+"["+strconv.Itoa(i)+"]", item)
+			default:
+				qsStringify(pairs, prefix+"[]", item)
+			}
+		}
+		return
+	}
+	*pairs = append(*pairs, url.QueryEscape(prefix)+"="+url.QueryEscape(qsValue(value)))
+}
+
+// qsValue converts a scalar query value to its string representation
+func qsValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case hexable:
+		return v.Hex()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,121 @@
+package feathers_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+)
+
+var parseQueryStringTests = []struct {
+	raw      string
+	expected map[string]interface{}
+}{
+	/* #1 */ {"name=test", map[string]interface{}{"name": "test"}},
+	/* #2 */ {"age[$gt]=18", map[string]interface{}{"age": map[string]interface{}{"$gt": "18"}}},
+	/* #3 */ {"$sort[name]=1&$sort[age]=-1", map[string]interface{}{"$sort": []interface{}{map[string]interface{}{"name": 1}, map[string]interface{}{"age": -1}}}},
+	/* #4 */ {"id[$in][]=a&id[$in][]=b", map[string]interface{}{"id": map[string]interface{}{"$in": []interface{}{"a", "b"}}}},
+	/* #5 */ {"id%5B%24in%5D%5B%5D=a&id%5B%24in%5D%5B%5D=b", map[string]interface{}{"id": map[string]interface{}{"$in": []interface{}{"a", "b"}}}},
+	/* #6 */ {"$limit=10&$skip=20", map[string]interface{}{"$limit": 10, "$skip": 20}},
+	/* #7 */ {"$limit=abc", map[string]interface{}{"$limit": "abc"}},
+	/* #8 */ {"tag=a&tag=b", map[string]interface{}{"tag": []interface{}{"a", "b"}}},
+	/* #9 */ {"tag[1]=b&tag[0]=a", map[string]interface{}{"tag": []interface{}{"a", "b"}}},
+	/* #10 */ {"tag[100]=a", map[string]interface{}{"tag": map[string]interface{}{"100": "a"}}},
+	/* #11 */ {"$or[0][name]=a&$or[1][age][$lt]=5", map[string]interface{}{"$or": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"age": map[string]interface{}{"$lt": "5"}}}}},
+	/* #12 */ {"a[b][c][d][e][f][g]=h", map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{"d": map[string]interface{}{"e": map[string]interface{}{"f": map[string]interface{}{"[g]": "h"}}}}}}}},
+	/* #13 */ {"name=hello+world&empty=", map[string]interface{}{"name": "hello world", "empty": ""}},
+	/* #14 */ {"", map[string]interface{}{}},
+	/* #15 */ {"id[$in]=&$or=", map[string]interface{}{"id": map[string]interface{}{"$in": []interface{}{}}, "$or": []interface{}{}}},
+	/* #16 */ {"$or[0][id][$nin]=", map[string]interface{}{"$or": []interface{}{map[string]interface{}{"id": map[string]interface{}{"$nin": []interface{}{}}}}}},
+	/* #17 */ {"$sort[b]=1&$sort[c]=-1&$sort[a]=1", map[string]interface{}{"$sort": []interface{}{map[string]interface{}{"b": 1}, map[string]interface{}{"c": -1}, map[string]interface{}{"a": 1}}}},
+	/* #18 */ {"$sort[name]=1", map[string]interface{}{"$sort": map[string]interface{}{"name": 1}}},
+	/* #19 */ {"$sort[0][name]=1&$sort[1][age]=-1", map[string]interface{}{"$sort": []interface{}{map[string]interface{}{"name": 1}, map[string]interface{}{"age": -1}}}},
+	/* #20 */ {"done[$exists]=true&deleted[$exists]=false", map[string]interface{}{"done": map[string]interface{}{"$exists": true}, "deleted": map[string]interface{}{"$exists": false}}},
+	/* #21 */ {"$or[0][done][$exists]=false&done=true", map[string]interface{}{"$or": []interface{}{map[string]interface{}{"done": map[string]interface{}{"$exists": false}}}, "done": "true"}},
+	/* #22 */ {"done[$exists]=yes", map[string]interface{}{"done": map[string]interface{}{"$exists": "yes"}}},
+}
+
+func TestParseQueryString(t *testing.T) {
+	for key, data := range parseQueryStringTests {
+		result, err := feathers.ParseQueryString(data.raw)
+		if err != nil {
+			t.Errorf("Failed #%d: unexpected error: %s", key+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, data.expected) {
+			t.Errorf("Failed #%d: wanted: %#v, got: %#v", key+1, data.expected, result)
+		}
+	}
+}
+
+func TestParseQueryStringSortOrder(t *testing.T) {
+	raw, err := feathers.ParseQueryString("$sort[name]=1&$sort[age]=-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	parsed, err := query.Parse(raw, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []query.Sort{{Field: "name", Direction: 1}, {Field: "age", Direction: -1}}
+	if !reflect.DeepEqual(parsed.Sort, expected) {
+		t.Errorf("Sort order was not kept: wanted: %v, got: %v", expected, parsed.Sort)
+	}
+}
+
+func TestParseQueryStringInvalid(t *testing.T) {
+	_, err := feathers.ParseQueryString("name=%zz")
+	if err == nil {
+		t.Errorf("Expected error for invalid escape sequence")
+	}
+}
+
+func TestStringifyQuery(t *testing.T) {
+	for key, data := range []struct {
+		query    map[string]interface{}
+		expected string
+	}{
+		/* #1 */ {map[string]interface{}{"name": "test"}, "name=test"},
+		/* #2 */ {map[string]interface{}{"age": map[string]interface{}{"$gt": 18}}, "age%5B%24gt%5D=18"},
+		/* #3 */ {map[string]interface{}{"id": map[string]interface{}{"$in": []string{"a", "b"}}}, "id%5B%24in%5D%5B%5D=a&id%5B%24in%5D%5B%5D=b"},
+		/* #4 */ {map[string]interface{}{"$limit": 10, "$skip": 0, "done": false}, "%24limit=10&%24skip=0&done=false"},
+		/* #5 */ {map[string]interface{}{}, ""},
+		/* #6 */ {map[string]interface{}{"id": map[string]interface{}{"$in": []interface{}{}}}, "id%5B%24in%5D="},
+	} {
+		if result := feathers.StringifyQuery(data.query); result != data.expected {
+			t.Errorf("Failed #%d: wanted: %s, got: %s", key+1, data.expected, result)
+		}
+	}
+
+	query := map[string]interface{}{
+		"$sort":  []interface{}{map[string]interface{}{"name": 1}, map[string]interface{}{"age": -1}},
+		"$limit": 10,
+		"id":     map[string]interface{}{"$in": []interface{}{"a", "b"}},
+		"$or": []interface{}{
+			map[string]interface{}{"name": "a b&c"},
+			map[string]interface{}{"age": map[string]interface{}{"$lt": "5"}},
+		},
+		"empty": nil,
+		"_id":   map[string]interface{}{"$in": []interface{}{}},
+		"$and":  []map[string]interface{}{},
+		"done":  map[string]interface{}{"$exists": true},
+	}
+	expected := map[string]interface{}{
+		"$sort":  []interface{}{map[string]interface{}{"name": 1}, map[string]interface{}{"age": -1}},
+		"$limit": 10,
+		"id":     map[string]interface{}{"$in": []interface{}{"a", "b"}},
+		"$or": []interface{}{
+			map[string]interface{}{"name": "a b&c"},
+			map[string]interface{}{"age": map[string]interface{}{"$lt": "5"}},
+		},
+		"empty": "",
+		"_id":   map[string]interface{}{"$in": []interface{}{}},
+		"$and":  []interface{}{},
+		"done":  map[string]interface{}{"$exists": true},
+	}
+	result, err := feathers.ParseQueryString(feathers.StringifyQuery(query))
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Round trip failed: wanted: %#v, got: %#v (%v)", expected, result, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator"
 	"github.com/mcuadros/go-defaults"
@@ -59,9 +60,14 @@ type HooksTreeBranch struct {
 	Update []Hook
 	// Remove hooks are executed for remove hooks
 	Remove []Hook
+	// Custom hooks are executed for custom methods (key is the method name)
+	Custom map[string][]Hook
 }
 
 func (b HooksTreeBranch) Branch(method RestMethod) []Hook {
+	if isCustomMethod(method) {
+		return mergeHooks(b.All, b.Custom[method.String()])
+	}
 	key := strings.Title(method.String())
 	if chain, ok := getField(&b, key); ok {
 		hc := chain.([]Hook)
@@ -71,8 +77,15 @@ func (b HooksTreeBranch) Branch(method RestMethod) []Hook {
 }
 
 func (b *HooksTreeBranch) Append(method RestMethod, hooks []Hook) {
+	if isCustomMethod(method) {
+		if b.Custom == nil {
+			b.Custom = map[string][]Hook{}
+		}
+		b.Custom[method.String()] = mergeHooks(b.Custom[method.String()], hooks)
+		return
+	}
 	key := strings.Title(method.String())
-	if chain, ok := getField(&b, key); ok {
+	if chain, ok := getField(b, key); ok {
 		hc := chain.([]Hook)
 		merged := mergeHooks(hc, hooks)
 		setField(b, key, merged)
@@ -102,14 +115,49 @@ func (t HooksTree) Branch(branchType HookType) HooksTreeBranch {
 
 func (t *HooksTree) Append(branchType HookType, method RestMethod, hooks []Hook) {
 	key := strings.Title(branchType.String())
-	if branch, ok := getField(&t, key); ok {
+	if branch, ok := getField(t, key); ok {
 		hc := branch.(HooksTreeBranch)
 		hc.Append(method, hooks)
+		setField(t, key, hc)
 	} else {
 		panic(fmt.Sprintf("Could not find branch %s", key))
 	}
 }
 
+// CustomMethod is a custom service method (e.g. `approve`) which runs through the hook pipeline like the standard methods
+type CustomMethod = func(ctx context.Context, data map[string]interface{}, params Params) (interface{}, error)
+
+// CustomMethodService is implemented by services which offer custom methods (see `BaseService.RegisterMethod`)
+type CustomMethodService interface {
+	// CustomMethod returns the custom method registered with name
+	CustomMethod(name string) (CustomMethod, bool)
+	// CustomMethodNames returns the names of all custom methods
+	CustomMethodNames() []string
+}
+
+// CallableService is a service on which custom methods can be called (services returned by `App.Service` implement it)
+type CallableService interface {
+	Call(ctx context.Context, method string, data map[string]interface{}, params Params) (interface{}, error)
+}
+
+// TimeoutService is implemented by services which overwrite the request timeout (see `BaseService.Timeouts`)
+type TimeoutService interface {
+	Timeout(method RestMethod) (time.Duration, bool)
+}
+
+// MultiService is implemented by services which allow calls affecting multiple entities (see `BaseService.Multi`)
+type MultiService interface {
+	AllowsMulti(method RestMethod) bool
+}
+
+// MultiCreateService is a service which can create multiple entities at once.
+/*
+Services which allow multi create but do not implement this interface get `Create` called for each entity
+*/
+type MultiCreateService interface {
+	CreateMany(ctx context.Context, data []map[string]interface{}, params Params) (interface{}, error)
+}
+
 type Defaulter interface {
 	SetDefaults()
 }
@@ -117,7 +165,63 @@ type Defaulter interface {
 // BaseService (every service should extend from this)
 type BaseService struct {
 	Hooks HooksTree
-	name  string
+	// Multi lists the methods (Create, Patch, Remove) which may affect multiple entities (`All` allows all of them).
+	// Multi create passes a list of entities, multi patch and remove are called with an empty id and use the query
+	Multi []RestMethod
+	// Timeouts overwrites the request timeout per method (`All` sets it for all methods, 0 disables the timeout)
+	Timeouts map[RestMethod]time.Duration
+	name     string
+	methods  map[string]CustomMethod
+}
+
+// Timeout returns the timeout configured for method
+func (b *BaseService) Timeout(method RestMethod) (time.Duration, bool) {
+	if timeout, ok := b.Timeouts[method]; ok {
+		return timeout, true
+	}
+	timeout, ok := b.Timeouts[All]
+	return timeout, ok
+}
+
+// RegisterMethod registers a custom method which can be called over all providers.
+/*
+Custom methods run through before, after and error hooks (registered in `HooksTreeBranch.Custom`).
+They can be called with a socket.io event named like the method or with a http POST request and the `X-Service-Method` header.
+Panics if name is the name of a standard service method
+*/
+func (b *BaseService) RegisterMethod(name string, method CustomMethod) {
+	if !isCustomMethod(RestMethod(name)) || name == "" {
+		panic(fmt.Sprintf("Cannot register custom method %s", name))
+	}
+	if b.methods == nil {
+		b.methods = map[string]CustomMethod{}
+	}
+	b.methods[name] = method
+}
+
+// CustomMethod returns the custom method registered with name
+func (b *BaseService) CustomMethod(name string) (CustomMethod, bool) {
+	method, ok := b.methods[name]
+	return method, ok
+}
+
+// CustomMethodNames returns the names of all registered custom methods
+func (b *BaseService) CustomMethodNames() []string {
+	names := make([]string, 0, len(b.methods))
+	for name := range b.methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+// AllowsMulti returns true if method is allowed to affect multiple entities
+func (b *BaseService) AllowsMulti(method RestMethod) bool {
+	for _, allowed := range b.Multi {
+		if allowed == All || allowed == method {
+			return true
+		}
+	}
+	return false
 }
 
 func (b *BaseService) Name() string {
@@ -264,6 +368,11 @@ func (as *appService) Create(ctx context.Context, data map[string]interface{}, p
 	return as.callMethod(ctx, Create, data, "", params)
 }
 
+// CreateMany creates multiple entities in one call (the service has to allow multi create)
+func (as *appService) CreateMany(ctx context.Context, data []map[string]interface{}, params Params) (interface{}, error) {
+	return as.callMethod(ctx, Create, data, "", params)
+}
+
 func (as *appService) Update(ctx context.Context, id string, data map[string]interface{}, params Params) (interface{}, error) {
 	return as.callMethod(ctx, Update, data, id, params)
 }
@@ -314,7 +423,19 @@ func (s *appService) BeforePublish(topic string, data interface{}, ctx *Context)
 	return data, nil
 }
 
-func (as *appService) callMethod(ctx context.Context, method RestMethod, data map[string]interface{}, id string, params Params) (interface{}, error) {
+// Call calls a custom method of the service
+func (as *appService) Call(ctx context.Context, method string, data map[string]interface{}, params Params) (interface{}, error) {
+	return as.callMethod(ctx, RestMethod(method), data, "", params)
+}
+
+func (as *appService) AllowsMulti(method RestMethod) bool {
+	if multiService, ok := as.service.(MultiService); ok {
+		return multiService.AllowsMulti(method)
+	}
+	return false
+}
+
+func (as *appService) callMethod(ctx context.Context, method RestMethod, data interface{}, id string, params Params) (interface{}, error) {
 	caller := &appServiceCaller{
 		success: make(chan interface{}, 0),
 		err:     make(chan error, 0),
@@ -0,0 +1,164 @@
+package feathers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+type testService struct {
+	*feathers.BaseService
+}
+
+func (s *testService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	return []map[string]interface{}{}, nil
+}
+
+func (s *testService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return map[string]interface{}{"_id": id}, nil
+}
+
+func (s *testService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return data, nil
+}
+
+func (s *testService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return data, nil
+}
+
+func (s *testService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return data, nil
+}
+
+func (s *testService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return map[string]interface{}{"_id": id}, nil
+}
+
+func newTestService() *testService {
+	return &testService{
+		BaseService: &feathers.BaseService{},
+	}
+}
+
+func TestCustomMethod(t *testing.T) {
+	app := feathers.NewApp()
+	service := newTestService()
+	service.RegisterMethod("approve", func(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+		return map[string]interface{}{"approved": data["id"], "hook": data["hook"]}, nil
+	})
+	service.Hooks.Before.Append("approve", []feathers.Hook{
+		func(ctx *feathers.Context) error {
+			ctx.Data["hook"] = true
+			return nil
+		},
+	})
+	app.AddService("messages", service)
+
+	callable, ok := app.Service("messages").(feathers.CallableService)
+	if !ok {
+		t.Fatalf("Service returned by app is not callable")
+	}
+	result, err := callable.Call(context.Background(), "approve", map[string]interface{}{"id": "1"}, *feathers.NewParams())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	resultMap := result.(map[string]interface{})
+	if resultMap["approved"] != "1" || resultMap["hook"] != true {
+		t.Errorf("Custom method was not called through hooks: %#v", resultMap)
+	}
+
+	_, err = callable.Call(context.Background(), "resend", map[string]interface{}{}, *feathers.NewParams())
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 405 {
+		t.Errorf("Expected MethodNotAllowed for unknown method, got: %v", err)
+	}
+}
+
+func TestRegisterStandardMethodPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a standard method should panic")
+		}
+	}()
+	newTestService().RegisterMethod("create", nil)
+}
+
+func TestMultiNotAllowed(t *testing.T) {
+	app := feathers.NewApp()
+	service := newTestService()
+	app.AddService("messages", service)
+
+	_, err := app.Service("messages").Patch(context.Background(), "", map[string]interface{}{"read": true}, *feathers.NewParams())
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 405 {
+		t.Errorf("Expected MethodNotAllowed for multi patch, got: %v", err)
+	}
+
+	service.Multi = []feathers.RestMethod{feathers.Patch, feathers.Create}
+	_, err = app.Service("messages").Patch(context.Background(), "", map[string]interface{}{"read": true}, *feathers.NewParams())
+	if err != nil {
+		t.Errorf("Unexpected error for allowed multi patch: %s", err)
+	}
+
+	result, err := app.Service("messages").(feathers.MultiCreateService).CreateMany(context.Background(), []map[string]interface{}{{"a": 1}, {"a": 2}}, *feathers.NewParams())
+	if err != nil {
+		t.Fatalf("Unexpected error for multi create: %s", err)
+	}
+	if items, ok := result.([]interface{}); !ok || len(items) != 2 {
+		t.Errorf("Expected two created entities, got: %#v", result)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	app := feathers.NewApp()
+	service := newTestService()
+	service.Timeouts = map[feathers.RestMethod]time.Duration{feathers.All: 20 * time.Millisecond}
+	service.RegisterMethod("report", func(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return data, nil
+	})
+	app.AddService("reports", service)
+
+	start := time.Now()
+	_, err := app.Service("reports").(feathers.CallableService).Call(context.Background(), "report", map[string]interface{}{}, *feathers.NewParams())
+	if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 408 {
+		t.Errorf("Expected Timeout error, got: %v", err)
+	}
+	if time.Since(start) > 150*time.Millisecond {
+		t.Errorf("Timeout was not returned before the service finished")
+	}
+
+	service.Timeouts = map[feathers.RestMethod]time.Duration{"report": 0}
+	_, err = app.Service("reports").(feathers.CallableService).Call(context.Background(), "report", map[string]interface{}{}, *feathers.NewParams())
+	if err != nil {
+		t.Errorf("Unexpected error with disabled timeout: %s", err)
+	}
+}
+
+func TestRequestCancellation(t *testing.T) {
+	app := feathers.NewApp()
+	service := newTestService()
+	cancelled := make(chan bool, 1)
+	service.RegisterMethod("wait", func(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+		<-ctx.Done()
+		cancelled <- true
+		return nil, ctx.Err()
+	})
+	app.AddService("waits", service)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := app.Service("waits").(feathers.CallableService).Call(ctx, "wait", map[string]interface{}{}, *feathers.NewParams())
+	if err == nil {
+		t.Errorf("Expected error for cancelled request")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("Service context was not cancelled")
+	}
+}
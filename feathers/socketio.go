@@ -1,10 +1,12 @@
 package feathers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
 	gosocketio "github.com/tobiasbeck/feathers-go/gosf-socketio"
@@ -26,12 +28,20 @@ func stringToCallmethod(method string) RestMethod {
 	case "get":
 		return Get
 	}
-	panic("unknown server method")
+	// custom service method
+	return RestMethod(method)
 }
 
 type socketConnection struct {
-	channel    *gosocketio.Channel
-	authEntity interface{}
+	channel        *gosocketio.Channel
+	authEntity     interface{}
+	authentication map[string]interface{}
+	authExpiresAt  time.Time
+	expiryTimer    *time.Timer
+	authLock       sync.RWMutex
+	// ctx is cancelled when the socket disconnects
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func (c *socketConnection) Join(room string) error {
@@ -41,15 +51,76 @@ func (c *socketConnection) Leave(room string) error {
 	return c.channel.Leave(room)
 }
 
+// expired returns if the authentication has expired (authLock has to be held)
+func (c *socketConnection) expired() bool {
+	return !c.authExpiresAt.IsZero() && !time.Now().Before(c.authExpiresAt)
+}
+
+// AuthEntity returns the authenticated entity (nil if the authentication has expired)
 func (c *socketConnection) AuthEntity() interface{} {
+	c.authLock.RLock()
+	defer c.authLock.RUnlock()
+	if c.expired() {
+		return nil
+	}
 	return c.authEntity
 }
 
+// SetAuthEntity sets the authenticated entity of the connection. An existing entity is replaced (re-authentication), nil clears it (logout)
 func (c *socketConnection) SetAuthEntity(entity interface{}) {
-	if c.authEntity != nil {
-		return
-	}
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
 	c.authEntity = entity
+	if entity == nil {
+		c.setAuthExpiry(time.Time{}, nil)
+	}
+}
+
+// Authentication returns the authentication information (strategy, accessToken) of the connection
+func (c *socketConnection) Authentication() map[string]interface{} {
+	c.authLock.RLock()
+	defer c.authLock.RUnlock()
+	if c.expired() {
+		return nil
+	}
+	return c.authentication
+}
+
+// SetAuthentication sets the authentication information of the connection
+func (c *socketConnection) SetAuthentication(authentication map[string]interface{}) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	c.authentication = authentication
+}
+
+// AuthExpiresAt returns when the authentication of the connection expires
+func (c *socketConnection) AuthExpiresAt() time.Time {
+	c.authLock.RLock()
+	defer c.authLock.RUnlock()
+	return c.authExpiresAt
+}
+
+// SetAuthExpiry sets when the authentication expires and replaces a previous expiry
+func (c *socketConnection) SetAuthExpiry(expiresAt time.Time, onExpire func()) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	c.setAuthExpiry(expiresAt, onExpire)
+}
+
+func (c *socketConnection) setAuthExpiry(expiresAt time.Time, onExpire func()) {
+	if c.expiryTimer != nil {
+		c.expiryTimer.Stop()
+		c.expiryTimer = nil
+	}
+	c.authExpiresAt = expiresAt
+	if !expiresAt.IsZero() && onExpire != nil {
+		c.expiryTimer = time.AfterFunc(time.Until(expiresAt), onExpire)
+	}
+}
+
+// Disconnect closes the socket
+func (c *socketConnection) Disconnect() {
+	c.channel.Close()
 }
 
 func (c *socketConnection) Emit(event string, data interface{}) error {
@@ -57,7 +128,7 @@ func (c *socketConnection) Emit(event string, data interface{}) error {
 }
 
 func (c *socketConnection) IsAuthenticated() bool {
-	return c.authEntity != nil
+	return c.AuthEntity() != nil
 }
 
 type socketCaller struct {
@@ -84,11 +155,34 @@ func (c *socketCaller) SocketConnection() Connection {
 	return c.connection
 }
 
+// Headers returns the headers of the socket handshake request
+func (c *socketCaller) Headers() map[string]string {
+	if c.channel.Request() == nil {
+		return map[string]string{}
+	}
+	return requestHeaders(c.channel.RequestHeader())
+}
+
+// RemoteAddress returns the network address of the socket client
+func (c *socketCaller) RemoteAddress() string {
+	if c.channel.Request() == nil {
+		return ""
+	}
+	return c.channel.Request().RemoteAddr
+}
+
+// Context returns the context of the socket connection (cancelled on disconnect)
+func (c *socketCaller) Context() context.Context {
+	return c.connection.ctx
+}
+
 //SocketIOProvider handles socket.io connections and events
 type SocketIOProvider struct {
 	server      *gosocketio.Server
 	app         *App
 	connections map[string]*socketConnection
+	// connectionsLock guards connections (connection events and calls of different sockets run concurrently)
+	connectionsLock sync.RWMutex
 }
 
 //Publish publishes a event to connections subscibed to room
@@ -109,15 +203,25 @@ func NewSocketIOProvider(app *App, config map[string]interface{}) *SocketIOProvi
 	provider.listenEvent("find")
 	provider.listenEvent("get")
 	provider.server.On(gosocketio.OnConnection, func(channel *gosocketio.Channel) {
+		ctx, cancel := context.WithCancel(context.Background())
 		connection := &socketConnection{
 			channel: channel,
+			ctx:     ctx,
+			cancel:  cancel,
 		}
+		provider.connectionsLock.Lock()
 		provider.connections[channel.Id()] = connection
+		provider.connectionsLock.Unlock()
 		provider.app.Emit("connection", channel)
 	})
 	provider.server.On(gosocketio.OnDisconnection, func(channel *gosocketio.Channel) {
-		if socketchannel, ok := provider.connections[channel.Id()]; ok {
-			delete(provider.connections, channel.Id())
+		provider.connectionsLock.Lock()
+		socketchannel, ok := provider.connections[channel.Id()]
+		delete(provider.connections, channel.Id())
+		provider.connectionsLock.Unlock()
+		if ok {
+			socketchannel.cancel()
+			socketchannel.SetAuthExpiry(time.Time{}, nil)
 			provider.app.Emit("disconnect", socketchannel)
 		}
 
@@ -145,12 +249,15 @@ func (p *SocketIOProvider) listenEvent(event string) {
 }
 
 //Handle handles a new event to a service
-func (p *SocketIOProvider) Handle(callMethod RestMethod, caller socketCaller, service string, data map[string]interface{}, id string, query map[string]interface{}) {
+func (p *SocketIOProvider) Handle(callMethod RestMethod, caller socketCaller, service string, data interface{}, id string, query map[string]interface{}) {
 	p.app.HandleRequest("socketio", callMethod, &caller, service, data, id, query)
 }
 
 // Listen starts listening for new socket.io connections
 func (fs *SocketIOProvider) Listen(port int, serveMux *http.ServeMux) {
+	for _, method := range fs.app.customMethodNames() {
+		fs.listenEvent(method)
+	}
 	serveMux.Handle("/socket.io/", fs.server)
 }
 
@@ -169,7 +276,9 @@ func (fs *SocketIOProvider) handleEvent(event string, c *gosocketio.Channel, res
 		return
 	}
 
+	fs.connectionsLock.RLock()
 	connection, ok := fs.connections[c.Id()]
+	fs.connectionsLock.RUnlock()
 
 	if !ok {
 		go func() {
@@ -187,9 +296,24 @@ func (fs *SocketIOProvider) handleEvent(event string, c *gosocketio.Channel, res
 	}
 
 	callMethod := stringToCallmethod(event)
-	var reqData map[string]interface{} = map[string]interface{}{}
+	var reqData interface{} = map[string]interface{}{}
 	reqQuery := make(map[string]interface{})
 	var id string = ""
+	if isCustomMethod(callMethod) {
+		// custom methods are called with (path, data, query)
+		if len(data) >= 2 {
+			if customData, ok := data[1].(map[string]interface{}); ok {
+				reqData = customData
+			}
+		}
+		if len(data) >= 3 {
+			if customQuery, ok := data[2].(map[string]interface{}); ok {
+				reqQuery = customQuery
+			}
+		}
+		fs.Handle(callMethod, caller, service, reqData, id, reqQuery)
+		return
+	}
 	if len(data) >= 2 {
 		switch v := data[1].(type) {
 		case string:
@@ -211,6 +335,11 @@ func (fs *SocketIOProvider) handleEvent(event string, c *gosocketio.Channel, res
 		case map[string]interface{}:
 			reqQuery = filterData(dl_query, callMethod, v)
 			reqData = filterData(dl_data, callMethod, v)
+		case []interface{}:
+			// multi create
+			if callMethod == Create {
+				reqData = v
+			}
 		}
 	}
 	if len(data) >= 4 {
@@ -41,8 +41,15 @@ type EventEmitter struct {
 	sync.RWMutex
 }
 
+// topic returns the topic of event (nil if nobody listened to it yet)
+func (el *EventEmitter) topic(event string) *topic {
+	el.RLock()
+	defer el.RUnlock()
+	return el.eventListeners[event]
+}
+
 func (el *EventEmitter) Emit(event string, data interface{}) {
-	if eventTopic, ok := el.eventListeners[event]; ok {
+	if eventTopic := el.topic(event); eventTopic != nil {
 		eventTopic.RLock()
 		nl := make([]listenerEntry, 0, len(eventTopic.listeners))
 		for _, listener := range eventTopic.listeners {
@@ -56,16 +63,14 @@ func (el *EventEmitter) Emit(event string, data interface{}) {
 		eventTopic.RUnlock()
 		eventTopic.Lock()
 		defer eventTopic.Unlock()
-		t := el.eventListeners[event]
-		t.listeners = nl
-		el.eventListeners[event] = t
+		eventTopic.listeners = nl
 	}
 }
 
 func (el *EventEmitter) initTopic(topicName string) {
+	el.Lock()
+	defer el.Unlock()
 	if _, ok := el.eventListeners[topicName]; !ok {
-		el.Lock()
-		defer el.Unlock()
 		el.eventListeners[topicName] = &topic{
 			listeners: make([]listenerEntry, 0),
 		}
@@ -75,9 +80,7 @@ func (el *EventEmitter) initTopic(topicName string) {
 type EventListenerUnregister = func() bool
 
 func (el *EventEmitter) On(event string) (<-chan interface{}, EventListenerUnregister) {
-	if _, ok := el.eventListeners[event]; !ok {
-		el.initTopic(event)
-	}
+	el.initTopic(event)
 	id, _ := uuid.New()
 	listenerE := listenerEntry{
 		key:     id,
@@ -85,13 +88,10 @@ func (el *EventEmitter) On(event string) (<-chan interface{}, EventListenerUnreg
 		once:    false,
 	}
 
-	el.RLock()
-	eventTopic := el.eventListeners[event]
-	el.RUnlock()
+	eventTopic := el.topic(event)
 	eventTopic.Lock()
 	defer eventTopic.Unlock()
 	eventTopic.listeners = append(eventTopic.listeners, listenerE)
-	el.eventListeners[event] = eventTopic
 	return listenerE.channel, func() bool {
 		eventTopic.Lock()
 		defer eventTopic.Unlock()
@@ -106,20 +106,15 @@ func (el *EventEmitter) On(event string) (<-chan interface{}, EventListenerUnreg
 }
 
 func (el *EventEmitter) Once(event string) <-chan interface{} {
-	if _, ok := el.eventListeners[event]; !ok {
-		el.initTopic(event)
-	}
+	el.initTopic(event)
 	listenerE := listenerEntry{
 		channel: make(chan interface{}),
 		once:    true,
 	}
-	el.RLock()
-	eventTopic := el.eventListeners[event]
-	el.RUnlock()
+	eventTopic := el.topic(event)
 	eventTopic.Lock()
 	defer eventTopic.Unlock()
 	eventTopic.listeners = append(eventTopic.listeners, listenerE)
-	el.eventListeners[event] = eventTopic
 	return listenerE.channel
 }
 
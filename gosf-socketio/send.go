@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/tobiasbeck/feathers-go/gosf-socketio/protocol"
@@ -46,6 +47,13 @@ func send(msg *protocol.Message, c *Channel, args interface{}, err error) error
 		msg.Args = string(json)
 	}
 
+	return sendEncoded(msg, c)
+}
+
+/**
+Encode message packet with already serialized args and send it to socket
+*/
+func sendEncoded(msg *protocol.Message, c *Channel) error {
 	command, err := protocol.Encode(msg)
 	if err != nil {
 		return err
@@ -97,3 +105,40 @@ func (c *Channel) Ack(method string, args []interface{}, timeout time.Duration)
 		return "", ErrorSendTimeout
 	}
 }
+
+/**
+Create ack packet which passes each of args as separate argument (like socket.io clients do),
+send it and receive response
+*/
+func (c *Channel) AckArgs(method string, args []interface{}, timeout time.Duration) (string, error) {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		part, err := json.Marshal(arg)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, string(part))
+	}
+	msg := &protocol.Message{
+		Type:   protocol.MessageTypeAckRequest,
+		AckId:  c.ack.getNextId(),
+		Method: method,
+		Args:   strings.Join(parts, ","),
+	}
+
+	waiter := make(chan string)
+	c.ack.addWaiter(msg.AckId, waiter)
+
+	if err := sendEncoded(msg, c); err != nil {
+		c.ack.removeWaiter(msg.AckId)
+		return "", err
+	}
+
+	select {
+	case result := <-waiter:
+		return result, nil
+	case <-time.After(timeout):
+		c.ack.removeWaiter(msg.AckId)
+		return "", ErrorSendTimeout
+	}
+}
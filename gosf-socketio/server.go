@@ -351,10 +351,11 @@ func (s *Server) SetupEventLoop(conn transport.Connection, remoteAddr string,
 
 	s.SendOpenSequence(c)
 
+	// connection handlers run before incoming messages are processed
+	s.callLoopEvent(c, OnConnection)
+
 	go inLoop(c, &s.methods)
 	go outLoop(c, &s.methods)
-
-	s.callLoopEvent(c, OnConnection)
 }
 
 /**
@@ -20,29 +20,60 @@ func normalizeToMapSlice(slice interface{}) []map[string]interface{} {
 	return []map[string]interface{}{}
 }
 
+// resultPage returns the page if the result is a paginated find result
+func resultPage(ctx *feathers.Context) (*feathers.Page, bool) {
+	switch page := ctx.Result.(type) {
+	case feathers.Page:
+		return &page, true
+	case *feathers.Page:
+		return page, true
+	}
+	return nil, false
+}
+
+// GetItems returns data in before hooks and the result in after hooks (for paginated results the page data)
+/*
+For multi create calls the list of entities is returned in before hooks
+*/
 func GetItems(ctx *feathers.Context) interface{} {
 	if ctx.Type == feathers.Before {
+		if ctx.MultiData != nil {
+			return ctx.MultiData
+		}
 		return ctx.Data
 	} else {
+		if page, ok := resultPage(ctx); ok {
+			return page.Data
+		}
 		return ctx.Result
 	}
 }
 
+// ReplaceItems replaces data in before hooks and the result in after hooks (for paginated results the page data)
 func ReplaceItems(ctx *feathers.Context, data interface{}) {
 	if ctx.Type == feathers.Before {
+		if ctx.MultiData != nil {
+			ctx.MultiData = normalizeToMapSlice(data)
+			return
+		}
 		ctx.Data = data.(map[string]interface{})
 	} else {
+		if page, ok := resultPage(ctx); ok {
+			page.Data = data
+			ctx.Result = *page
+			return
+		}
 		ctx.Result = data
 	}
 }
 
 func GetItemsNormalized(ctx *feathers.Context) ([]map[string]interface{}, bool) {
 	if ctx.Type == feathers.Before {
-		slice, normalized := NormalizeSlice(ctx.Data)
+		slice, normalized := NormalizeSlice(GetItems(ctx))
 		mapSlice := normalizeToMapSlice(slice)
 		return mapSlice, normalized
 	} else {
-		slice, normalized := NormalizeSlice(ctx.Result)
+		slice, normalized := NormalizeSlice(GetItems(ctx))
 		mapSlice := normalizeToMapSlice(slice)
 		return mapSlice, normalized
 	}
@@ -51,6 +82,10 @@ func GetItemsNormalized(ctx *feathers.Context) ([]map[string]interface{}, bool)
 func ReplaceItemsNormalized(ctx *feathers.Context, data interface{}, normalized bool) {
 	normData := UnormalizeSlice(data, normalized)
 	if ctx.Type == feathers.Before {
+		if ctx.MultiData != nil {
+			ReplaceItems(ctx, normData)
+			return
+		}
 		if normData != nil {
 			ctx.Data = normData.(map[string]interface{})
 		} else {
@@ -58,6 +93,6 @@ func ReplaceItemsNormalized(ctx *feathers.Context, data interface{}, normalized
 		}
 
 	} else {
-		ctx.Result = normData
+		ReplaceItems(ctx, normData)
 	}
 }
@@ -161,3 +161,57 @@ func TestReplaceItemsNormalizedAfter(t *testing.T) {
 		t.Errorf("Did not return data correctly")
 	}
 }
+
+func TestGetItemsNormalizedPage(t *testing.T) {
+	data := []map[string]interface{}{{
+		"test": "TesT",
+	}}
+	ctx := &feathers.Context{
+		Type:   feathers.After,
+		Method: "find",
+		Result: feathers.Page{Total: 5, Limit: 1, Data: data},
+	}
+
+	items, normalized := hooks.GetItemsNormalized(ctx)
+	if normalized != false {
+		t.Errorf("Should not normalize page data")
+	}
+	if !reflect.DeepEqual(items, data) {
+		t.Errorf("Did not return page data correctly")
+	}
+
+	replaced := []map[string]interface{}{{"test": "test"}}
+	hooks.ReplaceItemsNormalized(ctx, replaced, normalized)
+	page, ok := ctx.Result.(feathers.Page)
+	if !ok {
+		t.Errorf("Result is not a page anymore (%T)", ctx.Result)
+		return
+	}
+	if page.Total != 5 || !reflect.DeepEqual(page.Data, replaced) {
+		t.Errorf("Did not replace page data correctly")
+	}
+}
+
+func TestGetItemsNormalizedMulti(t *testing.T) {
+	data := []map[string]interface{}{{"test": "a"}, {"test": "b"}}
+	ctx := &feathers.Context{
+		Type:      feathers.Before,
+		Method:    "create",
+		Data:      map[string]interface{}{},
+		MultiData: data,
+	}
+
+	items, normalized := hooks.GetItemsNormalized(ctx)
+	if normalized != false {
+		t.Errorf("Should not normalize multi data")
+	}
+	if !reflect.DeepEqual(items, data) {
+		t.Errorf("Did not return multi data correctly")
+	}
+
+	replaced := []map[string]interface{}{{"test": "c"}}
+	hooks.ReplaceItemsNormalized(ctx, replaced, normalized)
+	if !reflect.DeepEqual(ctx.MultiData, replaced) {
+		t.Errorf("Did not replace multi data correctly")
+	}
+}
@@ -22,3 +22,57 @@ func Join(joinConfig map[string]JoinOperator) feathers.Hook {
 		return nil
 	}
 }
+
+// BatchRelation describes the entities of Service which BatchJoin assigns to a field of the items
+type BatchRelation struct {
+	// Service is the path of the related service
+	Service string
+	// LocalField is the field of the items containing the key (e.g. `userId`)
+	LocalField string
+	// ForeignField is the field of the related entities containing the key (e.g. `_id`)
+	ForeignField string
+	// Many assigns a list of all related entities instead of the first one
+	Many bool
+}
+
+// BatchJoin assigns the related entities to the items like Join, but loads them with a Loader
+/*
+The keys of all items are collected and each relation is loaded with a single find instead of one query per item.
+The params of the context are passed to the related services so their authorization applies.
+
+Example:
+````
+hooks.BatchJoin(map[string]hooks.BatchRelation{
+	"user":     {Service: "users", LocalField: "userId", ForeignField: "_id"},
+	"comments": {Service: "comments", LocalField: "_id", ForeignField: "messageId", Many: true},
+})
+````
+*/
+func BatchJoin(relations map[string]BatchRelation) feathers.Hook {
+	return func(ctx *feathers.Context) error {
+		data, normalized := GetItemsNormalized(ctx)
+		for name, relation := range relations {
+			keys := make([]interface{}, 0, len(data))
+			for _, entity := range data {
+				keys = append(keys, entity[relation.LocalField])
+			}
+			related, err := GetLoader(ctx, relation.Service, relation.ForeignField).Load(ctx, keys)
+			if err != nil {
+				return err
+			}
+			for _, entity := range data {
+				entities := related[LoaderKey(entity[relation.LocalField])]
+				if relation.Many {
+					if entities == nil {
+						entities = []map[string]interface{}{}
+					}
+					entity[name] = entities
+				} else if len(entities) > 0 {
+					entity[name] = entities[0]
+				}
+			}
+		}
+		ReplaceItemsNormalized(ctx, data, normalized)
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+)
+
+type loadersKey struct{}
+
+type loadingKey struct{}
+
+// loading contains the keys (by service and field) which are loaded by the finds a context was derived from
+type loading map[string]map[string]bool
+
+// with returns a copy of the loading keys which contains the keys of name as well
+func (l loading) with(name string, keys map[string][]map[string]interface{}) loading {
+	result := make(loading, len(l)+1)
+	for loaderName, loaderKeys := range l {
+		result[loaderName] = loaderKeys
+	}
+	merged := make(map[string]bool, len(result[name])+len(keys))
+	for key := range result[name] {
+		merged[key] = true
+	}
+	for key := range keys {
+		merged[key] = true
+	}
+	result[name] = merged
+	return result
+}
+
+// loaders are the loaders of a request stored in its context
+type loaders struct {
+	lock    sync.Mutex
+	loaders map[string]*Loader
+}
+
+// Loader batches the lookups of entities of a service by the value of a field (DataLoader-style)
+/*
+All keys passed to Load are fetched with a single find of the service (`{field: {"$in": keys}}`) and cached, so each
+entity is only loaded once per request. Loaders are scoped to the request context (see GetLoader)
+*/
+type Loader struct {
+	service string
+	field   string
+
+	lock  sync.Mutex
+	cache map[string][]map[string]interface{}
+}
+
+// GetLoader returns the loader of service and field for the request of ctx (it is created on first use)
+/*
+The loader is stored in the context of the request, so service calls made with this context share it as well
+*/
+func GetLoader(ctx *feathers.Context, service string, field string) *Loader {
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
+	}
+	registry, ok := ctx.Context.Value(loadersKey{}).(*loaders)
+	if !ok {
+		registry = &loaders{loaders: map[string]*Loader{}}
+		ctx.Context = context.WithValue(ctx.Context, loadersKey{}, registry)
+	}
+
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	// Entities are cached per provider so results of internal calls are not used for external calls
+	name := fmt.Sprintf("%s:%s:%s", ctx.Params.Provider, service, field)
+	loader, ok := registry.loaders[name]
+	if !ok {
+		loader = &Loader{
+			service: service,
+			field:   field,
+			cache:   map[string][]map[string]interface{}{},
+		}
+		registry.loaders[name] = loader
+	}
+	return loader
+}
+
+// Load returns the entities of the service grouped by the keys (entities of already loaded keys are cached)
+/*
+Keys which are not cached are loaded with one find through the app, the params of ctx are passed so hooks of the
+service (e.g. authorization) apply to the caller. Keys which are loaded by a find that (indirectly) triggered this load
+are left out, so joins in hooks of the related service (e.g. self joins or cycles) neither wait for themselves nor
+recurse endlessly
+*/
+func (l *Loader) Load(ctx *feathers.Context, keys []interface{}) (map[string][]map[string]interface{}, error) {
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ancestors, _ := parent.Value(loadingKey{}).(loading)
+	name := l.service + ":" + l.field
+
+	missing := []interface{}{}
+	seen := map[string]bool{}
+	l.lock.Lock()
+	for _, key := range keys {
+		keyString := LoaderKey(key)
+		if keyString == "" || seen[keyString] {
+			continue
+		}
+		seen[keyString] = true
+		if _, ok := l.cache[keyString]; !ok && !ancestors[name][keyString] {
+			missing = append(missing, key)
+		}
+	}
+	l.lock.Unlock()
+
+	if len(missing) > 0 {
+		service := ctx.App.Service(l.service)
+		if service == nil {
+			return nil, httperrors.NewGeneralError(fmt.Sprintf("Service %s not found", l.service))
+		}
+		related := make(map[string][]map[string]interface{}, len(missing))
+		for _, key := range missing {
+			related[LoaderKey(key)] = []map[string]interface{}{}
+		}
+		params := feathers.NewParamsFrom(&ctx.Params, feathers.WithQuery(map[string]interface{}{
+			l.field: map[string]interface{}{"$in": missing},
+		}))
+		params.Set("paginate", false)
+		// the lock is not held during the find, hooks of the service may use this loader as well
+		result, err := service.Find(context.WithValue(parent, loadingKey{}, ancestors.with(name, related)), *params)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resultItems(result) {
+			keyString := LoaderKey(item[l.field])
+			if entities, ok := related[keyString]; ok {
+				related[keyString] = append(entities, item)
+			}
+		}
+		l.lock.Lock()
+		for keyString, entities := range related {
+			l.cache[keyString] = entities
+		}
+		l.lock.Unlock()
+	}
+
+	result := make(map[string][]map[string]interface{}, len(seen))
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for keyString := range seen {
+		if entities, ok := l.cache[keyString]; ok {
+			result[keyString] = entities
+		}
+	}
+	return result, nil
+}
+
+// LoaderKey returns the string a key is grouped by (ObjectIds are converted to their hex representation)
+func LoaderKey(key interface{}) string {
+	switch v := key.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case interface{ Hex() string }:
+		return v.Hex()
+	}
+	return fmt.Sprint(key)
+}
+
+// resultItems returns the entities of a find result (a list or a page)
+func resultItems(result interface{}) []map[string]interface{} {
+	switch v := result.(type) {
+	case feathers.Page:
+		return normalizeToMapSlice(v.Data)
+	case *feathers.Page:
+		return normalizeToMapSlice(v.Data)
+	}
+	return normalizeToMapSlice(result)
+}
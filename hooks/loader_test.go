@@ -0,0 +1,203 @@
+package hooks_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/hooks"
+	"github.com/tobiasbeck/feathers-go/memory"
+)
+
+type countingService struct {
+	*memory.Service
+	lock   sync.Mutex
+	params []feathers.Params
+}
+
+func (s *countingService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	s.lock.Lock()
+	s.params = append(s.params, params)
+	s.lock.Unlock()
+	return s.Service.Find(ctx, params)
+}
+
+func newCountingService(t *testing.T, records ...map[string]interface{}) *countingService {
+	service := &countingService{Service: memory.NewService(nil, nil)}
+	for _, record := range records {
+		if _, err := service.Create(context.Background(), record, *feathers.NewParams()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	return service
+}
+
+func newMessagesApp(t *testing.T, hooksAfterFind ...feathers.Hook) (*feathers.App, *countingService, *countingService) {
+	app := feathers.NewApp()
+	users := newCountingService(t,
+		map[string]interface{}{"_id": "u1", "name": "a"},
+		map[string]interface{}{"_id": "u2", "name": "b"},
+	)
+	comments := newCountingService(t,
+		map[string]interface{}{"_id": "c1", "messageId": "m1"},
+		map[string]interface{}{"_id": "c2", "messageId": "m1"},
+		map[string]interface{}{"_id": "c3", "messageId": "m3"},
+	)
+	messages := newCountingService(t,
+		map[string]interface{}{"_id": "m1", "userId": "u1"},
+		map[string]interface{}{"_id": "m2", "userId": "u2"},
+		map[string]interface{}{"_id": "m3", "userId": "u1"},
+		map[string]interface{}{"_id": "m4", "userId": "u3"},
+	)
+	messages.Hooks.Append(feathers.After, feathers.Find, hooksAfterFind)
+	app.AddService("users", users)
+	app.AddService("comments", comments)
+	app.AddService("messages", messages)
+	return app, users, comments
+}
+
+func TestBatchJoin(t *testing.T) {
+	app, users, comments := newMessagesApp(t, hooks.BatchJoin(map[string]hooks.BatchRelation{
+		"user":     {Service: "users", LocalField: "userId", ForeignField: "_id"},
+		"comments": {Service: "comments", LocalField: "_id", ForeignField: "messageId", Many: true},
+	}))
+	params := feathers.Params{Provider: "rest", User: map[string]interface{}{"_id": "u1"}, Authenticated: true}
+	result, err := app.Service("messages").Find(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Find returned unexpected error: %s", err)
+	}
+
+	if len(users.params) != 1 || len(comments.params) != 1 {
+		t.Fatalf("expected one find per relation, got: %d and %d", len(users.params), len(comments.params))
+	}
+	userParams := users.params[0]
+	if userParams.Provider != "rest" || !reflect.DeepEqual(userParams.User, params.User) || !userParams.Authenticated {
+		t.Errorf("params of the caller were not passed: %#v", userParams)
+	}
+	expectedQuery := feathers.Query{"_id": map[string]interface{}{"$in": []interface{}{"u1", "u2", "u3"}}}
+	if !reflect.DeepEqual(userParams.Query, expectedQuery) {
+		t.Errorf("unexpected query: %#v", userParams.Query)
+	}
+
+	items := result.([]map[string]interface{})
+	for key, expected := range []struct {
+		user     interface{}
+		comments []string
+	}{
+		/* #1 */ {"a", []string{"c1", "c2"}},
+		/* #2 */ {"b", []string{}},
+		/* #3 */ {"a", []string{"c3"}},
+		/* #4 */ {nil, []string{}},
+	} {
+		var user interface{}
+		if entity, ok := items[key]["user"].(map[string]interface{}); ok {
+			user = entity["name"]
+		}
+		if user != expected.user {
+			t.Errorf("Failed #%d: wanted user %v, got: %v", key+1, expected.user, user)
+		}
+		commentIds := []string{}
+		for _, comment := range items[key]["comments"].([]map[string]interface{}) {
+			commentIds = append(commentIds, comment["_id"].(string))
+		}
+		if !reflect.DeepEqual(commentIds, expected.comments) {
+			t.Errorf("Failed #%d: wanted comments %v, got: %v", key+1, expected.comments, commentIds)
+		}
+	}
+}
+
+func TestLoaderCache(t *testing.T) {
+	var loaders []*hooks.Loader
+	app, users, _ := newMessagesApp(t, func(ctx *feathers.Context) error {
+		for _, keys := range [][]interface{}{{"u1"}, {"u1", "u2", "u2"}} {
+			loader := hooks.GetLoader(ctx, "users", "_id")
+			loaders = append(loaders, loader)
+			related, err := loader.Load(ctx, keys)
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if len(related[key.(string)]) != 1 {
+					t.Errorf("unexpected result for %s: %#v", key, related)
+				}
+			}
+		}
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := app.Service("messages").Find(context.Background(), *feathers.NewParams()); err != nil {
+			t.Fatalf("Find returned unexpected error: %s", err)
+		}
+	}
+	if loaders[0] != loaders[1] || loaders[2] != loaders[3] {
+		t.Errorf("loader is not scoped to the request")
+	}
+	if loaders[0] == loaders[2] {
+		t.Errorf("loader is shared between requests")
+	}
+	if len(users.params) != 4 {
+		t.Fatalf("expected 4 finds, got: %d", len(users.params))
+	}
+	if query := users.params[1].Query["_id"]; !reflect.DeepEqual(query, map[string]interface{}{"$in": []interface{}{"u2"}}) {
+		t.Errorf("cached keys were loaded again: %#v", query)
+	}
+}
+
+func TestBatchJoinNested(t *testing.T) {
+	app := feathers.NewApp()
+	users := newCountingService(t,
+		map[string]interface{}{"_id": "u1", "name": "a", "managerId": "u2"},
+		map[string]interface{}{"_id": "u2", "name": "b", "managerId": "u1"},
+		map[string]interface{}{"_id": "u3", "name": "c"},
+	)
+	messages := newCountingService(t,
+		map[string]interface{}{"_id": "m1", "userId": "u1"},
+		map[string]interface{}{"_id": "m2", "userId": "u3"},
+	)
+	// users join themselves and their messages, which join their users again
+	users.Hooks.Append(feathers.After, feathers.Find, []feathers.Hook{hooks.BatchJoin(map[string]hooks.BatchRelation{
+		"manager":  {Service: "users", LocalField: "managerId", ForeignField: "_id"},
+		"messages": {Service: "messages", LocalField: "_id", ForeignField: "userId", Many: true},
+	})})
+	messages.Hooks.Append(feathers.After, feathers.Find, []feathers.Hook{hooks.BatchJoin(map[string]hooks.BatchRelation{
+		"user": {Service: "users", LocalField: "userId", ForeignField: "_id"},
+	})})
+	app.AddService("users", users)
+	app.AddService("messages", messages)
+
+	done := make(chan error, 1)
+	var result interface{}
+	go func() {
+		var err error
+		result, err = app.Service("messages").Find(context.Background(), feathers.Params{Provider: "rest"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Find returned unexpected error: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("nested joins did not finish")
+	}
+
+	items := result.([]map[string]interface{})
+	user, ok := items[0]["user"].(map[string]interface{})
+	if !ok || user["_id"] != "u1" {
+		t.Fatalf("user was not joined: %#v", items[0])
+	}
+	manager, ok := user["manager"].(map[string]interface{})
+	if !ok || manager["_id"] != "u2" {
+		t.Errorf("manager was not joined: %#v", user)
+	}
+	if _, ok := manager["manager"]; ok {
+		t.Errorf("cycle was not stopped: %#v", manager)
+	}
+	if user, ok := items[1]["user"].(map[string]interface{}); !ok || user["_id"] != "u3" {
+		t.Errorf("user was not joined: %#v", items[1])
+	}
+}
@@ -0,0 +1,292 @@
+package memory
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+)
+
+// SupportedOperators are the query operators the memory service can evaluate
+var SupportedOperators = []string{"$eq", "$ne", "$in", "$nin", "$lt", "$lte", "$gt", "$gte", "$exists", "$or", "$and", "$nor"}
+
+// prepareQuery parses query with the allowed operators of the service (the query is not modified)
+func (s *Service) prepareQuery(raw map[string]interface{}) (*query.Query, error) {
+	parsed, err := query.Parse(raw, s.Operators)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.Filter.Unsupported(SupportedOperators); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// matches returns if record matches filter
+func matches(record map[string]interface{}, filter query.Filter) bool {
+	for _, node := range filter {
+		var ok bool
+		switch n := node.(type) {
+		case query.Group:
+			ok = matchesGroup(record, n)
+		case query.Condition:
+			value, exists := fieldValue(record, n.Field)
+			ok = matchesCondition(value, exists, n)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesGroup(record map[string]interface{}, group query.Group) bool {
+	if group.Operator == "$or" {
+		for _, filter := range group.Filters {
+			if matches(record, filter) {
+				return true
+			}
+		}
+		return false
+	}
+	// $and and $nor
+	for _, filter := range group.Filters {
+		if matches(record, filter) == (group.Operator == "$nor") {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesCondition(value interface{}, exists bool, condition query.Condition) bool {
+	operand := condition.Value
+	switch condition.Operator {
+	case query.Eq:
+		return exists && matchesValue(value, operand)
+	case "$ne":
+		return !exists || !matchesValue(value, operand)
+	case "$in":
+		for _, item := range toSlice(operand) {
+			if exists && matchesValue(value, item) {
+				return true
+			}
+		}
+		return false
+	case "$nin":
+		for _, item := range toSlice(operand) {
+			if exists && matchesValue(value, item) {
+				return false
+			}
+		}
+		return true
+	case "$lt", "$lte", "$gt", "$gte":
+		result, comparable := compare(value, operand)
+		return exists && comparable && ((condition.Operator == "$lt" && result < 0) ||
+			(condition.Operator == "$lte" && result <= 0) ||
+			(condition.Operator == "$gt" && result > 0) ||
+			(condition.Operator == "$gte" && result >= 0))
+	case "$exists":
+		return exists == operand.(bool)
+	}
+	return false
+}
+
+// fieldValue returns the value of field in record (nested fields are separated by dots)
+func fieldValue(record map[string]interface{}, field string) (interface{}, bool) {
+	var current interface{} = record
+	for _, part := range strings.Split(field, ".") {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = currentMap[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+type hexable interface {
+	Hex() string
+}
+
+// normalize converts values so values of different go types can be compared (numbers to float64, ids to strings)
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case hexable:
+		return v.Hex()
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+// compare compares two numbers, strings, bools or times. Strings are compared to numbers as numbers (query strings
+// contain numbers as strings). ok is false if they cannot be compared
+func compare(a interface{}, b interface{}) (int, bool) {
+	a, b = normalize(a), normalize(b)
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if str, isString := b.(string); isString {
+			parsed, err := strconv.ParseFloat(str, 64)
+			y, ok = parsed, err == nil
+		}
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if _, isNumber := b.(float64); isNumber {
+			result, ok := compare(b, a)
+			return -result, ok
+		}
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if str, isString := b.(string); isString {
+			parsed, err := strconv.ParseBool(str)
+			y, ok = parsed, err == nil
+		}
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		}
+		return 1, true
+	case time.Time:
+		y, ok := b.(time.Time)
+		if str, isString := b.(string); isString {
+			parsed, err := time.Parse(time.RFC3339Nano, str)
+			y, ok = parsed, err == nil
+		}
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x.Before(y):
+			return -1, true
+		case x.After(y):
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func equal(a interface{}, b interface{}) bool {
+	if result, ok := compare(a, b); ok {
+		return result == 0
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// matchesValue returns if value (or one element of value if it is a list like mongo) equals expected
+func matchesValue(value interface{}, expected interface{}) bool {
+	if equal(value, expected) {
+		return true
+	}
+	for _, item := range toSlice(value) {
+		if equal(item, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// toSlice returns the elements of a slice value (nil if value is not a slice)
+func toSlice(value interface{}) []interface{} {
+	if values, ok := value.([]interface{}); ok {
+		return values
+	}
+	reflected := reflect.ValueOf(value)
+	if !reflected.IsValid() || reflected.Kind() != reflect.Slice {
+		return nil
+	}
+	result := make([]interface{}, reflected.Len())
+	for i := range result {
+		result[i] = reflected.Index(i).Interface()
+	}
+	return result
+}
+
+// sortRecords sorts records by the sort fields (records without value or with incomparable values keep their order)
+func sortRecords(records []map[string]interface{}, fields []query.Sort) {
+	if len(fields) == 0 {
+		return
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, field := range fields {
+			a, aExists := fieldValue(records[i], field.Field)
+			b, bExists := fieldValue(records[j], field.Field)
+			var result int
+			switch {
+			case (!aExists || a == nil) && (!bExists || b == nil):
+				continue
+			case !aExists || a == nil:
+				// missing values come first (like mongo)
+				result = -1
+			case !bExists || b == nil:
+				result = 1
+			default:
+				result, _ = compare(a, b)
+			}
+			if result != 0 {
+				return result*field.Direction < 0
+			}
+		}
+		return false
+	})
+}
+
+// selectFields returns a copy of record which only contains fields (and idField)
+func selectFields(record map[string]interface{}, fields []string, idField string) map[string]interface{} {
+	if fields == nil {
+		return record
+	}
+	selected := make(map[string]interface{}, len(fields)+1)
+	for _, field := range append([]string{idField}, fields...) {
+		if value, ok := record[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
@@ -0,0 +1,402 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+)
+
+// DefaultIDField is the field containing the id of an entity
+const DefaultIDField = "_id"
+
+// Service stores entities in memory (e.g. for tests). Use `NewService` for new instance.
+/*
+It supports the feathers query syntax: equality, $in, $nin, $lt, $lte, $gt, $gte, $ne, $or, $and and $nor
+(and $exists if it is added to Operators) and the options $sort, $skip, $limit and $select.
+Nested fields can be queried with dots (`address.city`).
+If a model is set, data is validated and mapped like the mongo service does. Entities without id get an incrementing id
+*/
+type Service struct {
+	*feathers.BaseService
+	*feathers.ModelService
+	// IDField is the field containing the id (DefaultIDField if empty)
+	IDField string
+	// Paginate enables pagination for find calls (nil disables pagination)
+	Paginate *feathers.PaginateOptions
+	// Operators are the allowed query operators (query.DefaultOperators if nil)
+	Operators []string
+
+	lock     sync.RWMutex
+	store    map[string]map[string]interface{}
+	order    []string
+	sequence int64
+}
+
+// NewService creates a new memory service. model may be nil to store data without validation
+/*
+If the app config contains a `paginate` key (`default` and `max`) it is used as pagination for the service
+*/
+func NewService(model feathers.ModelFactory, app *feathers.App) *Service {
+	service := &Service{
+		BaseService: &feathers.BaseService{},
+		IDField:     DefaultIDField,
+		store:       map[string]map[string]interface{}{},
+	}
+	if model != nil {
+		service.ModelService = feathers.NewModelService(model)
+	}
+	if app != nil {
+		if paginate, ok := app.Config("paginate"); ok {
+			if options, err := feathers.ParsePaginateOptions(paginate); err == nil {
+				service.Paginate = options
+			}
+		}
+	}
+	return service
+}
+
+func (s *Service) idField() string {
+	if s.IDField == "" {
+		return DefaultIDField
+	}
+	return s.IDField
+}
+
+// idString converts an id into the key of the store
+func idString(id interface{}) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case hexable:
+		return v.Hex()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(id)
+}
+
+// Service routes
+
+func (s *Service) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	paginate := feathers.ResolvePaginate(params, s.Paginate)
+	if paginate != nil {
+		parsed.Limit = paginate.Limit(parsed.Limit)
+	}
+
+	s.lock.RLock()
+	records := s.matching(parsed.Filter)
+	s.lock.RUnlock()
+
+	total := int64(len(records))
+	records = s.page(records, parsed)
+	if paginate == nil {
+		return records, nil
+	}
+	page := feathers.Page{
+		Total: total,
+		Skip:  parsed.Skip,
+		Data:  records,
+	}
+	if parsed.Limit != nil {
+		page.Limit = *parsed.Limit
+	}
+	return page, nil
+}
+
+func (s *Service) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	record, err := s.get(id, parsed.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return selectFields(copyValue(record).(map[string]interface{}), parsed.Select, s.idField()), nil
+}
+
+func (s *Service) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	record, err := s.prepareRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.insert(record); err != nil {
+		return nil, err
+	}
+	return copyValue(record), nil
+}
+
+// CreateMany creates multiple entities. If one of them is invalid none is created
+func (s *Service) CreateMany(ctx context.Context, data []map[string]interface{}, params feathers.Params) (interface{}, error) {
+	records := make([]map[string]interface{}, 0, len(data))
+	for _, item := range data {
+		record, err := s.prepareRecord(item)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ids := map[string]bool{}
+	for _, record := range records {
+		id := idString(record[s.idField()])
+		if id == "" {
+			continue
+		}
+		if _, exists := s.store[id]; exists || ids[id] {
+			return nil, httperrors.NewConflict(fmt.Sprintf("Entity with id %s already exists", id))
+		}
+		ids[id] = true
+	}
+	result := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		s.insert(record)
+		result = append(result, copyValue(record).(map[string]interface{}))
+	}
+	return result, nil
+}
+
+func (s *Service) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	if id == "" {
+		return nil, httperrors.NewBadRequest("Update requires an id")
+	}
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.mapRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	existing, err := s.get(id, parsed.Filter)
+	if err != nil {
+		return nil, err
+	}
+	// the id cannot be changed
+	record[s.idField()] = existing[s.idField()]
+	s.store[id] = record
+	return selectFields(copyValue(record).(map[string]interface{}), parsed.Select, s.idField()), nil
+}
+
+func (s *Service) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if id != "" {
+		existing, err := s.get(id, parsed.Filter)
+		if err != nil {
+			return nil, err
+		}
+		record, err := s.patch(existing, data)
+		if err != nil {
+			return nil, err
+		}
+		return selectFields(copyValue(record).(map[string]interface{}), parsed.Select, s.idField()), nil
+	}
+
+	matching := s.page(s.matching(parsed.Filter), &query.Query{Sort: parsed.Sort, Skip: parsed.Skip, Limit: parsed.Limit})
+	// validate all entities before patching any of them
+	patched := make([]map[string]interface{}, 0, len(matching))
+	for _, record := range matching {
+		record, err := s.patchedRecord(s.store[idString(record[s.idField()])], data)
+		if err != nil {
+			return nil, err
+		}
+		patched = append(patched, record)
+	}
+	result := make([]map[string]interface{}, 0, len(patched))
+	for _, record := range patched {
+		s.store[idString(record[s.idField()])] = record
+		result = append(result, selectFields(copyValue(record).(map[string]interface{}), parsed.Select, s.idField()))
+	}
+	return result, nil
+}
+
+func (s *Service) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if id != "" {
+		record, err := s.get(id, parsed.Filter)
+		if err != nil {
+			return nil, err
+		}
+		s.delete(id)
+		return selectFields(record, parsed.Select, s.idField()), nil
+	}
+
+	removed := s.page(s.matching(parsed.Filter), parsed)
+	for _, record := range removed {
+		s.delete(idString(record[s.idField()]))
+	}
+	return removed, nil
+}
+
+// prepareRecord maps and validates data of a new entity
+func (s *Service) prepareRecord(data map[string]interface{}) (map[string]interface{}, error) {
+	record, err := s.mapRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := data[s.idField()]; ok && idString(record[s.idField()]) == "" {
+		// the model does not contain the id field
+		record[s.idField()] = id
+	}
+	return record, nil
+}
+
+// mapRecord validates data with the model and returns the mapped model (a copy of data if there is no model)
+func (s *Service) mapRecord(data map[string]interface{}) (map[string]interface{}, error) {
+	if s.ModelService == nil || s.Model == nil {
+		return copyValue(data).(map[string]interface{}), nil
+	}
+	model, err := s.MapAndValidate(data)
+	if err != nil {
+		return nil, httperrors.NewBadRequest(err.Error())
+	}
+	record, err := s.StructToMap(model)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err.Error())
+	}
+	return record, nil
+}
+
+// insert stores a new record. Records without id get the next id of the sequence (the lock has to be held)
+func (s *Service) insert(record map[string]interface{}) error {
+	id := idString(record[s.idField()])
+	if id == "" {
+		for id == "" || s.store[id] != nil {
+			s.sequence++
+			id = strconv.FormatInt(s.sequence, 10)
+		}
+		record[s.idField()] = id
+	} else if _, exists := s.store[id]; exists {
+		return httperrors.NewConflict(fmt.Sprintf("Entity with id %s already exists", id))
+	}
+	s.store[id] = record
+	s.order = append(s.order, id)
+	return nil
+}
+
+func (s *Service) delete(id string) {
+	delete(s.store, id)
+	for i, orderID := range s.order {
+		if orderID == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// get returns the stored record with id if it matches filter (the lock has to be held)
+func (s *Service) get(id string, filter query.Filter) (map[string]interface{}, error) {
+	record, ok := s.store[id]
+	if !ok || !matches(record, filter) {
+		return nil, httperrors.NewNotFound(fmt.Sprintf("Entity with id %s not found", id))
+	}
+	return record, nil
+}
+
+// matching returns copies of all records matching filter in insertion order (the lock has to be held)
+func (s *Service) matching(filter query.Filter) []map[string]interface{} {
+	records := []map[string]interface{}{}
+	for _, id := range s.order {
+		if record := s.store[id]; matches(record, filter) {
+			records = append(records, copyValue(record).(map[string]interface{}))
+		}
+	}
+	return records
+}
+
+// page sorts records and applies $skip, $limit and $select
+func (s *Service) page(records []map[string]interface{}, parsed *query.Query) []map[string]interface{} {
+	sortRecords(records, parsed.Sort)
+	if parsed.Skip >= int64(len(records)) {
+		records = records[:0]
+	} else {
+		records = records[parsed.Skip:]
+	}
+	if parsed.Limit != nil && *parsed.Limit < int64(len(records)) {
+		records = records[:*parsed.Limit]
+	}
+	for i, record := range records {
+		records[i] = selectFields(record, parsed.Select, s.idField())
+	}
+	return records
+}
+
+// patchedRecord returns existing merged with data (validated if there is a model)
+func (s *Service) patchedRecord(existing map[string]interface{}, data map[string]interface{}) (map[string]interface{}, error) {
+	merged := copyValue(existing).(map[string]interface{})
+	for key, value := range data {
+		if key == s.idField() {
+			continue
+		}
+		merged[key] = copyValue(value)
+	}
+	record, err := s.mapRecord(merged)
+	if err != nil {
+		return nil, err
+	}
+	record[s.idField()] = existing[s.idField()]
+	return record, nil
+}
+
+// patch merges data into the stored record existing (the lock has to be held)
+func (s *Service) patch(existing map[string]interface{}, data map[string]interface{}) (map[string]interface{}, error) {
+	record, err := s.patchedRecord(existing, data)
+	if err != nil {
+		return nil, err
+	}
+	s.store[idString(existing[s.idField()])] = record
+	return record, nil
+}
+
+// copyValue copies maps and slices so stored entities cannot be modified by callers
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	case []map[string]interface{}:
+		copied := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item).(map[string]interface{})
+		}
+		return copied
+	}
+	return value
+}
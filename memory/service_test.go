@@ -0,0 +1,222 @@
+package memory_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+	"github.com/tobiasbeck/feathers-go/memory"
+)
+
+type message struct {
+	ID   string `mapstructure:"_id"`
+	Text string `mapstructure:"text" validate:"required"`
+	Age  int    `mapstructure:"age"`
+}
+
+func messageModel() interface{} {
+	return &message{}
+}
+
+func newService(t *testing.T, records ...map[string]interface{}) *memory.Service {
+	service := memory.NewService(nil, nil)
+	for _, record := range records {
+		if _, err := service.Create(context.Background(), record, *feathers.NewParams()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	return service
+}
+
+var testRecords = []map[string]interface{}{
+	{"name": "a", "age": 10, "tags": []interface{}{"x", "y"}, "address": map[string]interface{}{"city": "Berlin"}},
+	{"name": "b", "age": 20, "tags": []interface{}{"y"}},
+	{"name": "c", "age": 30, "done": true},
+	{"name": "d", "age": 40},
+}
+
+func ids(result interface{}) []string {
+	items, _ := result.([]map[string]interface{})
+	if page, ok := result.(feathers.Page); ok {
+		items, _ = page.Data.([]map[string]interface{})
+	}
+	names := []string{}
+	for _, item := range items {
+		names = append(names, item["name"].(string))
+	}
+	return names
+}
+
+func TestFindQuery(t *testing.T) {
+	service := newService(t, testRecords...)
+	for key, test := range []struct {
+		query    map[string]interface{}
+		expected []string
+	}{
+		/* #1 */ {map[string]interface{}{}, []string{"a", "b", "c", "d"}},
+		/* #2 */ {map[string]interface{}{"name": "b"}, []string{"b"}},
+		/* #3 */ {map[string]interface{}{"age": map[string]interface{}{"$gt": 15, "$lte": 30}}, []string{"b", "c"}},
+		/* #4 */ {map[string]interface{}{"age": map[string]interface{}{"$lt": "25"}}, []string{"a", "b"}},
+		/* #5 */ {map[string]interface{}{"name": map[string]interface{}{"$in": []interface{}{"a", "d"}}}, []string{"a", "d"}},
+		/* #6 */ {map[string]interface{}{"name": map[string]interface{}{"$nin": []string{"a", "d"}}}, []string{"b", "c"}},
+		/* #7 */ {map[string]interface{}{"done": map[string]interface{}{"$ne": true}}, []string{"a", "b", "d"}},
+		/* #8 */ {map[string]interface{}{"$or": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"age": 40}}}, []string{"a", "d"}},
+		/* #9 */ {map[string]interface{}{"$and": []interface{}{map[string]interface{}{"age": map[string]interface{}{"$gte": 20}}, map[string]interface{}{"age": map[string]interface{}{"$lt": 40}}}}, []string{"b", "c"}},
+		/* #10 */ {map[string]interface{}{"tags": "y"}, []string{"a", "b"}},
+		/* #11 */ {map[string]interface{}{"address.city": "Berlin"}, []string{"a"}},
+		/* #12 */ {map[string]interface{}{"$sort": map[string]interface{}{"age": -1}, "$skip": 1, "$limit": 2}, []string{"c", "b"}},
+		/* #13 */ {map[string]interface{}{"$sort": []interface{}{map[string]interface{}{"done": 1}, map[string]interface{}{"age": -1}}}, []string{"d", "b", "a", "c"}},
+		/* #14 */ {map[string]interface{}{"age": "20"}, []string{"b"}},
+	} {
+		result, err := service.Find(context.Background(), *feathers.NewParamsQuery(test.query))
+		if err != nil {
+			t.Errorf("Failed #%d: unexpected error: %s", key+1, err)
+			continue
+		}
+		if names := ids(result); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("Failed #%d: wanted: %v, got: %v", key+1, test.expected, names)
+		}
+	}
+
+	for key, query := range []map[string]interface{}{
+		/* #1 */ {"$where": "true"},
+		/* #2 */ {"age": map[string]interface{}{"$regex": ".*"}},
+		/* #3 */ {"$or": map[string]interface{}{"name": "a"}},
+		/* #4 */ {"$limit": -1},
+		/* #5 */ {"$sort": map[string]interface{}{"age": 2}},
+	} {
+		_, err := service.Find(context.Background(), *feathers.NewParamsQuery(query))
+		if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 400 {
+			t.Errorf("Failed invalid #%d: expected BadRequest, got: %v", key+1, err)
+		}
+	}
+
+	exists := *feathers.NewParamsQuery(map[string]interface{}{"done": map[string]interface{}{"$exists": false}})
+	if _, err := service.Find(context.Background(), exists); err == nil {
+		t.Errorf("$exists should only be allowed if it is in the operators of the service")
+	}
+	service.Operators = append(query.DefaultOperators, "$exists")
+	if result, err := service.Find(context.Background(), exists); err != nil || !reflect.DeepEqual(ids(result), []string{"a", "b", "d"}) {
+		t.Errorf("$exists failed: %#v %v", result, err)
+	}
+}
+
+func TestSelectAndPaginate(t *testing.T) {
+	service := newService(t, testRecords...)
+	result, err := service.Get(context.Background(), "2", *feathers.NewParamsQuery(map[string]interface{}{"$select": []interface{}{"name"}}))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "2", "name": "b"}) {
+		t.Errorf("Select failed: %#v %v", result, err)
+	}
+	if _, err := service.Get(context.Background(), "2", *feathers.NewParamsQuery(map[string]interface{}{"name": "a"})); err == nil {
+		t.Errorf("Get should respect the query")
+	}
+
+	service.Paginate = &feathers.PaginateOptions{Default: 2, Max: 3}
+	result, err = service.Find(context.Background(), *feathers.NewParamsQuery(map[string]interface{}{"$skip": 1}))
+	page, ok := result.(feathers.Page)
+	if err != nil || !ok || page.Total != 4 || page.Limit != 2 || page.Skip != 1 || !reflect.DeepEqual(ids(page), []string{"b", "c"}) {
+		t.Errorf("Paginated find failed: %#v %v", result, err)
+	}
+	result, _ = service.Find(context.Background(), *feathers.NewParamsQuery(map[string]interface{}{"$limit": 10}))
+	if page := result.(feathers.Page); page.Limit != 3 || len(ids(page)) != 3 {
+		t.Errorf("Limit should be capped at max: %#v", page)
+	}
+	params := feathers.NewParams()
+	params.Set("paginate", false)
+	if result, _ := service.Find(context.Background(), *params); len(ids(result)) != 4 {
+		t.Errorf("Pagination should be disabled by params: %#v", result)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	service := newService(t, testRecords...)
+	ctx := context.Background()
+	params := *feathers.NewParams()
+
+	data := map[string]interface{}{"name": "e", "nested": map[string]interface{}{"a": 1}}
+	created, err := service.Create(ctx, data, params)
+	if err != nil || created.(map[string]interface{})["_id"] != "5" {
+		t.Fatalf("Create failed: %#v %v", created, err)
+	}
+	data["nested"].(map[string]interface{})["a"] = 2
+	if result, _ := service.Get(ctx, "5", params); result.(map[string]interface{})["nested"].(map[string]interface{})["a"] != 1 {
+		t.Errorf("Stored entity should not be changed by the caller")
+	}
+	if _, err := service.Create(ctx, map[string]interface{}{"_id": "5"}, params); err.(httperrors.FeathersError).Code != 409 {
+		t.Errorf("Expected Conflict for existing id, got: %v", err)
+	}
+
+	result, err := service.Patch(ctx, "5", map[string]interface{}{"age": 50, "_id": "6"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "5", "name": "e", "age": 50, "nested": map[string]interface{}{"a": 1}}) {
+		t.Errorf("Patch failed: %#v %v", result, err)
+	}
+	result, err = service.Update(ctx, "5", map[string]interface{}{"name": "f"}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "5", "name": "f"}) {
+		t.Errorf("Update failed: %#v %v", result, err)
+	}
+
+	result, err = service.Patch(ctx, "", map[string]interface{}{"done": true}, *feathers.NewParamsQuery(map[string]interface{}{"age": map[string]interface{}{"$gte": 30}}))
+	if err != nil || !reflect.DeepEqual(ids(result), []string{"c", "d"}) {
+		t.Errorf("Multi patch failed: %#v %v", result, err)
+	}
+	result, err = service.Remove(ctx, "", *feathers.NewParamsQuery(map[string]interface{}{"done": true}))
+	if err != nil || !reflect.DeepEqual(ids(result), []string{"c", "d"}) {
+		t.Errorf("Multi remove failed: %#v %v", result, err)
+	}
+	result, err = service.Remove(ctx, "1", params)
+	if err != nil || result.(map[string]interface{})["name"] != "a" {
+		t.Errorf("Remove failed: %#v %v", result, err)
+	}
+	if _, err := service.Get(ctx, "1", params); err.(httperrors.FeathersError).Code != 404 {
+		t.Errorf("Expected NotFound for removed entity, got: %v", err)
+	}
+	if result, _ := service.Find(ctx, params); !reflect.DeepEqual(ids(result), []string{"b", "f"}) {
+		t.Errorf("Unexpected entities: %#v", result)
+	}
+}
+
+func TestModelValidation(t *testing.T) {
+	service := memory.NewService(messageModel, nil)
+	ctx := context.Background()
+	params := *feathers.NewParams()
+	if _, err := service.Create(ctx, map[string]interface{}{"age": 3}, params); err.(httperrors.FeathersError).Code != 400 {
+		t.Errorf("Expected BadRequest for invalid entity, got: %v", err)
+	}
+	result, err := service.Create(ctx, map[string]interface{}{"text": "hello", "unknown": true}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"_id": "1", "text": "hello", "age": 0}) {
+		t.Errorf("Create failed: %#v %v", result, err)
+	}
+	if _, err := service.Patch(ctx, "1", map[string]interface{}{"text": ""}, params); err.(httperrors.FeathersError).Code != 400 {
+		t.Errorf("Expected BadRequest for invalid patch, got: %v", err)
+	}
+	if _, err := service.CreateMany(ctx, []map[string]interface{}{{"text": "a"}, {}}, params); err == nil {
+		t.Errorf("Expected error for invalid entity")
+	}
+	if result, _ := service.Find(ctx, params); len(result.([]map[string]interface{})) != 1 {
+		t.Errorf("No entity should be created if one is invalid: %#v", result)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	service := newService(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := context.Background()
+			service.Create(ctx, map[string]interface{}{"name": "a", "age": i}, *feathers.NewParams())
+			service.Find(ctx, *feathers.NewParamsQuery(map[string]interface{}{"age": map[string]interface{}{"$gt": 5}}))
+			service.Patch(ctx, "", map[string]interface{}{"seen": true}, *feathers.NewParams())
+		}(i)
+	}
+	wg.Wait()
+	result, _ := service.Find(context.Background(), *feathers.NewParams())
+	if len(result.([]map[string]interface{})) != 20 {
+		t.Errorf("Expected 20 entities, got %d", len(result.([]map[string]interface{})))
+	}
+}
@@ -2,22 +2,26 @@ package mongo
 
 import (
 	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/hooks"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// MongoKeys converts the hex strings of fields in data into ObjectIds (for multi create calls in every entity)
 func MongoKeys(fields ...string) feathers.Hook {
 	return func(ctx *feathers.Context) error {
-		data := ctx.Data
-		for _, field := range fields {
-			value := data[field]
-			if strValue, ok := value.(string); ok {
-				objId, err := primitive.ObjectIDFromHex(strValue)
-				if err != nil {
-					data[field] = objId
+		items, normalized := hooks.GetItemsNormalized(ctx)
+		for _, data := range items {
+			for _, field := range fields {
+				value := data[field]
+				if strValue, ok := value.(string); ok {
+					objId, err := primitive.ObjectIDFromHex(strValue)
+					if err == nil {
+						data[field] = objId
+					}
 				}
 			}
 		}
-
+		hooks.ReplaceItemsNormalized(ctx, items, normalized)
 		return nil
 	}
 }
@@ -0,0 +1,38 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMongoKeysMultiCreate(t *testing.T) {
+	userIds := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	ctx := &feathers.Context{
+		Type:   feathers.Before,
+		Method: "create",
+		MultiData: []map[string]interface{}{
+			{"userId": userIds[0].Hex()},
+			{"userId": userIds[1].Hex()},
+			{"userId": "invalid"},
+		},
+	}
+	if err := MongoKeys("userId")(ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for key, expected := range []interface{}{userIds[0], userIds[1], "invalid"} {
+		if ctx.MultiData[key]["userId"] != expected {
+			t.Errorf("Failed #%d: wanted %#v, got: %#v", key+1, expected, ctx.MultiData[key]["userId"])
+		}
+	}
+
+	ctx = &feathers.Context{
+		Type:   feathers.Before,
+		Method: "create",
+		Data:   map[string]interface{}{"userId": userIds[0].Hex()},
+	}
+	if err := MongoKeys("userId")(ctx); err != nil || ctx.Data["userId"] != userIds[0] {
+		t.Errorf("Single create was not converted: %#v %v", ctx.Data, err)
+	}
+}
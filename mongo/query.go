@@ -0,0 +1,188 @@
+package mongo
+
+import (
+	"fmt"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// queryOptions contains the feathers common query options ($sort, $skip, $limit, $select)
+type queryOptions struct {
+	sort       bson.D
+	skip       *int64
+	limit      *int64
+	projection bson.M
+	// populate contains the relations requested with `$populate`
+	populate []string
+	// hidden are fields which are not selected but needed to resolve relations (they are removed afterwards)
+	hidden []string
+}
+
+// prepareFilter parses the query of params and returns the mongo filter and the query options (the query is not modified).
+/*
+If id is set the filter only matches the entity with this id. Values of ObjectId fields are converted to ObjectIds
+*/
+func (f *Service) prepareFilter(id string, params feathers.Params) (map[string]interface{}, *queryOptions, error) {
+	raw := params.Query
+	var populate []string
+	if value, ok := raw["$populate"]; ok {
+		var err error
+		populate, err = f.parsePopulate(value, params.Provider)
+		if err != nil {
+			return nil, nil, err
+		}
+		withoutPopulate := make(map[string]interface{}, len(raw))
+		for key, value := range raw {
+			if key != "$populate" {
+				withoutPopulate[key] = value
+			}
+		}
+		raw = withoutPopulate
+	}
+	parsed, err := query.Parse(raw, f.Operators)
+	if err != nil {
+		return nil, nil, err
+	}
+	filter := f.translateFilter(parsed.Filter)
+	if id != "" {
+		objectId, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, nil, httperrors.NewBadRequest(fmt.Sprintf("Invalid id %s", id))
+		}
+		if _, exists := filter["_id"]; exists {
+			filter = map[string]interface{}{"$and": []interface{}{filter, bson.M{"_id": objectId}}}
+		} else {
+			filter["_id"] = objectId
+		}
+	}
+	opts := newQueryOptions(parsed)
+	opts.populate = populate
+	if opts.projection != nil {
+		for _, name := range populate {
+			relation := f.relations[name]
+			if relation.Collection != "" || relation.Type != BelongsTo {
+				continue
+			}
+			if _, selected := opts.projection[relation.LocalField]; !selected {
+				opts.projection[relation.LocalField] = 1
+				opts.hidden = append(opts.hidden, relation.LocalField)
+			}
+		}
+	}
+	return filter, opts, nil
+}
+
+// translateFilter converts a parsed filter into a mongo filter
+func (f *Service) translateFilter(filter query.Filter) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, node := range filter {
+		switch n := node.(type) {
+		case query.Group:
+			if len(n.Filters) == 0 {
+				if n.Operator == "$or" {
+					// mongo does not accept empty lists, an empty $or matches nothing
+					result["$or"] = []interface{}{bson.M{"_id": bson.M{"$in": []interface{}{}}}}
+				}
+				continue
+			}
+			filters := make([]interface{}, 0, len(n.Filters))
+			for _, item := range n.Filters {
+				filters = append(filters, f.translateFilter(item))
+			}
+			result[n.Operator] = filters
+		case query.Condition:
+			value := n.Value
+			if contains(f.objectIdFields, n.Field) {
+				value = objectIdValue(value)
+			}
+			operators, ok := result[n.Field].(bson.M)
+			if !ok {
+				operators = bson.M{}
+				result[n.Field] = operators
+			}
+			operators[n.Operator] = value
+		}
+	}
+	for field, value := range result {
+		// plain values are compared directly
+		if operators, ok := value.(bson.M); ok && len(operators) == 1 {
+			if plain, isEq := operators[query.Eq]; isEq {
+				result[field] = plain
+			}
+		}
+	}
+	return result
+}
+
+// objectIdValue converts hex strings (and hex strings of lists) into ObjectIds
+func objectIdValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		objectId, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return value
+		}
+		return objectId
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = objectIdValue(item)
+		}
+		return values
+	}
+	return value
+}
+
+// newQueryOptions returns the options of the parsed query. `_id` is always included in the projection
+func newQueryOptions(parsed *query.Query) *queryOptions {
+	opts := &queryOptions{limit: parsed.Limit}
+	if parsed.Skip > 0 {
+		skip := parsed.Skip
+		opts.skip = &skip
+	}
+	if len(parsed.Sort) > 0 {
+		opts.sort = bson.D{}
+		for _, field := range parsed.Sort {
+			opts.sort = append(opts.sort, bson.E{Key: field.Field, Value: field.Direction})
+		}
+	}
+	if parsed.Select != nil {
+		opts.projection = bson.M{"_id": 1}
+		for _, field := range parsed.Select {
+			opts.projection[field] = 1
+		}
+	}
+	return opts
+}
+
+// findOptions returns mongo find options for all query options
+func (o *queryOptions) findOptions() *options.FindOptions {
+	findOpts := options.Find()
+	if o.limit != nil {
+		findOpts.SetLimit(*o.limit)
+	}
+	if o.skip != nil {
+		findOpts.SetSkip(*o.skip)
+	}
+	if len(o.sort) > 0 {
+		findOpts.SetSort(o.sort)
+	}
+	if o.projection != nil {
+		findOpts.SetProjection(o.projection)
+	}
+	return findOpts
+}
+
+// findOneOptions returns mongo find options for single entity operations (only `$select` is applied)
+func (o *queryOptions) findOneOptions() *options.FindOneOptions {
+	findOpts := options.FindOne()
+	if o.projection != nil {
+		findOpts.SetProjection(o.projection)
+	}
+	return findOpts
+}
@@ -0,0 +1,279 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RelationType is the kind of a relation between entities
+type RelationType string
+
+const (
+	// BelongsTo relations reference a single entity whose id is stored in LocalField (e.g. `userId` of a message)
+	BelongsTo RelationType = "belongsTo"
+	// HasMany relations reference all entities which store the id of the entity in ForeignField (e.g. `messageId` of comments)
+	HasMany RelationType = "hasMany"
+)
+
+// Relation defines how entities of a service reference entities of another service (use `Service.AddRelation`)
+/*
+If Collection is set the relation is resolved with a `$lookup` stage in the aggregation of the find, which reads the
+collection directly (hooks of the related service are not run). The whole related documents are embedded, so these
+relations can only be populated by internal calls unless External is set. Otherwise Service is called through the app
+with a single batched `$in` query for all entities and the params of the caller, so its hooks (e.g. authorization) apply.
+*/
+type Relation struct {
+	Type RelationType
+	// Service is the path of the related service (used if Collection is empty)
+	Service string
+	// Collection is the mongo collection of the related entities
+	Collection string
+	// LocalField is the ObjectId field containing the id of the related entity (BelongsTo)
+	LocalField string
+	// ForeignField is the field of the related entities containing the id of the entity (HasMany)
+	ForeignField string
+	// External allows external calls to populate a Collection relation. Only set it if all fields of the related
+	// documents may be read by every caller of the service (e.g. no password hashes)
+	External bool
+}
+
+// AddRelation adds a relation which is resolved into field name if it is requested with `$populate`.
+/*
+Example (query `{"$populate": ["user", "comments"]}`):
+````
+service.AddRelation("user", mongo.Relation{Type: mongo.BelongsTo, Collection: "users", LocalField: "userId"})
+service.AddRelation("comments", mongo.Relation{Type: mongo.HasMany, Service: "comments", ForeignField: "messageId"})
+````
+The LocalField of BelongsTo relations has to be an ObjectId field of the model
+*/
+func (f *Service) AddRelation(name string, relation Relation) error {
+	if name == "" || strings.HasPrefix(name, "$") || strings.Contains(name, ".") {
+		return fmt.Errorf("invalid relation name '%s'", name)
+	}
+	if relation.Service == "" && relation.Collection == "" {
+		return fmt.Errorf("relation %s requires a service or a collection", name)
+	}
+	switch relation.Type {
+	case BelongsTo:
+		if !contains(f.objectIdFields, relation.LocalField) {
+			return fmt.Errorf("local field '%s' of relation %s is not an ObjectId field of the model", relation.LocalField, name)
+		}
+	case HasMany:
+		if relation.ForeignField == "" {
+			return fmt.Errorf("relation %s requires a foreign field", name)
+		}
+	default:
+		return fmt.Errorf("unknown type '%s' of relation %s", relation.Type, name)
+	}
+	if f.relations == nil {
+		f.relations = map[string]Relation{}
+	}
+	f.relations[name] = relation
+	return nil
+}
+
+// parsePopulate returns the relations requested with `$populate` (a name or a list of names). External calls (provider is
+// set) can only populate service relations and Collection relations with External
+func (f *Service) parsePopulate(value interface{}, provider string) ([]string, error) {
+	var names []string
+	switch v := value.(type) {
+	case string:
+		names = []string{v}
+	case []string:
+		names = v
+	case []interface{}:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, httperrors.NewBadRequest("$populate has to be a list of relations")
+			}
+			names = append(names, name)
+		}
+	default:
+		return nil, httperrors.NewBadRequest("$populate has to be a list of relations")
+	}
+	for _, name := range names {
+		relation, ok := f.relations[name]
+		if !ok {
+			return nil, httperrors.NewBadRequest(fmt.Sprintf("Unknown relation %s", name))
+		}
+		if provider != "" && relation.Collection != "" && !relation.External {
+			return nil, httperrors.NewForbidden(fmt.Sprintf("Relation %s can not be populated by external calls", name))
+		}
+	}
+	return names, nil
+}
+
+// lookups returns the requested relations which are resolved with `$lookup`
+func (f *Service) lookups(populate []string) []string {
+	names := []string{}
+	for _, name := range populate {
+		if f.relations[name].Collection != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// aggregate finds the entities matching filters and resolves the relations with `$lookup` in the same aggregation
+func (f *Service) aggregate(ctx context.Context, collection *mongo.Collection, filters map[string]interface{}, opts *queryOptions, lookups []string) ([]map[string]interface{}, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filters}}}
+	if len(opts.sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: opts.sort}})
+	}
+	if opts.skip != nil && *opts.skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: *opts.skip}})
+	}
+	if opts.limit != nil && *opts.limit > 0 {
+		// like find a limit of 0 is no limit
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: *opts.limit}})
+	}
+	for _, name := range lookups {
+		relation := f.relations[name]
+		if relation.Type == BelongsTo {
+			pipeline = append(pipeline,
+				bson.D{{Key: "$lookup", Value: bson.D{
+					{Key: "from", Value: relation.Collection},
+					{Key: "localField", Value: relation.LocalField},
+					{Key: "foreignField", Value: "_id"},
+					{Key: "as", Value: name},
+				}}},
+				bson.D{{Key: "$unwind", Value: bson.D{
+					{Key: "path", Value: "$" + name},
+					{Key: "preserveNullAndEmptyArrays", Value: true},
+				}}},
+			)
+			continue
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: relation.Collection},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: relation.ForeignField},
+			{Key: "as", Value: name},
+		}}})
+	}
+	if opts.projection != nil {
+		// the projection is applied last so the local fields of the lookups are available
+		projection := bson.M{}
+		for field, value := range opts.projection {
+			projection[field] = value
+		}
+		for _, name := range lookups {
+			projection[name] = 1
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: projection}})
+	}
+
+	result, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	documents := []map[string]interface{}{}
+	err = result.All(ctx, &documents)
+	if err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// resolveServiceRelations resolves the requested relations without collection by calling their services. Local fields
+// which were not selected (see queryOptions.hidden) are removed afterwards
+func (f *Service) resolveServiceRelations(ctx context.Context, documents []map[string]interface{}, opts *queryOptions, params feathers.Params) error {
+	if len(documents) == 0 {
+		return nil
+	}
+	for _, name := range opts.populate {
+		relation := f.relations[name]
+		if relation.Collection != "" {
+			continue
+		}
+		if err := f.resolveServiceRelation(ctx, documents, name, relation, params); err != nil {
+			return err
+		}
+	}
+	for _, document := range documents {
+		for _, field := range opts.hidden {
+			delete(document, field)
+		}
+	}
+	return nil
+}
+
+// resolveServiceRelation finds the related entities of all documents with one `$in` query and assigns them
+func (f *Service) resolveServiceRelation(ctx context.Context, documents []map[string]interface{}, name string, relation Relation, params feathers.Params) error {
+	service := f.app.Service(relation.Service)
+	if service == nil {
+		return httperrors.NewGeneralError(fmt.Sprintf("Service %s of relation %s not found", relation.Service, name))
+	}
+	localField, foreignField := relation.LocalField, "_id"
+	if relation.Type == HasMany {
+		localField, foreignField = "_id", relation.ForeignField
+	}
+
+	keys := []interface{}{}
+	seen := map[string]bool{}
+	for _, document := range documents {
+		key := objectIdString(document[localField])
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	related := map[string][]map[string]interface{}{}
+	if len(keys) > 0 {
+		relatedParams := feathers.NewParamsFrom(&params, feathers.WithQuery(map[string]interface{}{
+			foreignField: map[string]interface{}{"$in": keys},
+		}))
+		relatedParams.Set("paginate", false)
+		result, err := service.Find(ctx, *relatedParams)
+		if err != nil {
+			return err
+		}
+		for _, item := range resultItems(result) {
+			key := objectIdString(item[foreignField])
+			related[key] = append(related[key], item)
+		}
+	}
+
+	for _, document := range documents {
+		items := related[objectIdString(document[localField])]
+		if relation.Type == HasMany {
+			if items == nil {
+				items = []map[string]interface{}{}
+			}
+			document[name] = items
+		} else if len(items) > 0 {
+			document[name] = items[0]
+		}
+	}
+	return nil
+}
+
+// resultItems returns the entities of a find result (a list or a page)
+func resultItems(result interface{}) []map[string]interface{} {
+	switch v := result.(type) {
+	case feathers.Page:
+		return resultItems(v.Data)
+	case *feathers.Page:
+		return resultItems(v.Data)
+	case []map[string]interface{}:
+		return v
+	case []interface{}:
+		items := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				items = append(items, itemMap)
+			}
+		}
+		return items
+	}
+	return nil
+}
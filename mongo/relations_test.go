@@ -0,0 +1,208 @@
+package mongo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testMessage struct {
+	ID     primitive.ObjectID `mapstructure:"_id"`
+	UserID primitive.ObjectID `mapstructure:"userId"`
+	Text   string             `mapstructure:"text"`
+}
+
+// relatedService is the service of a relation. Find returns the records whose field is in the `$in` query of field
+// (as a list of interface{} like decoded remote results) and keeps the params of each call
+type relatedService struct {
+	*feathers.BaseService
+	field   string
+	records []map[string]interface{}
+	calls   []feathers.Params
+}
+
+func (s *relatedService) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	s.calls = append(s.calls, params)
+	condition, _ := params.Query[s.field].(map[string]interface{})
+	keys, _ := condition["$in"].([]interface{})
+	result := []interface{}{}
+	for _, record := range s.records {
+		for _, key := range keys {
+			if record[s.field] == key {
+				result = append(result, record)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *relatedService) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *relatedService) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *relatedService) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *relatedService) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *relatedService) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	return nil, nil
+}
+
+func newMessagesService(app *feathers.App) *Service {
+	return NewService("messages", func() interface{} { return &testMessage{} }, app)
+}
+
+func TestAddRelation(t *testing.T) {
+	service := newMessagesService(feathers.NewApp())
+	for key, test := range []struct {
+		name     string
+		relation Relation
+		valid    bool
+	}{
+		/* #1 */ {"user", Relation{Type: BelongsTo, Service: "users", LocalField: "userId"}, true},
+		/* #2 */ {"comments", Relation{Type: HasMany, Collection: "comments", ForeignField: "messageId"}, true},
+		/* #3 */ {"", Relation{Type: BelongsTo, Service: "users", LocalField: "userId"}, false},
+		/* #4 */ {"$user", Relation{Type: BelongsTo, Service: "users", LocalField: "userId"}, false},
+		/* #5 */ {"user.name", Relation{Type: BelongsTo, Service: "users", LocalField: "userId"}, false},
+		/* #6 */ {"user", Relation{Type: BelongsTo, LocalField: "userId"}, false},
+		/* #7 */ {"user", Relation{Type: BelongsTo, Service: "users", LocalField: "text"}, false},
+		/* #8 */ {"comments", Relation{Type: HasMany, Service: "comments"}, false},
+		/* #9 */ {"user", Relation{Type: "hasOne", Service: "users", LocalField: "userId"}, false},
+	} {
+		err := service.AddRelation(test.name, test.relation)
+		if (err == nil) != test.valid {
+			t.Errorf("Failed #%d: expected valid %v, got: %v", key+1, test.valid, err)
+		}
+	}
+}
+
+func TestParsePopulate(t *testing.T) {
+	service := newMessagesService(feathers.NewApp())
+	service.AddRelation("user", Relation{Type: BelongsTo, Service: "users", LocalField: "userId"})
+	service.AddRelation("comments", Relation{Type: HasMany, Service: "comments", ForeignField: "messageId"})
+	service.AddRelation("author", Relation{Type: BelongsTo, Collection: "users", LocalField: "userId"})
+	service.AddRelation("likes", Relation{Type: HasMany, Collection: "likes", ForeignField: "messageId", External: true})
+	for key, test := range []struct {
+		value    interface{}
+		provider string
+		expected []string
+		code     int
+	}{
+		/* #1 */ {"user", "rest", []string{"user"}, 0},
+		/* #2 */ {[]string{"user", "comments"}, "rest", []string{"user", "comments"}, 0},
+		/* #3 */ {[]interface{}{"comments"}, "rest", []string{"comments"}, 0},
+		/* #4 */ {"editor", "", nil, 400},
+		/* #5 */ {[]interface{}{"user", 1}, "", nil, 400},
+		/* #6 */ {map[string]interface{}{"user": true}, "", nil, 400},
+		/* #7 */ {"author", "", []string{"author"}, 0},
+		/* #8 */ {"author", "rest", nil, 403},
+		/* #9 */ {[]interface{}{"user", "likes"}, "socketio", []string{"user", "likes"}, 0},
+	} {
+		names, err := service.parsePopulate(test.value, test.provider)
+		if test.expected == nil {
+			if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != test.code {
+				t.Errorf("Failed #%d: expected %d, got: %v", key+1, test.code, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("Failed #%d: expected %v, got: %v (%v)", key+1, test.expected, names, err)
+		}
+	}
+}
+
+func TestResolveServiceRelations(t *testing.T) {
+	app := feathers.NewApp()
+	userA, userB, userMissing := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	messages := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+	users := &relatedService{BaseService: &feathers.BaseService{}, field: "_id", records: []map[string]interface{}{
+		{"_id": userA.Hex(), "name": "a"},
+		{"_id": userB.Hex(), "name": "b"},
+	}}
+	comments := &relatedService{BaseService: &feathers.BaseService{}, field: "messageId", records: []map[string]interface{}{
+		{"_id": "c1", "messageId": messages[0].Hex()},
+		{"_id": "c2", "messageId": messages[0].Hex()},
+		{"_id": "c3", "messageId": messages[2].Hex()},
+	}}
+	app.AddService("users", users)
+	app.AddService("comments", comments)
+	service := newMessagesService(app)
+	service.AddRelation("user", Relation{Type: BelongsTo, Service: "users", LocalField: "userId"})
+	service.AddRelation("comments", Relation{Type: HasMany, Service: "comments", ForeignField: "messageId"})
+
+	// userId is not selected but needed to resolve the user
+	_, opts, err := service.prepareFilter("", *feathers.NewParamsQuery(map[string]interface{}{
+		"$select":   []interface{}{"text"},
+		"$populate": []interface{}{"user", "comments"},
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if opts.projection["userId"] != 1 || !reflect.DeepEqual(opts.hidden, []string{"userId"}) {
+		t.Errorf("Local field was not added to the projection: %#v %v", opts.projection, opts.hidden)
+	}
+
+	tests := []struct {
+		document map[string]interface{}
+		user     interface{}
+		comments []string
+	}{
+		/* #1 */ {map[string]interface{}{"_id": messages[0], "userId": userA, "text": "x"}, "a", []string{"c1", "c2"}},
+		/* #2 */ {map[string]interface{}{"_id": messages[1], "userId": userB}, "b", []string{}},
+		/* #3 */ {map[string]interface{}{"_id": messages[2], "userId": userA}, "a", []string{"c3"}},
+		/* #4 */ {map[string]interface{}{"_id": messages[3], "userId": userMissing}, nil, []string{}},
+	}
+	documents := make([]map[string]interface{}, len(tests))
+	for i, test := range tests {
+		documents[i] = test.document
+	}
+	if err := service.resolveServiceRelations(context.Background(), documents, opts, feathers.Params{Provider: "rest"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(users.calls) != 1 || len(comments.calls) != 1 {
+		t.Fatalf("Relations were not batched: %d user and %d comment finds", len(users.calls), len(comments.calls))
+	}
+	if call := users.calls[0]; call.Provider != "rest" || call.Get("paginate") != false {
+		t.Errorf("Unexpected params of related find: %#v", call)
+	}
+	expectedKeys := []interface{}{userA.Hex(), userB.Hex(), userMissing.Hex()}
+	if keys := users.calls[0].Query["_id"]; !reflect.DeepEqual(keys, map[string]interface{}{"$in": expectedKeys}) {
+		t.Errorf("Unexpected user query: %#v", keys)
+	}
+
+	for key, test := range tests {
+		user, _ := test.document["user"].(map[string]interface{})
+		if (user == nil && test.user != nil) || (user != nil && user["name"] != test.user) {
+			t.Errorf("Failed #%d: expected user %v, got: %#v", key+1, test.user, test.document["user"])
+		}
+		related, ok := test.document["comments"].([]map[string]interface{})
+		if !ok {
+			t.Errorf("Failed #%d: comments are not a list: %#v", key+1, test.document["comments"])
+			continue
+		}
+		ids := make([]string, len(related))
+		for i, comment := range related {
+			ids[i] = comment["_id"].(string)
+		}
+		if !reflect.DeepEqual(ids, test.comments) {
+			t.Errorf("Failed #%d: expected comments %v, got: %v", key+1, test.comments, ids)
+		}
+		if _, ok := test.document["userId"]; ok {
+			t.Errorf("Failed #%d: unselected local field was not removed", key+1)
+		}
+	}
+}
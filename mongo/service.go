@@ -12,6 +12,7 @@ import (
 
 	"github.com/tobiasbeck/feathers-go/feathers"
 	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -68,72 +69,119 @@ type Service struct {
 	CollectionName string
 	validator      *validator.Validate
 	objectIdFields []string
+	// Paginate enables pagination for find calls (nil disables pagination)
+	Paginate *feathers.PaginateOptions
+	// Operators are the allowed query operators (query.DefaultOperators if nil). Other mongo operators like
+	// $exists or $all can be added, their values must not contain operators
+	Operators []string
+	relations map[string]Relation
 }
 
 // Service routes
 
 func (f *Service) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
 	if collection, ok := f.Collection(); ok {
-		filters, findOpts, err := f.prepareFilter("", params.Query)
+		filters, queryOptions, err := f.prepareFilter("", params)
 		if err != nil {
 			return nil, err
 		}
 
-		queryOptions := options.Find()
-
-		if limit, ok := findOpts["$limit"]; ok {
-			queryOptions.SetLimit(int64(limit.(int)))
+		paginate := feathers.ResolvePaginate(params, f.Paginate)
+		if paginate == nil {
+			returnData, err := f.find(ctx, collection, filters, queryOptions)
+			if err != nil {
+				return nil, err
+			}
+			err = f.resolveServiceRelations(ctx, returnData, queryOptions, params)
+			if err != nil {
+				return nil, err
+			}
+			return normalizeArray(returnData), nil
 		}
-		// fmt.Printf("QUERY: %#v\n\n", filters)
-		result, err := collection.Find(ctx, filters, queryOptions)
+
+		queryOptions.limit = paginate.Limit(queryOptions.limit)
+		total, err := collection.CountDocuments(ctx, filters)
 		if err != nil {
 			return nil, err
 		}
-
-		var returnData []map[string]interface{}
-		err = result.All(ctx, &returnData)
+		page := feathers.Page{
+			Total: total,
+			Data:  []map[string]interface{}{},
+		}
+		if queryOptions.skip != nil {
+			page.Skip = *queryOptions.skip
+		}
+		if queryOptions.limit != nil {
+			page.Limit = *queryOptions.limit
+			if page.Limit == 0 {
+				// $limit: 0 only counts the entities
+				return page, nil
+			}
+		}
+		documents, err := f.find(ctx, collection, filters, queryOptions)
 		if err != nil {
 			return nil, err
 		}
-
-		if returnData == nil {
-			returnData = []map[string]interface{}{}
+		err = f.resolveServiceRelations(ctx, documents, queryOptions, params)
+		if err != nil {
+			return nil, err
 		}
-		return normalizeArray(returnData), err
+		page.Data = documents
+		return page, nil
 	}
 	return nil, notReady()
 }
-func (f *Service) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
-	if collection, ok := f.Collection(); ok {
+func (f *Service) find(ctx context.Context, collection *mongo.Collection, filters map[string]interface{}, queryOptions *queryOptions) ([]map[string]interface{}, error) {
+	if lookups := f.lookups(queryOptions.populate); len(lookups) > 0 {
+		return f.aggregate(ctx, collection, filters, queryOptions, lookups)
+	}
+	// fmt.Printf("QUERY: %#v\n\n", filters)
+	result, err := collection.Find(ctx, filters, queryOptions.findOptions())
+	if err != nil {
+		return nil, err
+	}
 
-		query, _, err := f.prepareFilter(id, params.Query)
-		if err != nil {
-			return nil, err
-		}
+	var returnData []map[string]interface{}
+	err = result.All(ctx, &returnData)
+	if err != nil {
+		return nil, err
+	}
 
-		queryOptions := options.Find()
-		queryOptions.SetLimit(int64(1))
+	if returnData == nil {
+		returnData = []map[string]interface{}{}
+	}
+	return returnData, nil
+}
 
-		result, err := collection.Find(ctx, query, queryOptions)
+func (f *Service) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	if collection, ok := f.Collection(); ok {
+
+		query, queryOptions, err := f.prepareFilter(id, params)
 		if err != nil {
 			return nil, err
 		}
 
-		var returnData []map[string]interface{}
-		err = result.All(ctx, &returnData)
+		limit := int64(1)
+		queryOptions.limit, queryOptions.skip = &limit, nil
+		returnData, err := f.find(ctx, collection, query, queryOptions)
 		if err != nil {
 			return nil, err
 		}
 		if len(returnData) <= 0 {
 			return nil, httperrors.NewNotFound(fmt.Sprintf("Entity with id %s not found", id), nil)
 		}
+		err = f.resolveServiceRelations(ctx, returnData, queryOptions, params)
+		if err != nil {
+			return nil, err
+		}
 		// fmt.Printf("\n\nRETURNDATA: %#v\n\n", returnData)
 		return returnData[0], err
 	}
 	return nil, notReady()
 }
 
-func (f *Service) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+// prepareModel maps and validates data and sets timestamps and id for a new entity
+func (f *Service) prepareModel(data map[string]interface{}) (interface{}, error) {
 	model, err := f.MapToModel(data)
 	if err != nil {
 		return nil, errors.Wrap(err, "Model Parsing")
@@ -153,6 +201,14 @@ func (f *Service) Create(ctx context.Context, data map[string]interface{}, param
 			idDoc.GenerateID()
 		}
 	}
+	return model, nil
+}
+
+func (f *Service) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	model, err := f.prepareModel(data)
+	if err != nil {
+		return nil, err
+	}
 	if collection, ok := f.Collection(); ok {
 		result, err := collection.InsertOne(ctx, model)
 		if err != nil {
@@ -172,6 +228,39 @@ func (f *Service) Create(ctx context.Context, data map[string]interface{}, param
 	return nil, notReady()
 }
 
+// CreateMany creates multiple entities using a single InsertMany
+func (f *Service) CreateMany(ctx context.Context, data []map[string]interface{}, params feathers.Params) (interface{}, error) {
+	models := make([]interface{}, 0, len(data))
+	for _, item := range data {
+		model, err := f.prepareModel(item)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	if collection, ok := f.Collection(); ok {
+		if len(models) == 0 {
+			return []map[string]interface{}{}, nil
+		}
+		result, err := collection.InsertMany(ctx, models)
+		if err != nil {
+			return nil, err
+		}
+		modelMaps := make([]map[string]interface{}, 0, len(models))
+		for i, model := range models {
+			modelMap, err := f.StructToMap(model)
+			if err != nil {
+				return nil, err
+			}
+			modelMap["_id"] = result.InsertedIDs[i]
+			modelMaps = append(modelMaps, modelMap)
+		}
+		params.Set("mongo_result", result)
+		return modelMaps, nil
+	}
+	return nil, notReady()
+}
+
 func (f *Service) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
 	model, err := f.MapAndValidate(data)
 	if err != nil {
@@ -183,7 +272,7 @@ func (f *Service) Update(ctx context.Context, id string, data map[string]interfa
 	}
 
 	if collection, ok := f.Collection(); ok {
-		query, _, err := f.prepareFilter(id, params.Query)
+		query, _, err := f.prepareFilter(id, params)
 		if err != nil {
 			return nil, err
 		}
@@ -206,7 +295,7 @@ func (f *Service) Update(ctx context.Context, id string, data map[string]interfa
 func (f *Service) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
 
 	if collection, ok := f.Collection(); ok {
-		query, _, err := f.prepareFilter(id, params.Query)
+		query, queryOptions, err := f.prepareFilter(id, params)
 		if err != nil {
 			return nil, err
 		}
@@ -214,6 +303,10 @@ func (f *Service) Patch(ctx context.Context, id string, data map[string]interfac
 		replacement := remapModifiers(data)
 		// fmt.Printf("replacement: %#v, data: %#v\n", replacement, data)
 
+		if id == "" {
+			return f.patchMany(ctx, collection, query, queryOptions, replacement, params)
+		}
+
 		opts := options.Update()
 		if params.Has("mongodb.upsert") {
 			opts.SetUpsert(true)
@@ -227,9 +320,9 @@ func (f *Service) Patch(ctx context.Context, id string, data map[string]interfac
 			return nil, httperrors.NewNotFound(fmt.Sprintf("Entity with id %s not found", id), nil)
 		}
 		params.Set("mongo_result", result)
-		findResult := collection.FindOne(ctx, query)
+		findResult := collection.FindOne(ctx, query, queryOptions.findOneOptions())
 		if findResult.Err() != nil {
-			return nil, err
+			return nil, findResult.Err()
 		}
 		var document map[string]interface{}
 		err = findResult.Decode(&document)
@@ -243,12 +336,16 @@ func (f *Service) Patch(ctx context.Context, id string, data map[string]interfac
 
 func (f *Service) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
 	if collection, ok := f.Collection(); ok {
-		query, _, err := f.prepareFilter(id, params.Query)
+		query, queryOptions, err := f.prepareFilter(id, params)
 		if err != nil {
 			return nil, err
 		}
 
-		findResult := collection.FindOne(ctx, query)
+		if id == "" {
+			return f.removeMany(ctx, collection, query, queryOptions, params)
+		}
+
+		findResult := collection.FindOne(ctx, query, queryOptions.findOneOptions())
 		var document map[string]interface{}
 		err = findResult.Decode(&document)
 		if err != nil {
@@ -271,6 +368,64 @@ func (f *Service) Remove(ctx context.Context, id string, params feathers.Params)
 	return nil, notReady()
 }
 
+// matchingIds returns the ids of all entities matching query (respecting $sort, $skip and $limit)
+func (f *Service) matchingIds(ctx context.Context, collection *mongo.Collection, query map[string]interface{}, queryOptions *queryOptions) ([]interface{}, error) {
+	findOpts := queryOptions.findOptions().SetProjection(bson.M{"_id": 1})
+	result, err := collection.Find(ctx, query, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	var documents []map[string]interface{}
+	err = result.All(ctx, &documents)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document["_id"])
+	}
+	return ids, nil
+}
+
+// patchMany patches all entities matching query using UpdateMany
+func (f *Service) patchMany(ctx context.Context, collection *mongo.Collection, query map[string]interface{}, opts *queryOptions, replacement map[string]interface{}, params feathers.Params) (interface{}, error) {
+	ids, err := f.matchingIds(ctx, collection, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []map[string]interface{}{}, nil
+	}
+	idFilter := map[string]interface{}{"_id": bson.M{"$in": ids}}
+	result, err := collection.UpdateMany(ctx, idFilter, replacement)
+	if err != nil {
+		return nil, errors.Wrap(err, "Update Error")
+	}
+	params.Set("mongo_result", result)
+	return f.find(ctx, collection, idFilter, &queryOptions{projection: opts.projection})
+}
+
+// removeMany removes all entities matching query using DeleteMany
+func (f *Service) removeMany(ctx context.Context, collection *mongo.Collection, query map[string]interface{}, queryOptions *queryOptions, params feathers.Params) (interface{}, error) {
+	documents, err := f.find(ctx, collection, query, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return documents, nil
+	}
+	ids := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document["_id"])
+	}
+	result, err := collection.DeleteMany(ctx, map[string]interface{}{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	params.Set("mongo_result", result)
+	return documents, nil
+}
+
 func notReady() error {
 	return httperrors.NewGeneralError("Service not ready", nil)
 }
@@ -289,55 +444,6 @@ func (f *Service) mongoDb() (*mongo.Database, bool) {
 	return nil, false
 }
 
-var reservedFilters = []string{"$limit", "$sort", "$select", "$skip"}
-
-func (f *Service) prepareFilter(id string, filter map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
-	feathersFilter := map[string]interface{}{}
-	var err error
-	if id != "" {
-		filter["_id"], err = primitive.ObjectIDFromHex(id)
-		if err != nil {
-			return filter, feathersFilter, err
-		}
-	}
-
-	if len(f.objectIdFields) > 0 {
-		filter = replaceMongoKeys(filter, f.objectIdFields)
-	}
-
-	for filterKey, filerValue := range filter {
-		if !strings.HasPrefix(filterKey, "$") {
-			continue
-		}
-		if contains(reservedFilters, filterKey) {
-			delete(filter, filterKey)
-			feathersFilter["filterKey"] = filerValue
-		}
-	}
-	return filter, feathersFilter, nil
-}
-
-func replaceMongoKeys(filter map[string]interface{}, keys []string) map[string]interface{} {
-	for key, value := range filter {
-		switch v := value.(type) {
-		case map[string]interface{}:
-			if strings.HasPrefix(key, "$") {
-				filter[key] = replaceMongoKeys(v, keys)
-			}
-		case string:
-			if contains(keys, key) {
-				objectId, err := primitive.ObjectIDFromHex(v)
-				if err != nil {
-					continue
-				}
-				// fmt.Printf("REPLACED %s with %v (err: %s)", key, objectId, err)
-				filter[key] = objectId
-			}
-		}
-	}
-	return filter
-}
-
 func filterObjectIdFields(data map[string]interface{}, keys []string) map[string]interface{} {
 	for _, field := range keys {
 		value, isSet := data[field]
@@ -355,6 +461,9 @@ func filterObjectIdFields(data map[string]interface{}, keys []string) map[string
 }
 
 // NewService creates a new mongo service struct
+/*
+If the app config contains a `paginate` key (`default` and `max`) it is used as pagination for the service
+*/
 func NewService(collection string, model feathers.ModelFactory, app *feathers.App) *Service {
 	service := &Service{
 		BaseService:    &feathers.BaseService{},
@@ -363,6 +472,11 @@ func NewService(collection string, model feathers.ModelFactory, app *feathers.Ap
 		objectIdFields: getModelObjectIdFields(model()),
 		app:            app,
 	}
+	if paginate, ok := app.Config("paginate"); ok {
+		if options, err := feathers.ParsePaginateOptions(paginate); err == nil {
+			service.Paginate = options
+		}
+	}
 	return service
 }
 
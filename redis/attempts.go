@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/tobiasbeck/feathers-go/auth"
+)
+
+// AttemptStore is an auth.AttemptStore which tracks attempts in redis (shared by all server instances)
+type AttemptStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func (s *AttemptStore) key(key string) string {
+	return s.prefix + key
+}
+
+// Get returns the attempts of key
+func (s *AttemptStore) Get(ctx context.Context, key string) (auth.Attempts, error) {
+	values, err := s.client.HGetAll(s.key(key)).Result()
+	if err != nil {
+		return auth.Attempts{}, err
+	}
+	return parseAttempts(values), nil
+}
+
+// Attempt records an attempt of key if allow returns true for its current attempts. The key is watched so concurrent
+// attempts are retried with the attempts of each other. The key expires window after the last attempt
+func (s *AttemptStore) Attempt(ctx context.Context, key string, window time.Duration, allow func(auth.Attempts) bool) (auth.Attempts, bool, error) {
+	for {
+		var attempts auth.Attempts
+		recorded := false
+		err := s.client.Watch(func(tx *redis.Tx) error {
+			values, err := tx.HGetAll(s.key(key)).Result()
+			if err != nil {
+				return err
+			}
+			attempts = parseAttempts(values)
+			if !allow(attempts) {
+				return nil
+			}
+			now := time.Now()
+			_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+				pipe.HIncrBy(s.key(key), "count", 1)
+				pipe.HSet(s.key(key), "lastAttempt", now.UnixNano())
+				pipe.Expire(s.key(key), window)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			attempts.Count++
+			attempts.LastAttempt = now
+			recorded = true
+			return nil
+		}, s.key(key))
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return auth.Attempts{}, false, err
+		}
+		return attempts, recorded, nil
+	}
+}
+
+// Reset clears the attempts of key
+func (s *AttemptStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(s.key(key)).Err()
+}
+
+func parseAttempts(values map[string]string) auth.Attempts {
+	attempts := auth.Attempts{}
+	if count, err := strconv.ParseInt(values["count"], 10, 64); err == nil {
+		attempts.Count = count
+	}
+	if lastAttempt, err := strconv.ParseInt(values["lastAttempt"], 10, 64); err == nil {
+		attempts.LastAttempt = time.Unix(0, lastAttempt)
+	}
+	return attempts
+}
+
+// NewAttemptStore creates an attempt store using client (e.g. the `redisClient` config set by ConfigureRedisSync)
+func NewAttemptStore(client *redis.Client) *AttemptStore {
+	return &AttemptStore{
+		client: client,
+		prefix: "feathers-attempts:",
+	}
+}
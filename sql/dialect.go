@@ -0,0 +1,110 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect contains the differences of SQL databases the service has to know about
+type Dialect interface {
+	// Placeholder returns the placeholder of the nth (starting at 1) parameter of a statement
+	Placeholder(n int) string
+	// Quote quotes an identifier (table or column name)
+	Quote(identifier string) string
+	// Limit returns the LIMIT / OFFSET clause (empty if there is neither a limit nor a skip)
+	Limit(limit *int64, skip int64) string
+	// Returning returns if the id of inserted rows has to be read with `RETURNING` (instead of `LastInsertId`)
+	Returning() bool
+}
+
+type postgres struct{}
+
+func (postgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgres) Quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func (postgres) Limit(limit *int64, skip int64) string {
+	clause := ""
+	if limit != nil {
+		clause += " LIMIT " + strconv.FormatInt(*limit, 10)
+	}
+	if skip > 0 {
+		clause += " OFFSET " + strconv.FormatInt(skip, 10)
+	}
+	return clause
+}
+
+func (postgres) Returning() bool {
+	return true
+}
+
+type sqlite struct{}
+
+func (sqlite) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqlite) Quote(identifier string) string {
+	return postgres{}.Quote(identifier)
+}
+
+func (sqlite) Limit(limit *int64, skip int64) string {
+	if limit == nil && skip == 0 {
+		return ""
+	}
+	// sqlite only supports OFFSET after LIMIT (-1 is no limit)
+	clause := " LIMIT -1"
+	if limit != nil {
+		clause = " LIMIT " + strconv.FormatInt(*limit, 10)
+	}
+	if skip > 0 {
+		clause += " OFFSET " + strconv.FormatInt(skip, 10)
+	}
+	return clause
+}
+
+func (sqlite) Returning() bool {
+	return false
+}
+
+type mysql struct{}
+
+func (mysql) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysql) Quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+func (mysql) Limit(limit *int64, skip int64) string {
+	if limit == nil && skip == 0 {
+		return ""
+	}
+	// mysql only supports OFFSET after LIMIT (the largest unsigned number is no limit)
+	clause := " LIMIT 18446744073709551615"
+	if limit != nil {
+		clause = " LIMIT " + strconv.FormatInt(*limit, 10)
+	}
+	if skip > 0 {
+		clause += " OFFSET " + strconv.FormatInt(skip, 10)
+	}
+	return clause
+}
+
+func (mysql) Returning() bool {
+	return false
+}
+
+var (
+	// Postgres is the dialect of PostgreSQL (e.g. github.com/lib/pq or github.com/jackc/pgx)
+	Postgres Dialect = postgres{}
+	// SQLite is the dialect of SQLite (e.g. github.com/mattn/go-sqlite3)
+	SQLite Dialect = sqlite{}
+	// MySQL is the dialect of MySQL and MariaDB (e.g. github.com/go-sql-driver/mysql)
+	MySQL Dialect = mysql{}
+)
@@ -0,0 +1,251 @@
+package sql
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+)
+
+// SupportedOperators are the query operators the sql service can translate
+var SupportedOperators = []string{"$eq", "$ne", "$in", "$nin", "$lt", "$lte", "$gt", "$gte", "$like", "$notlike", "$exists", "$or", "$and", "$nor"}
+
+// comparisonOperators maps the feathers query operators to SQL operators
+var comparisonOperators = map[string]string{
+	"$lt":      "<",
+	"$lte":     "<=",
+	"$gt":      ">",
+	"$gte":     ">=",
+	"$like":    "LIKE",
+	"$notlike": "NOT LIKE",
+}
+
+// fieldPattern matches the column names which can be used in queries. Names are quoted as well but only plain
+// identifiers are accepted so a query can never contain SQL
+var fieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// prepareQuery parses query with the allowed operators of the service (the query is not modified)
+func (s *Service) prepareQuery(raw map[string]interface{}) (*query.Query, error) {
+	parsed, err := query.Parse(raw, s.Operators)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.Filter.Unsupported(SupportedOperators); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// toSlice returns the elements of a slice value (nil if value is not a slice)
+func toSlice(value interface{}) []interface{} {
+	if values, ok := value.([]interface{}); ok {
+		return values
+	}
+	reflected := reflect.ValueOf(value)
+	if !reflected.IsValid() || reflected.Kind() != reflect.Slice {
+		return nil
+	}
+	result := make([]interface{}, reflected.Len())
+	for i := range result {
+		result[i] = reflected.Index(i).Interface()
+	}
+	return result
+}
+
+// isScalar returns if value can be passed to the database as parameter (maps and lists cannot)
+func isScalar(value interface{}) bool {
+	if _, ok := value.([]byte); ok {
+		return true
+	}
+	reflected := reflect.ValueOf(value)
+	if !reflected.IsValid() {
+		return true
+	}
+	kind := reflected.Kind()
+	return kind != reflect.Map && kind != reflect.Slice && kind != reflect.Array
+}
+
+func sortedKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// statement builds a SQL statement and collects its parameters. Values are always passed as parameters and
+// identifiers are validated and quoted
+type statement struct {
+	dialect Dialect
+	args    []interface{}
+}
+
+// param adds value to the parameters and returns its placeholder
+func (s *statement) param(value interface{}) string {
+	s.args = append(s.args, value)
+	return s.dialect.Placeholder(len(s.args))
+}
+
+// column returns the quoted column name (BadRequest if field is not a valid column name)
+func (s *statement) column(field string) (string, error) {
+	if !fieldPattern.MatchString(field) {
+		return "", httperrors.NewBadRequest(fmt.Sprintf("Invalid field name '%s'", field))
+	}
+	return s.dialect.Quote(field), nil
+}
+
+// columns returns the quoted and comma separated list of fields
+func (s *statement) columns(fields []string) (string, error) {
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		column, err := s.column(field)
+		if err != nil {
+			return "", err
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+// where returns the WHERE clause of filter (empty if there are no conditions)
+func (s *statement) where(filter query.Filter) (string, error) {
+	condition, err := s.conditions(filter)
+	if err != nil || condition == "" {
+		return "", err
+	}
+	return " WHERE " + condition, nil
+}
+
+// conditions translates filter into conditions combined with AND (empty if filter is empty)
+func (s *statement) conditions(filter query.Filter) (string, error) {
+	conditions := make([]string, 0, len(filter))
+	for _, node := range filter {
+		var condition string
+		var err error
+		switch n := node.(type) {
+		case query.Group:
+			condition, err = s.group(n)
+		case query.Condition:
+			condition, err = s.condition(n)
+		}
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, condition)
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// group translates the filters of $or, $and or $nor
+func (s *statement) group(group query.Group) (string, error) {
+	conditions := make([]string, 0, len(group.Filters))
+	for _, filter := range group.Filters {
+		condition, err := s.conditions(filter)
+		if err != nil {
+			return "", err
+		}
+		if condition == "" {
+			condition = "1=1"
+		}
+		conditions = append(conditions, "("+condition+")")
+	}
+	switch {
+	case len(conditions) == 0 && group.Operator == "$or":
+		return "1=0", nil
+	case len(conditions) == 0:
+		return "1=1", nil
+	case group.Operator == "$or":
+		return "(" + strings.Join(conditions, " OR ") + ")", nil
+	case group.Operator == "$nor":
+		return "NOT (" + strings.Join(conditions, " OR ") + ")", nil
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", nil
+}
+
+// condition translates the condition of a single field
+func (s *statement) condition(condition query.Condition) (string, error) {
+	column, err := s.column(condition.Field)
+	if err != nil {
+		return "", err
+	}
+	field, operator, operand := condition.Field, condition.Operator, condition.Value
+	switch operator {
+	case query.Eq:
+		if operand == nil {
+			return column + " IS NULL", nil
+		}
+		if !isScalar(operand) {
+			return "", httperrors.NewBadRequest(fmt.Sprintf("Invalid value of '%s'", field))
+		}
+		return column + " = " + s.param(operand), nil
+	case "$ne":
+		if operand == nil {
+			return column + " IS NOT NULL", nil
+		}
+		if !isScalar(operand) {
+			return "", httperrors.NewBadRequest(fmt.Sprintf("Invalid value of %s of '%s'", operator, field))
+		}
+		return "(" + column + " <> " + s.param(operand) + " OR " + column + " IS NULL)", nil
+	case "$in", "$nin":
+		return s.in(field, column, operator, toSlice(operand))
+	case "$exists":
+		if operand.(bool) {
+			return column + " IS NOT NULL", nil
+		}
+		return column + " IS NULL", nil
+	}
+	sqlOperator, ok := comparisonOperators[operator]
+	if !ok {
+		return "", httperrors.NewBadRequest(fmt.Sprintf("Query operator %s is not supported", operator))
+	}
+	if operand == nil || !isScalar(operand) {
+		return "", httperrors.NewBadRequest(fmt.Sprintf("Invalid value of %s of '%s'", operator, field))
+	}
+	return column + " " + sqlOperator + " " + s.param(operand), nil
+}
+
+func (s *statement) in(field string, column string, operator string, values []interface{}) (string, error) {
+	if len(values) == 0 {
+		if operator == "$in" {
+			return "1=0", nil
+		}
+		return "1=1", nil
+	}
+	placeholders := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == nil || !isScalar(value) {
+			return "", httperrors.NewBadRequest(fmt.Sprintf("Invalid value of %s of '%s'", operator, field))
+		}
+		placeholders = append(placeholders, s.param(value))
+	}
+	if operator == "$nin" {
+		return "(" + column + " NOT IN (" + strings.Join(placeholders, ", ") + ") OR " + column + " IS NULL)", nil
+	}
+	return column + " IN (" + strings.Join(placeholders, ", ") + ")", nil
+}
+
+// orderBy returns the ORDER BY clause of the sort fields (empty if there are none)
+func (s *statement) orderBy(fields []query.Sort) (string, error) {
+	if len(fields) == 0 {
+		return "", nil
+	}
+	order := make([]string, 0, len(fields))
+	for _, field := range fields {
+		column, err := s.column(field.Field)
+		if err != nil {
+			return "", err
+		}
+		if field.Direction < 0 {
+			column += " DESC"
+		} else {
+			column += " ASC"
+		}
+		order = append(order, column)
+	}
+	return " ORDER BY " + strings.Join(order, ", "), nil
+}
@@ -0,0 +1,565 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+)
+
+// DefaultIDField is the column containing the id of an entity
+const DefaultIDField = "id"
+
+// Service stores entities in a table of a SQL database using `database/sql`. Use `NewService` for new instance.
+/*
+It supports the feathers query syntax: equality, $in, $nin, $lt, $lte, $gt, $gte, $ne, $or, $and and $nor
+(and $like, $notlike and $exists if they are added to Operators) and the options $sort, $skip, $limit and $select.
+Queries are translated into statements of the dialect in which all values are parameters.
+Field names have to be plain identifiers (letters, digits and underscores).
+If a model is set, data is validated and mapped like the mongo service does and its fields are the columns
+which are written. Without model the keys of the data are used as columns
+*/
+type Service struct {
+	*feathers.BaseService
+	*feathers.ModelService
+	DB      *dbsql.DB
+	Dialect Dialect
+	// Table is the name of the table (may contain a schema like `public.messages`)
+	Table string
+	// IDField is the primary key column (DefaultIDField if empty)
+	IDField string
+	// Paginate enables pagination for find calls (nil disables pagination)
+	Paginate *feathers.PaginateOptions
+	// Operators are the allowed query operators (query.DefaultOperators if nil)
+	Operators []string
+}
+
+// NewService creates a new sql service for table. model may be nil to store data without validation
+/*
+If the app config contains a `paginate` key (`default` and `max`) it is used as pagination for the service
+*/
+func NewService(db *dbsql.DB, dialect Dialect, table string, model feathers.ModelFactory, app *feathers.App) *Service {
+	service := &Service{
+		BaseService: &feathers.BaseService{},
+		DB:          db,
+		Dialect:     dialect,
+		Table:       table,
+		IDField:     DefaultIDField,
+	}
+	if model != nil {
+		service.ModelService = feathers.NewModelService(model)
+	}
+	if app != nil {
+		if paginate, ok := app.Config("paginate"); ok {
+			if options, err := feathers.ParsePaginateOptions(paginate); err == nil {
+				service.Paginate = options
+			}
+		}
+	}
+	return service
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (dbsql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*dbsql.Rows, error)
+}
+
+func (s *Service) idField() string {
+	if s.IDField == "" {
+		return DefaultIDField
+	}
+	return s.IDField
+}
+
+func (s *Service) statement() *statement {
+	return &statement{dialect: s.Dialect}
+}
+
+// table returns the quoted table name
+func (s *Service) table() string {
+	parts := strings.Split(s.Table, ".")
+	for i, part := range parts {
+		parts[i] = s.Dialect.Quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// withID returns a filter which matches the entity with id if it also matches filter
+func (s *Service) withID(id interface{}, filter query.Filter) query.Filter {
+	return append(query.Filter{query.Condition{Field: s.idField(), Operator: query.Eq, Value: id}}, filter...)
+}
+
+// Service routes
+
+func (s *Service) Find(ctx context.Context, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	paginate := feathers.ResolvePaginate(params, s.Paginate)
+	if paginate == nil {
+		return s.find(ctx, s.DB, parsed)
+	}
+
+	parsed.Limit = paginate.Limit(parsed.Limit)
+	total, err := s.count(ctx, parsed.Filter)
+	if err != nil {
+		return nil, err
+	}
+	page := feathers.Page{
+		Total: total,
+		Skip:  parsed.Skip,
+		Data:  []map[string]interface{}{},
+	}
+	if parsed.Limit != nil {
+		page.Limit = *parsed.Limit
+		if page.Limit == 0 {
+			// $limit: 0 only counts the entities
+			return page, nil
+		}
+	}
+	page.Data, err = s.find(ctx, s.DB, parsed)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *Service) Get(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	return s.get(ctx, s.DB, id, parsed.Filter, parsed.Select)
+}
+
+func (s *Service) Create(ctx context.Context, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	record, err := s.mapRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.insert(ctx, s.DB, record)
+	if err != nil {
+		return nil, err
+	}
+	return s.get(ctx, s.DB, id, nil, nil)
+}
+
+// CreateMany creates multiple entities in a transaction. If one of them is invalid none is created
+func (s *Service) CreateMany(ctx context.Context, data []map[string]interface{}, params feathers.Params) (interface{}, error) {
+	records := make([]map[string]interface{}, 0, len(data))
+	for _, item := range data {
+		record, err := s.mapRecord(item)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	result := make([]map[string]interface{}, 0, len(records))
+	err := s.transaction(ctx, func(tx *dbsql.Tx) error {
+		for _, record := range records {
+			id, err := s.insert(ctx, tx, record)
+			if err != nil {
+				return err
+			}
+			created, err := s.get(ctx, tx, id, nil, nil)
+			if err != nil {
+				return err
+			}
+			result = append(result, created)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Update replaces the entity. Without model only the columns contained in data are written
+func (s *Service) Update(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	if id == "" {
+		return nil, httperrors.NewBadRequest("Update requires an id")
+	}
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.mapRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	// the id cannot be changed
+	delete(record, s.idField())
+
+	var result map[string]interface{}
+	err = s.transaction(ctx, func(tx *dbsql.Tx) error {
+		if _, err := s.get(ctx, tx, id, parsed.Filter, []string{}); err != nil {
+			return err
+		}
+		if err := s.update(ctx, tx, id, record); err != nil {
+			return err
+		}
+		result, err = s.get(ctx, tx, id, nil, parsed.Select)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Service) Patch(ctx context.Context, id string, data map[string]interface{}, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	if id != "" {
+		var result map[string]interface{}
+		err = s.transaction(ctx, func(tx *dbsql.Tx) error {
+			existing, err := s.get(ctx, tx, id, parsed.Filter, nil)
+			if err != nil {
+				return err
+			}
+			if err := s.patch(ctx, tx, existing, data); err != nil {
+				return err
+			}
+			result, err = s.get(ctx, tx, id, nil, parsed.Select)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	result := []map[string]interface{}{}
+	err = s.transaction(ctx, func(tx *dbsql.Tx) error {
+		matching, err := s.find(ctx, tx, &query.Query{Filter: parsed.Filter, Sort: parsed.Sort, Skip: parsed.Skip, Limit: parsed.Limit})
+		if err != nil {
+			return err
+		}
+		for _, existing := range matching {
+			if err := s.patch(ctx, tx, existing, data); err != nil {
+				return err
+			}
+			patched, err := s.get(ctx, tx, existing[s.idField()], nil, parsed.Select)
+			if err != nil {
+				return err
+			}
+			result = append(result, patched)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Service) Remove(ctx context.Context, id string, params feathers.Params) (interface{}, error) {
+	parsed, err := s.prepareQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	if id != "" {
+		var result map[string]interface{}
+		err = s.transaction(ctx, func(tx *dbsql.Tx) error {
+			result, err = s.get(ctx, tx, id, parsed.Filter, parsed.Select)
+			if err != nil {
+				return err
+			}
+			return s.delete(ctx, tx, []interface{}{result[s.idField()]})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	var result []map[string]interface{}
+	err = s.transaction(ctx, func(tx *dbsql.Tx) error {
+		result, err = s.find(ctx, tx, parsed)
+		if err != nil || len(result) == 0 {
+			return err
+		}
+		ids := make([]interface{}, 0, len(result))
+		for _, record := range result {
+			ids = append(ids, record[s.idField()])
+		}
+		return s.delete(ctx, tx, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// transaction runs fn in a transaction which is rolled back if fn returns an error
+func (s *Service) transaction(ctx context.Context, fn func(tx *dbsql.Tx) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// mapRecord validates data with the model and returns the mapped model (a copy of data if there is no model)
+func (s *Service) mapRecord(data map[string]interface{}) (map[string]interface{}, error) {
+	if s.ModelService == nil || s.Model == nil {
+		record := make(map[string]interface{}, len(data))
+		for key, value := range data {
+			record[key] = value
+		}
+		return record, nil
+	}
+	model, err := s.MapAndValidate(data)
+	if err != nil {
+		return nil, httperrors.NewBadRequest(err.Error())
+	}
+	record, err := s.StructToMap(model)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err.Error())
+	}
+	if id, ok := data[s.idField()]; ok && isZero(record[s.idField()]) {
+		// the model does not contain the id field
+		record[s.idField()] = id
+	}
+	return record, nil
+}
+
+// isZero returns if value is nil or the zero value of its type (e.g. an id which is generated by the database)
+func isZero(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	return reflect.ValueOf(value).IsZero()
+}
+
+// find selects the rows matching the parsed query
+func (s *Service) find(ctx context.Context, q querier, parsed *query.Query) ([]map[string]interface{}, error) {
+	stmt := s.statement()
+	columns := "*"
+	if parsed.Select != nil {
+		fields := []string{s.idField()}
+		for _, field := range parsed.Select {
+			if field != s.idField() {
+				fields = append(fields, field)
+			}
+		}
+		var err error
+		if columns, err = stmt.columns(fields); err != nil {
+			return nil, err
+		}
+	}
+	where, err := stmt.where(parsed.Filter)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, err := stmt.orderBy(parsed.Sort)
+	if err != nil {
+		return nil, err
+	}
+	sqlQuery := "SELECT " + columns + " FROM " + s.table() + where + orderBy + s.Dialect.Limit(parsed.Limit, parsed.Skip)
+	rows, err := q.QueryContext(ctx, sqlQuery, stmt.args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows)
+}
+
+// get returns the row with id if it matches filter (NotFound otherwise)
+func (s *Service) get(ctx context.Context, q querier, id interface{}, filter query.Filter, fields []string) (map[string]interface{}, error) {
+	limit := int64(1)
+	records, err := s.find(ctx, q, &query.Query{Filter: s.withID(id, filter), Limit: &limit, Select: fields})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, httperrors.NewNotFound(fmt.Sprintf("Entity with id %v not found", id))
+	}
+	return records[0], nil
+}
+
+func (s *Service) count(ctx context.Context, filter query.Filter) (int64, error) {
+	stmt := s.statement()
+	where, err := stmt.where(filter)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	err = s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.table()+where, stmt.args...).Scan(&total)
+	return total, err
+}
+
+// assignments returns the columns and parameters of record (sorted by column)
+func (s *Service) assignments(stmt *statement, record map[string]interface{}) ([]string, []string, error) {
+	columns := make([]string, 0, len(record))
+	placeholders := make([]string, 0, len(record))
+	for _, key := range sortedKeys(record) {
+		column, err := stmt.column(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isScalar(record[key]) {
+			return nil, nil, httperrors.NewBadRequest(fmt.Sprintf("Invalid value of '%s'", key))
+		}
+		columns = append(columns, column)
+		placeholders = append(placeholders, stmt.param(record[key]))
+	}
+	return columns, placeholders, nil
+}
+
+// insert inserts record and returns its id. A zero id is left out so the database generates it
+func (s *Service) insert(ctx context.Context, q querier, record map[string]interface{}) (interface{}, error) {
+	id, hasID := record[s.idField()]
+	if hasID && isZero(id) {
+		delete(record, s.idField())
+		hasID = false
+	}
+	stmt := s.statement()
+	columns, placeholders, err := s.assignments(stmt, record)
+	if err != nil {
+		return nil, err
+	}
+	sqlQuery := "INSERT INTO " + s.table() + " DEFAULT VALUES"
+	if len(columns) > 0 {
+		sqlQuery = "INSERT INTO " + s.table() + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	if s.Dialect.Returning() {
+		rows, err := q.QueryContext(ctx, sqlQuery+" RETURNING "+s.Dialect.Quote(s.idField()), stmt.args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+			return nil, httperrors.NewGeneralError("Inserted entity returned no id")
+		}
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		return convertValue(id), rows.Err()
+	}
+
+	result, err := q.ExecContext(ctx, sqlQuery, stmt.args...)
+	if err != nil {
+		return nil, err
+	}
+	if hasID {
+		return id, nil
+	}
+	return result.LastInsertId()
+}
+
+// update sets the columns of record of the row with id
+func (s *Service) update(ctx context.Context, q querier, id interface{}, record map[string]interface{}) error {
+	if len(record) == 0 {
+		return nil
+	}
+	stmt := s.statement()
+	columns, placeholders, err := s.assignments(stmt, record)
+	if err != nil {
+		return err
+	}
+	set := make([]string, len(columns))
+	for i, column := range columns {
+		set[i] = column + " = " + placeholders[i]
+	}
+	sqlQuery := "UPDATE " + s.table() + " SET " + strings.Join(set, ", ") + " WHERE " + s.Dialect.Quote(s.idField()) + " = " + stmt.param(id)
+	_, err = q.ExecContext(ctx, sqlQuery, stmt.args...)
+	return err
+}
+
+// patch updates the columns of data of the row existing. With model the merged entity is validated first
+func (s *Service) patch(ctx context.Context, q querier, existing map[string]interface{}, data map[string]interface{}) error {
+	values := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if key != s.idField() {
+			values[key] = value
+		}
+	}
+	if s.ModelService != nil && s.Model != nil {
+		merged := make(map[string]interface{}, len(existing)+len(values))
+		for key, value := range existing {
+			merged[key] = value
+		}
+		for key, value := range values {
+			merged[key] = value
+		}
+		record, err := s.mapRecord(merged)
+		if err != nil {
+			return err
+		}
+		for key := range values {
+			if value, ok := record[key]; ok {
+				values[key] = value
+			} else {
+				// the field is not part of the model
+				delete(values, key)
+			}
+		}
+	}
+	return s.update(ctx, q, existing[s.idField()], values)
+}
+
+// delete deletes the rows with ids
+func (s *Service) delete(ctx context.Context, q querier, ids []interface{}) error {
+	stmt := s.statement()
+	where, err := stmt.where(query.Filter{query.Condition{Field: s.idField(), Operator: "$in", Value: ids}})
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, "DELETE FROM "+s.table()+where, stmt.args...)
+	return err
+}
+
+// scanRows reads all rows into maps of column names to values and closes rows
+func scanRows(rows *dbsql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	records := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			record[column] = convertValue(values[i])
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// convertValue converts text returned as bytes by drivers into a string
+func convertValue(value interface{}) interface{} {
+	if bytes, ok := value.([]byte); ok {
+		return string(bytes)
+	}
+	return value
+}
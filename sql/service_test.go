@@ -0,0 +1,275 @@
+package sql_test
+
+import (
+	"context"
+	dbsql "database/sql"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/tobiasbeck/feathers-go/feathers"
+	"github.com/tobiasbeck/feathers-go/feathers/httperrors"
+	"github.com/tobiasbeck/feathers-go/feathers/query"
+	"github.com/tobiasbeck/feathers-go/sql"
+)
+
+type note struct {
+	ID   int64  `mapstructure:"id"`
+	Text string `mapstructure:"text" validate:"required"`
+	Age  int    `mapstructure:"age"`
+}
+
+func noteModel() interface{} {
+	return &note{}
+}
+
+// newDB opens an in memory sqlite database (a single connection so all statements use the same database)
+func newDB(t *testing.T) *dbsql.DB {
+	db, err := dbsql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, schema := range []string{
+		"CREATE TABLE messages (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, age INTEGER, done BOOLEAN)",
+		"CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, text TEXT NOT NULL, age INTEGER)",
+	} {
+		if _, err := db.Exec(schema); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	return db
+}
+
+func newService(t *testing.T, records ...map[string]interface{}) *sql.Service {
+	service := sql.NewService(newDB(t), sql.SQLite, "messages", nil, nil)
+	for _, record := range records {
+		if _, err := service.Create(context.Background(), record, *feathers.NewParams()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	return service
+}
+
+var testRecords = []map[string]interface{}{
+	{"name": "a", "age": 10},
+	{"name": "b", "age": 20},
+	{"name": "c", "age": 30, "done": true},
+	{"name": "d", "age": 40, "done": false},
+}
+
+func names(result interface{}) []string {
+	items, _ := result.([]map[string]interface{})
+	if page, ok := result.(feathers.Page); ok {
+		items, _ = page.Data.([]map[string]interface{})
+	}
+	names := []string{}
+	for _, item := range items {
+		names = append(names, item["name"].(string))
+	}
+	return names
+}
+
+func TestFindQuery(t *testing.T) {
+	service := newService(t, testRecords...)
+	service.Operators = append(query.DefaultOperators, "$like", "$exists")
+	for key, test := range []struct {
+		query    map[string]interface{}
+		expected []string
+	}{
+		/* #1 */ {map[string]interface{}{}, []string{"a", "b", "c", "d"}},
+		/* #2 */ {map[string]interface{}{"name": "b"}, []string{"b"}},
+		/* #3 */ {map[string]interface{}{"age": map[string]interface{}{"$gt": 15, "$lte": 30}}, []string{"b", "c"}},
+		/* #4 */ {map[string]interface{}{"age": map[string]interface{}{"$lt": "25"}}, []string{"a", "b"}},
+		/* #5 */ {map[string]interface{}{"name": map[string]interface{}{"$in": []interface{}{"a", "d"}}}, []string{"a", "d"}},
+		/* #6 */ {map[string]interface{}{"name": map[string]interface{}{"$nin": []string{"a", "d"}}}, []string{"b", "c"}},
+		/* #7 */ {map[string]interface{}{"done": map[string]interface{}{"$ne": true}}, []string{"a", "b", "d"}},
+		/* #8 */ {map[string]interface{}{"$or": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"age": 40}}}, []string{"a", "d"}},
+		/* #9 */ {map[string]interface{}{"$and": []interface{}{map[string]interface{}{"age": map[string]interface{}{"$gte": 20}}, map[string]interface{}{"age": map[string]interface{}{"$lt": 40}}}}, []string{"b", "c"}},
+		/* #10 */ {map[string]interface{}{"$nor": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"age": 40}}}, []string{"b", "c"}},
+		/* #11 */ {map[string]interface{}{"done": nil}, []string{"a", "b"}},
+		/* #12 */ {map[string]interface{}{"name": map[string]interface{}{"$like": "%c%"}}, []string{"c"}},
+		/* #13 */ {map[string]interface{}{"name": map[string]interface{}{"$in": []interface{}{}}}, []string{}},
+		/* #14 */ {map[string]interface{}{"$sort": map[string]interface{}{"age": -1}, "$skip": 1, "$limit": 2}, []string{"c", "b"}},
+		/* #15 */ {map[string]interface{}{"$sort": []interface{}{map[string]interface{}{"done": 1}, map[string]interface{}{"age": -1}}, "$skip": "1"}, []string{"a", "d", "c"}},
+		/* #16 */ {map[string]interface{}{"age": "20"}, []string{"b"}},
+		/* #17 */ {map[string]interface{}{"name": "a' OR '1'='1"}, []string{}},
+		/* #18 */ {map[string]interface{}{"done": map[string]interface{}{"$exists": true}}, []string{"c", "d"}},
+	} {
+		result, err := service.Find(context.Background(), *feathers.NewParamsQuery(test.query))
+		if err != nil {
+			t.Errorf("Failed #%d: unexpected error: %s", key+1, err)
+			continue
+		}
+		if names := names(result); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("Failed #%d: wanted: %v, got: %v", key+1, test.expected, names)
+		}
+	}
+
+	for key, query := range []map[string]interface{}{
+		/* #1 */ {"$where": "true"},
+		/* #2 */ {"age": map[string]interface{}{"$regex": ".*"}},
+		/* #3 */ {"$or": map[string]interface{}{"name": "a"}},
+		/* #4 */ {"$limit": -1},
+		/* #5 */ {"$sort": map[string]interface{}{"age": 2}},
+		/* #6 */ {`name" = "name" OR "1`: "a"},
+		/* #7 */ {"$sort": map[string]interface{}{"age; DROP TABLE messages": 1}},
+		/* #8 */ {"$select": []interface{}{"name", "(SELECT 1)"}},
+		/* #9 */ {"name": map[string]interface{}{"a": 1}},
+		/* #10 */ {"age": map[string]interface{}{"$gt": []interface{}{1}}},
+	} {
+		_, err := service.Find(context.Background(), *feathers.NewParamsQuery(query))
+		if featherErr, ok := err.(httperrors.FeathersError); !ok || featherErr.Code != 400 {
+			t.Errorf("Failed invalid #%d: expected BadRequest, got: %v", key+1, err)
+		}
+	}
+	if result, _ := service.Find(context.Background(), *feathers.NewParams()); len(names(result)) != 4 {
+		t.Errorf("Table should not be changed by invalid queries: %#v", result)
+	}
+}
+
+func TestSelectAndPaginate(t *testing.T) {
+	service := newService(t, testRecords...)
+	result, err := service.Get(context.Background(), "2", *feathers.NewParamsQuery(map[string]interface{}{"$select": []interface{}{"name"}}))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"id": int64(2), "name": "b"}) {
+		t.Errorf("Select failed: %#v %v", result, err)
+	}
+	if _, err := service.Get(context.Background(), "2", *feathers.NewParamsQuery(map[string]interface{}{"name": "a"})); err == nil {
+		t.Errorf("Get should respect the query")
+	}
+
+	service.Paginate = &feathers.PaginateOptions{Default: 2, Max: 3}
+	result, err = service.Find(context.Background(), *feathers.NewParamsQuery(map[string]interface{}{"$skip": 1}))
+	page, ok := result.(feathers.Page)
+	if err != nil || !ok || page.Total != 4 || page.Limit != 2 || page.Skip != 1 || !reflect.DeepEqual(names(page), []string{"b", "c"}) {
+		t.Errorf("Paginated find failed: %#v %v", result, err)
+	}
+	result, _ = service.Find(context.Background(), *feathers.NewParamsQuery(map[string]interface{}{"$limit": 10, "age": map[string]interface{}{"$gt": 10}}))
+	if page := result.(feathers.Page); page.Limit != 3 || page.Total != 3 || len(names(page)) != 3 {
+		t.Errorf("Limit should be capped at max: %#v", page)
+	}
+	result, _ = service.Find(context.Background(), *feathers.NewParamsQuery(map[string]interface{}{"$limit": 0}))
+	if page := result.(feathers.Page); page.Total != 4 || len(names(page)) != 0 {
+		t.Errorf("$limit 0 should only count: %#v", page)
+	}
+	params := feathers.NewParams()
+	params.Set("paginate", false)
+	if result, _ := service.Find(context.Background(), *params); len(names(result)) != 4 {
+		t.Errorf("Pagination should be disabled by params: %#v", result)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	service := newService(t, testRecords...)
+	ctx := context.Background()
+	params := *feathers.NewParams()
+
+	created, err := service.Create(ctx, map[string]interface{}{"name": "e", "age": 50}, params)
+	if err != nil || !reflect.DeepEqual(created, map[string]interface{}{"id": int64(5), "name": "e", "age": int64(50), "done": nil}) {
+		t.Fatalf("Create failed: %#v %v", created, err)
+	}
+	if _, err := service.Create(ctx, map[string]interface{}{"id": 5, "name": "f"}, params); err == nil {
+		t.Errorf("Expected error for existing id")
+	}
+
+	result, err := service.Patch(ctx, "5", map[string]interface{}{"age": 51, "id": 6}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"id": int64(5), "name": "e", "age": int64(51), "done": nil}) {
+		t.Errorf("Patch failed: %#v %v", result, err)
+	}
+	result, err = service.Update(ctx, "5", map[string]interface{}{"name": "f", "age": nil}, *feathers.NewParamsQuery(map[string]interface{}{"$select": []interface{}{"name"}}))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"id": int64(5), "name": "f"}) {
+		t.Errorf("Update failed: %#v %v", result, err)
+	}
+	if _, err := service.Update(ctx, "5", map[string]interface{}{"name": "g"}, *feathers.NewParamsQuery(map[string]interface{}{"name": "e"})); err.(httperrors.FeathersError).Code != 404 {
+		t.Errorf("Update should respect the query, got: %v", err)
+	}
+
+	result, err = service.Patch(ctx, "", map[string]interface{}{"done": true}, *feathers.NewParamsQuery(map[string]interface{}{"age": map[string]interface{}{"$gte": 30}}))
+	if err != nil || !reflect.DeepEqual(names(result), []string{"c", "d"}) {
+		t.Errorf("Multi patch failed: %#v %v", result, err)
+	}
+	result, err = service.Remove(ctx, "", *feathers.NewParamsQuery(map[string]interface{}{"done": true}))
+	if err != nil || !reflect.DeepEqual(names(result), []string{"c", "d"}) {
+		t.Errorf("Multi remove failed: %#v %v", result, err)
+	}
+	result, err = service.Remove(ctx, "1", params)
+	if err != nil || result.(map[string]interface{})["name"] != "a" {
+		t.Errorf("Remove failed: %#v %v", result, err)
+	}
+	if _, err := service.Get(ctx, "1", params); err.(httperrors.FeathersError).Code != 404 {
+		t.Errorf("Expected NotFound for removed entity, got: %v", err)
+	}
+	if result, _ := service.Find(ctx, params); !reflect.DeepEqual(names(result), []string{"b", "f"}) {
+		t.Errorf("Unexpected entities: %#v", result)
+	}
+
+	result, err = service.CreateMany(ctx, []map[string]interface{}{{"name": "g"}, {"name": "h"}}, params)
+	if err != nil || !reflect.DeepEqual(names(result), []string{"g", "h"}) {
+		t.Errorf("Create many failed: %#v %v", result, err)
+	}
+	if _, err := service.CreateMany(ctx, []map[string]interface{}{{"name": "i"}, {"id": 2, "name": "j"}}, params); err == nil {
+		t.Errorf("Expected error for existing id")
+	}
+	if result, _ := service.Find(ctx, params); !reflect.DeepEqual(names(result), []string{"b", "f", "g", "h"}) {
+		t.Errorf("No entity should be created if one fails: %#v", result)
+	}
+}
+
+func TestModelValidation(t *testing.T) {
+	service := sql.NewService(newDB(t), sql.SQLite, "notes", noteModel, nil)
+	ctx := context.Background()
+	params := *feathers.NewParams()
+	if _, err := service.Create(ctx, map[string]interface{}{"age": 3}, params); err.(httperrors.FeathersError).Code != 400 {
+		t.Errorf("Expected BadRequest for invalid entity, got: %v", err)
+	}
+	result, err := service.Create(ctx, map[string]interface{}{"text": "hello", "unknown": true}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"id": int64(1), "text": "hello", "age": int64(0)}) {
+		t.Errorf("Create failed: %#v %v", result, err)
+	}
+	if _, err := service.Patch(ctx, "1", map[string]interface{}{"text": ""}, params); err.(httperrors.FeathersError).Code != 400 {
+		t.Errorf("Expected BadRequest for invalid patch, got: %v", err)
+	}
+	result, err = service.Patch(ctx, "1", map[string]interface{}{"age": 4, "unknown": true}, params)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"id": int64(1), "text": "hello", "age": int64(4)}) {
+		t.Errorf("Patch failed: %#v %v", result, err)
+	}
+	if _, err := service.CreateMany(ctx, []map[string]interface{}{{"text": "a"}, {}}, params); err == nil {
+		t.Errorf("Expected error for invalid entity")
+	}
+	if result, _ := service.Find(ctx, params); len(result.([]map[string]interface{})) != 1 {
+		t.Errorf("No entity should be created if one is invalid: %#v", result)
+	}
+}
+
+func TestDialects(t *testing.T) {
+	limit := int64(5)
+	for key, test := range []struct {
+		dialect     sql.Dialect
+		placeholder string
+		quoted      string
+		limit       string
+		skip        string
+	}{
+		/* #1 */ {sql.Postgres, "$2", `"a""b"`, " LIMIT 5 OFFSET 3", " OFFSET 3"},
+		/* #2 */ {sql.SQLite, "?", `"a""b"`, " LIMIT 5 OFFSET 3", " LIMIT -1 OFFSET 3"},
+		/* #3 */ {sql.MySQL, "?", "`a\"b`", " LIMIT 5 OFFSET 3", " LIMIT 18446744073709551615 OFFSET 3"},
+	} {
+		if placeholder := test.dialect.Placeholder(2); placeholder != test.placeholder {
+			t.Errorf("Failed #%d: wanted placeholder %s, got: %s", key+1, test.placeholder, placeholder)
+		}
+		if quoted := test.dialect.Quote(`a"b`); quoted != test.quoted {
+			t.Errorf("Failed #%d: wanted quoted %s, got: %s", key+1, test.quoted, quoted)
+		}
+		if clause := test.dialect.Limit(&limit, 3); clause != test.limit {
+			t.Errorf("Failed #%d: wanted limit %q, got: %q", key+1, test.limit, clause)
+		}
+		if clause := test.dialect.Limit(nil, 3); clause != test.skip {
+			t.Errorf("Failed #%d: wanted skip %q, got: %q", key+1, test.skip, clause)
+		}
+		if clause := test.dialect.Limit(nil, 0); clause != "" {
+			t.Errorf("Failed #%d: expected no limit, got: %q", key+1, clause)
+		}
+	}
+}